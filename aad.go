@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// encodeMultiAAD joins AAD segments into the single AAD string GCM
+// authenticates. Each segment is written as length (4 bytes, big-endian) ||
+// segment, so ["ab", "c"] and ["a", "bc"] differ. A single segment is used
+// as is, which keeps one-segment AAD identical to plain GCMEncrypt; the
+// price is that the encoding is only unique among lists of two or more
+// segments (see GCMEncryptMultiAAD).
+func encodeMultiAAD(segments [][]byte) []byte {
+	if len(segments) == 1 {
+		return segments[0]
+	}
+	var n int
+	for _, s := range segments {
+		n += 4 + len(s)
+	}
+	out := make([]byte, 0, n)
+	for _, s := range segments {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(s)))
+		out = append(out, s...)
+	}
+	return out
+}
+
+// GCMEncryptMultiAAD is GCMEncrypt with the AAD given as ordered segments.
+// Decryption must supply the same segments in the same order.
+//
+// Two different lists of two or more segments never authenticate the same,
+// but a single segment is passed through raw, so it matches any longer list
+// whose encoding it spells out byte for byte. An empty list and a single
+// empty segment also match. If a key is used with different segment counts,
+// fix the count in the protocol or make it one of the segments.
+// Returns: ciphertext || tag (16 bytes)
+func GCMEncryptMultiAAD(plaintext, key, nonce []byte, aad [][]byte) ([]byte, error) {
+	return GCMEncrypt(plaintext, key, nonce, encodeMultiAAD(aad))
+}
+
+// GCMDecryptMultiAAD reverses GCMEncryptMultiAAD
+func GCMDecryptMultiAAD(ciphertextWithTag, key, nonce []byte, aad [][]byte) ([]byte, error) {
+	return GCMDecrypt(ciphertextWithTag, key, nonce, encodeMultiAAD(aad))
+}
+
+// aadFlag collects repeated -aad flags in order
+type aadFlag []string
+
+func (a *aadFlag) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *aadFlag) Set(v string) error {
+	*a = append(*a, v)
+	return nil
+}
+
+// bytes returns the AAD to authenticate. No flags means no AAD, and a
+// single flag is passed through unchanged as before -aad could be repeated.
+func (a aadFlag) bytes() []byte {
+	if len(a) == 0 {
+		return nil
+	}
+	segments := make([][]byte, len(a))
+	for i, s := range a {
+		segments[i] = []byte(s)
+	}
+	return encodeMultiAAD(segments)
+}
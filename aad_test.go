@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGCMMultiAAD(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	plaintext := []byte("segmented aad")
+	aad := [][]byte{[]byte("tenant=42"), []byte("object=invoice.pdf")}
+
+	ct, err := GCMEncryptMultiAAD(plaintext, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncryptMultiAAD failed: %v", err)
+	}
+	decrypted, err := GCMDecryptMultiAAD(ct, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMDecryptMultiAAD failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	for _, bad := range [][][]byte{
+		{aad[1], aad[0]},
+		{[]byte("tenant=42object=invoice.pdf")},
+		{[]byte("tenant=4"), []byte("2object=invoice.pdf")},
+	} {
+		if _, err := GCMDecryptMultiAAD(ct, key, nonce, bad); err == nil {
+			t.Errorf("Expected failure for AAD segments %q", bad)
+		}
+	}
+
+	// One segment is the same as plain GCM AAD
+	single, err := GCMEncryptMultiAAD(plaintext, key, nonce, aad[:1])
+	if err != nil {
+		t.Fatalf("GCMEncryptMultiAAD failed: %v", err)
+	}
+	plain, err := GCMEncrypt(plaintext, key, nonce, aad[0])
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(single, plain) {
+		t.Errorf("Single-segment AAD doesn't match GCMEncrypt")
+	}
+
+	// The price of that: one segment holding the encoding of a longer list
+	// authenticates as that list
+	if _, err := GCMDecryptMultiAAD(ct, key, nonce, [][]byte{encodeMultiAAD(aad)}); err != nil {
+		t.Errorf("Expected the raw encoding as one segment to match, got %v", err)
+	}
+}
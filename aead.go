@@ -0,0 +1,57 @@
+package main
+
+import "crypto/cipher"
+
+// Cipher already has the BlockSize, Encrypt and Decrypt methods of
+// cipher.Block, so it can be passed to cipher.NewCBCEncrypter, cipher.NewCTR
+// and the like. NewGCM adds the other direction: this package's GCM behind
+// the standard cipher.AEAD interface.
+var _ cipher.Block = (*Cipher)(nil)
+
+// gcmAEAD is GCM under c with 12-byte nonces and 16-byte tags
+type gcmAEAD struct {
+	c *Cipher
+}
+
+// NewGCM returns c's GCM as a cipher.AEAD. As with crypto/cipher, Seal and
+// Open panic if the nonce isn't NonceSize() bytes, and Seal panics if the
+// plaintext is longer than GCM allows.
+func (c *Cipher) NewGCM() (cipher.AEAD, error) {
+	return gcmAEAD{c}, nil
+}
+
+func (g gcmAEAD) NonceSize() int {
+	return gcmNonceSize
+}
+
+func (g gcmAEAD) Overhead() int {
+	return gcmTagSize
+}
+
+// Seal appends ciphertext || tag to dst
+func (g gcmAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmNonceSize {
+		panic("GCM Seal: incorrect nonce length")
+	}
+	out, err := g.c.GCMSeal(plaintext, nonce, additionalData)
+	if err != nil {
+		panic("GCM Seal: " + err.Error())
+	}
+	dst = append(dst, out...)
+	putBuffer(out)
+	return dst
+}
+
+// Open verifies and decrypts ciphertext, appending the plaintext to dst
+func (g gcmAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmNonceSize {
+		panic("GCM Open: incorrect nonce length")
+	}
+	pt, err := g.c.GCMOpen(ciphertext, nonce, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, pt...)
+	putBuffer(pt)
+	return dst, nil
+}
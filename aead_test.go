@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
+
+func TestCipherAsStdlibBlock(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := bytes.Repeat([]byte("sixteen byte blk"), 4)
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	ref, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("aes.NewCipher failed: %v", err)
+	}
+	for name, mode := range map[string]func(b cipher.Block) cipher.BlockMode{
+		"CBC encrypter": func(b cipher.Block) cipher.BlockMode { return cipher.NewCBCEncrypter(b, iv) },
+		"CBC decrypter": func(b cipher.Block) cipher.BlockMode { return cipher.NewCBCDecrypter(b, iv) },
+	} {
+		got := make([]byte, len(plaintext))
+		want := make([]byte, len(plaintext))
+		mode(c).CryptBlocks(got, plaintext)
+		mode(ref).CryptBlocks(want, plaintext)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s over Cipher differs from crypto/aes", name)
+		}
+	}
+
+	got := make([]byte, len(plaintext)-3)
+	cipher.NewCTR(c, iv).XORKeyStream(got, plaintext[:len(got)])
+	want, err := CTREncrypt(plaintext[:len(got)], key, iv)
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("cipher.NewCTR over Cipher differs from CTREncrypt")
+	}
+}
+
+func TestCipherNewGCM(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("header")
+	plaintext := []byte("sealed through cipher.AEAD")
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	aead, err := c.NewGCM()
+	if err != nil {
+		t.Fatalf("NewGCM failed: %v", err)
+	}
+	if aead.NonceSize() != 12 || aead.Overhead() != 16 {
+		t.Errorf("NonceSize, Overhead = %d, %d, want 12, 16", aead.NonceSize(), aead.Overhead())
+	}
+
+	prefix := []byte("prefix:")
+	sealed := aead.Seal(append([]byte(nil), prefix...), nonce, plaintext, aad)
+	if !bytes.HasPrefix(sealed, prefix) {
+		t.Fatal("Seal didn't append to dst")
+	}
+	ref, _ := aes.NewCipher(key)
+	refGCM, _ := cipher.NewGCM(ref)
+	if want := refGCM.Seal(nil, nonce, plaintext, aad); !bytes.Equal(sealed[len(prefix):], want) {
+		t.Errorf("Seal = %x, crypto/cipher gives %x", sealed[len(prefix):], want)
+	}
+
+	opened, err := aead.Open(nil, nonce, sealed[len(prefix):], aad)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open = %q, want %q", opened, plaintext)
+	}
+	if _, err := aead.Open(nil, nonce, sealed[len(prefix):], []byte("other")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for the wrong AAD, got %v", err)
+	}
+
+	// crypto/cipher's own GCM can run over Cipher as a plain Block
+	stdGCM, err := cipher.NewGCM(c)
+	if err != nil {
+		t.Fatalf("cipher.NewGCM failed: %v", err)
+	}
+	if got := stdGCM.Seal(nil, nonce, plaintext, aad); !bytes.Equal(got, sealed[len(prefix):]) {
+		t.Error("cipher.NewGCM over Cipher differs from Cipher.NewGCM")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Seal to panic on a short nonce")
+		}
+	}()
+	aead.Seal(nil, nonce[:8], plaintext, nil)
+}
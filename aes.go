@@ -1,13 +1,22 @@
 package main
 
 import (
-	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
 	"fmt"
 )
 
 const Nb = 4
-const Nk = 4
-const Nr = 10
+
+// checkKeySize reports an error unless key is a supported AES key length
+func checkKeySize(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("AES requires a 16-, 24- or 32-byte key")
+}
 
 var sbox = [256]byte{
 	0x63, 0x7c, 0x77, 0x7b, 0xf2, 0x6b, 0x6f, 0xc5, 0x30, 0x01, 0x67, 0x2b, 0xfe, 0xd7, 0xab, 0x76,
@@ -59,31 +68,37 @@ func SubWord(word [4]byte) [4]byte {
 	return [4]byte{sbox[word[0]], sbox[word[1]], sbox[word[2]], sbox[word[3]]}
 }
 
-func KeyExpansion(key []byte) [Nb * (Nr + 1)][4]byte {
-	if len(key) != 16 {
-		panic("AES-128 requires a 16-byte key")
+// KeyExpansion expands a 16-, 24- or 32-byte key into Nb*(Nr+1) round key words,
+// where Nr is 10 for AES-128, 12 for AES-192 and 14 for AES-256
+func KeyExpansion(key []byte) [][4]byte {
+	if checkKeySize(key) != nil {
+		panic("AES requires a 16-, 24- or 32-byte key")
 	}
-	var w [Nb * (Nr + 1)][4]byte
-	for i := 0; i < Nk; i++ {
+	nk := len(key) / 4
+	nr := nk + 6
+	w := make([][4]byte, Nb*(nr+1))
+	for i := 0; i < nk; i++ {
 		w[i][0] = key[4*i]
 		w[i][1] = key[4*i+1]
 		w[i][2] = key[4*i+2]
 		w[i][3] = key[4*i+3]
 	}
-	for i := Nk; i < Nb*(Nr+1); i++ {
+	for i := nk; i < len(w); i++ {
 		temp := w[i-1]
-		if i%Nk == 0 {
+		if i%nk == 0 {
 			temp = SubWord(RotWord(temp))
-			temp[0] ^= Rcon[(i/Nk)-1]
+			temp[0] ^= Rcon[(i/nk)-1]
+		} else if nk > 6 && i%nk == 4 {
+			temp = SubWord(temp)
 		}
 		for j := 0; j < 4; j++ {
-			w[i][j] = w[i-Nk][j] ^ temp[j]
+			w[i][j] = w[i-nk][j] ^ temp[j]
 		}
 	}
 	return w
 }
 
-func RoundKeyMatrix(w [Nb*(Nr+1)][4]byte, round int) [4][4]byte {
+func RoundKeyMatrix(w [][4]byte, round int) [4][4]byte {
 	var key [4][4]byte
 	for c := 0; c < 4; c++ {
 		for r := 0; r < 4; r++ {
@@ -169,16 +184,17 @@ func EncryptBlock(input, key []byte) []byte {
 	if len(input) != 16 {
 		panic("EncryptBlock requires a 16-byte block")
 	}
-	if len(key) != 16 {
-		panic("AES-128 requires a 16-byte key")
+	if checkKeySize(key) != nil {
+		panic("AES requires a 16-, 24- or 32-byte key")
 	}
 	w := KeyExpansion(key)
+	nr := len(w)/Nb - 1
 	var state [4][4]byte
 	for i := 0; i < 16; i++ {
 		state[i%4][i/4] = input[i]
 	}
 	AddRoundKey(&state, RoundKeyMatrix(w, 0))
-	for round := 1; round < Nr; round++ {
+	for round := 1; round < nr; round++ {
 		SubBytes(&state)
 		ShiftRows(&state)
 		MixColumns(&state)
@@ -186,7 +202,7 @@ func EncryptBlock(input, key []byte) []byte {
 	}
 	SubBytes(&state)
 	ShiftRows(&state)
-	AddRoundKey(&state, RoundKeyMatrix(w, Nr))
+	AddRoundKey(&state, RoundKeyMatrix(w, nr))
 	out := make([]byte, 16)
 	for i := 0; i < 16; i++ {
 		out[i] = state[i%4][i/4]
@@ -198,16 +214,17 @@ func DecryptBlock(input, key []byte) []byte {
 	if len(input) != 16 {
 		panic("DecryptBlock requires a 16-byte block")
 	}
-	if len(key) != 16 {
-		panic("AES-128 requires a 16-byte key")
+	if checkKeySize(key) != nil {
+		panic("AES requires a 16-, 24- or 32-byte key")
 	}
 	w := KeyExpansion(key)
+	nr := len(w)/Nb - 1
 	var state [4][4]byte
 	for i := 0; i < 16; i++ {
 		state[i%4][i/4] = input[i]
 	}
-	AddRoundKey(&state, RoundKeyMatrix(w, Nr))
-	for round := Nr - 1; round > 0; round-- {
+	AddRoundKey(&state, RoundKeyMatrix(w, nr))
+	for round := nr - 1; round > 0; round-- {
 		InvShiftRows(&state)
 		InvSubBytes(&state)
 		AddRoundKey(&state, RoundKeyMatrix(w, round))
@@ -242,74 +259,64 @@ func PKCS7Pad(data []byte, blockSize int) []byte {
 	return out
 }
 
+// ErrInvalidPadding is returned for every malformed CBC plaintext, whether
+// the length, the padding value or the padding bytes are wrong, so callers
+// can't be used as a padding oracle
+var ErrInvalidPadding = errors.New("invalid padding")
+
 func PKCS7Unpad(data []byte, blockSize int) ([]byte, error) {
 	if len(data) == 0 || len(data)%blockSize != 0 {
-		return nil, fmt.Errorf("invalid padding length")
+		return nil, ErrInvalidPadding
 	}
 	padLen := int(data[len(data)-1])
-	if padLen == 0 || padLen > blockSize || padLen > len(data) {
-		return nil, fmt.Errorf("invalid padding value")
-	}
-	for i := 0; i < padLen; i++ {
-		if data[len(data)-1-i] != byte(padLen) {
-			return nil, fmt.Errorf("invalid padding bytes")
-		}
+	// Check the value and scan the whole last block so the time taken
+	// doesn't depend on which check fails or where the first bad byte is
+	good := subtle.ConstantTimeLessOrEq(1, padLen) & subtle.ConstantTimeLessOrEq(padLen, blockSize)
+	var diff byte
+	for i := 1; i <= blockSize; i++ {
+		inPad := byte(-subtle.ConstantTimeLessOrEq(i, padLen))
+		diff |= inPad & (data[len(data)-i] ^ byte(padLen))
+	}
+	good &= subtle.ConstantTimeByteEq(diff, 0)
+	if good != 1 {
+		return nil, ErrInvalidPadding
 	}
 	return data[:len(data)-padLen], nil
 }
 
 func CBCEncrypt(plaintext, key, iv []byte) ([]byte, error) {
-	if len(key) != 16 {
-		return nil, fmt.Errorf("AES-128 requires a 16-byte key")
-	}
-	if len(iv) != 16 {
-		return nil, fmt.Errorf("CBCEncrypt requires 16-byte IV")
-	}
-	pt := PKCS7Pad(plaintext, 16)
-	out := make([]byte, len(pt))
-	prev := iv
-	buf := make([]byte, 16)
-	for i := 0; i < len(pt); i += 16 {
-		xorBlocks(buf, pt[i:i+16], prev)
-		ct := EncryptBlock(buf, key)
-		copy(out[i:i+16], ct)
-		prev = ct
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
-	return out, nil
+	defer c.Close()
+	return c.CBCEncrypt(plaintext, iv)
 }
 
 func CBCDecrypt(ciphertext, key, iv []byte) ([]byte, error) {
-	if len(key) != 16 {
-		return nil, fmt.Errorf("AES-128 requires a 16-byte key")
-	}
-	if len(iv) != 16 {
-		return nil, fmt.Errorf("CBCDecrypt requires 16-byte IV")
-	}
-	if len(ciphertext)%16 != 0 {
-		return nil, fmt.Errorf("ciphertext not multiple of block size")
-	}
-	out := make([]byte, len(ciphertext))
-	prev := iv
-	for i := 0; i < len(ciphertext); i += 16 {
-		block := ciphertext[i : i+16]
-		ptBlock := DecryptBlock(block, key)
-		xorBlocks(out[i:i+16], ptBlock, prev)
-		prev = block
-	}
-	unpadded, err := PKCS7Unpad(out, 16)
+	c, err := NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	return unpadded, nil
+	defer c.Close()
+	return c.CBCDecrypt(ciphertext, iv)
 }
 
-func RandomIV() []byte {
-	iv := make([]byte, 16)
-	_, err := rand.Read(iv)
-	if err != nil {
-		panic(err)
+// CBCPlaintextMaxLen returns the largest plaintext a CBC file of blobLen
+// bytes (16-byte IV || ciphertext) can decrypt to: the ciphertext length
+// minus at least one byte of padding. A plaintext that is a whole number of
+// blocks gets a full block of padding, so the true length may be up to 15
+// bytes shorter. Returns 0 if blobLen can't be a CBC file.
+func CBCPlaintextMaxLen(blobLen int) int {
+	if blobLen < 32 || blobLen%16 != 0 {
+		return 0
 	}
-	return iv
+	return blobLen - 16 - 1
+}
+
+// RandomIV generates a random 16-byte IV for CBC or CTR
+func RandomIV() ([]byte, error) {
+	return randomBytes(16)
 }
 
 // GCM implementation
@@ -326,104 +333,121 @@ func incCounter(counter []byte) {
 
 // CTREncrypt performs CTR mode encryption/decryption (it's symmetric)
 func CTREncrypt(data, key, iv []byte) ([]byte, error) {
-	if len(key) != 16 {
-		return nil, fmt.Errorf("AES-128 requires a 16-byte key")
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.CTRXOR(data, iv)
+}
+
+// CTRKeystreamBytes returns the first n bytes of the CTR keystream for key and iv
+func CTRKeystreamBytes(key, iv []byte, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("keystream length must not be negative")
 	}
+	return CTREncrypt(make([]byte, n), key, iv)
+}
+
+// addCounter adds n to a 128-bit big-endian counter, wrapping like incCounter
+func addCounter(counter []byte, n uint64) {
+	lo := binary.BigEndian.Uint64(counter[8:])
+	sum := lo + n
+	binary.BigEndian.PutUint64(counter[8:], sum)
+	if sum < lo {
+		hi := binary.BigEndian.Uint64(counter[:8])
+		binary.BigEndian.PutUint64(counter[:8], hi+1)
+	}
+}
+
+// CTREncryptSkip is CTREncrypt starting skipBlocks blocks into the keystream,
+// for protocols that reserve the first keystream blocks for other uses
+func CTREncryptSkip(data, key, iv []byte, skipBlocks int) ([]byte, error) {
 	if len(iv) != 16 {
 		return nil, fmt.Errorf("CTR mode requires 16-byte IV/counter")
 	}
-	
-	out := make([]byte, len(data))
+	if skipBlocks < 0 {
+		return nil, fmt.Errorf("skip must not be negative")
+	}
 	counter := make([]byte, 16)
 	copy(counter, iv)
-	
-	for i := 0; i < len(data); i += 16 {
-		keyStream := EncryptBlock(counter, key)
-		blockSize := 16
-		if i+blockSize > len(data) {
-			blockSize = len(data) - i
-		}
-		for j := 0; j < blockSize; j++ {
-			out[i+j] = data[i+j] ^ keyStream[j]
-		}
-		incCounter(counter)
-	}
-	return out, nil
+	addCounter(counter, uint64(skipBlocks))
+	return CTREncrypt(data, key, counter)
 }
 
 // gfMul multiplies two elements in GF(2^128) used in GHASH
 func gfMul(x, y []byte) []byte {
-	result := make([]byte, 16)
-	v := make([]byte, 16)
-	copy(v, y)
-	
+	var z [16]byte
+	copy(z[:], x)
+	gfMulInto(&z, y)
+	return z[:]
+}
+
+// gfMulInto sets x = x * y without allocating.
+//
+// It is constant-time: the multiply step and the reduction are both
+// applied through masks built from a single bit, never through a branch,
+// so the running time doesn't depend on x or y (which include the secret
+// GHASH key).
+func gfMulInto(x *[16]byte, y []byte) {
+	var result, v [16]byte
+	copy(v[:], y)
+
 	for i := 0; i < 128; i++ {
-		byteIdx := i / 8
-		bitIdx := 7 - (i % 8)
-		if (x[byteIdx] & (1 << bitIdx)) != 0 {
-			for j := 0; j < 16; j++ {
-				result[j] ^= v[j]
-			}
+		// All ones if bit i of x is set, otherwise zero
+		mask := -((x[i/8] >> (7 - i%8)) & 1)
+		for j := 0; j < 16; j++ {
+			result[j] ^= v[j] & mask
 		}
-		
-		// Check if LSB is set
+
+		// Right shift v by 1, then XOR in R = 0xE1 || 0^120 if a bit fell off
 		lsb := v[15] & 1
-		// Right shift v by 1
 		for j := 15; j > 0; j-- {
-			v[j] = (v[j] >> 1) | ((v[j-1] & 1) << 7)
+			v[j] = (v[j] >> 1) | (v[j-1] << 7)
 		}
 		v[0] >>= 1
-		
-		// If LSB was set, XOR with R = 0xE1000000000000000000000000000000
-		if lsb != 0 {
-			v[0] ^= 0xE1
-		}
+		v[0] ^= 0xE1 & -lsb
 	}
-	return result
+	*x = result
 }
 
-// ghash computes GHASH authentication tag
-func ghash(h, aad, ciphertext []byte) []byte {
-	// Initialize tag
-	tag := make([]byte, 16)
-	
-	// Process AAD
-	aadLen := len(aad)
-	for i := 0; i < aadLen; i += 16 {
-		block := make([]byte, 16)
-		copy(block, aad[i:min(i+16, aadLen)])
-		for j := 0; j < 16; j++ {
-			tag[j] ^= block[j]
-		}
-		tag = gfMul(tag, h)
-	}
-	
-	// Process ciphertext
-	ctLen := len(ciphertext)
-	for i := 0; i < ctLen; i += 16 {
-		block := make([]byte, 16)
-		copy(block, ciphertext[i:min(i+16, ctLen)])
-		for j := 0; j < 16; j++ {
-			tag[j] ^= block[j]
-		}
-		tag = gfMul(tag, h)
-	}
-	
-	// Process lengths
+// gcmLengthBlock is the final GHASH block: len(A) || len(C), in bits, as
+// big-endian 64-bit integers
+func gcmLengthBlock(aadLen, ctLen int) []byte {
 	lenBlock := make([]byte, 16)
-	// AAD length in bits (big-endian)
-	aadBits := uint64(aadLen * 8)
-	ctBits := uint64(ctLen * 8)
-	for i := 0; i < 8; i++ {
-		lenBlock[7-i] = byte(aadBits >> (i * 8))
-		lenBlock[15-i] = byte(ctBits >> (i * 8))
-	}
+	binary.BigEndian.PutUint64(lenBlock, uint64(aadLen)*8)
+	binary.BigEndian.PutUint64(lenBlock[8:], uint64(ctLen)*8)
+	return lenBlock
+}
+
+// ghashWithLengths computes GHASH over aad and ciphertext, finishing with
+// lenBlock (normally gcmLengthBlock)
+func ghashWithLengths(h, aad, ciphertext, lenBlock []byte) []byte {
+	var tag [16]byte
+	ghashInto(&tag, h, aad, ciphertext, lenBlock)
+	return tag[:]
+}
+
+// ghashInto is ghashWithLengths writing into tag, without allocating
+func ghashInto(tag *[16]byte, h, aad, ciphertext, lenBlock []byte) {
+	*tag = [16]byte{}
+	ghashUpdate(tag, h, aad)
+	ghashUpdate(tag, h, ciphertext)
 	for j := 0; j < 16; j++ {
 		tag[j] ^= lenBlock[j]
 	}
-	tag = gfMul(tag, h)
-	
-	return tag
+	gfMulInto(tag, h)
+}
+
+// ghashUpdate absorbs data into tag, zero-padding the last block
+func ghashUpdate(tag *[16]byte, h, data []byte) {
+	for i := 0; i < len(data); i += 16 {
+		n := min(16, len(data)-i)
+		for j := 0; j < n; j++ {
+			tag[j] ^= data[i+j]
+		}
+		gfMulInto(tag, h)
+	}
 }
 
 func min(a, b int) int {
@@ -433,115 +457,199 @@ func min(a, b int) int {
 	return b
 }
 
-// GCMEncrypt encrypts data using AES-GCM mode
-// Returns: ciphertext || tag (16 bytes)
-func GCMEncrypt(plaintext, key, nonce, aad []byte) ([]byte, error) {
-	if len(key) != 16 {
-		return nil, fmt.Errorf("AES-128 requires a 16-byte key")
-	}
-	if len(nonce) != 12 {
-		return nil, fmt.Errorf("GCM requires a 12-byte nonce")
-	}
-	
-	// Generate H = E(K, 0^128)
-	h := EncryptBlock(make([]byte, 16), key)
-	
-	// Prepare counter for CTR mode
-	// GCM uses: nonce || 0^31 || 1
-	counter := make([]byte, 16)
-	copy(counter, nonce)
-	counter[15] = 1
-	
-	// Prepare initial counter for tag generation
-	j0 := make([]byte, 16)
-	copy(j0, nonce)
-	// j0 ends with 0x00000001
-	
-	// Increment counter for encryption
-	incCounter(counter)
-	
-	// Encrypt plaintext using CTR mode
-	ciphertext, err := CTREncrypt(plaintext, key, counter)
-	if err != nil {
-		return nil, err
+// inc32 increments the rightmost 32 bits of a GCM counter block
+func inc32(counter []byte) {
+	c := binary.BigEndian.Uint32(counter[12:16])
+	binary.BigEndian.PutUint32(counter[12:16], c+1)
+}
+
+// gcmCTR XORs data with the GCM keystream starting at counter block icb and
+// writes the result to out, which must be at least len(data) bytes
+func (c *Cipher) gcmCTR(out, data, icb []byte) {
+	var counter, keyStream [16]byte
+	copy(counter[:], icb)
+	for i := 0; i < len(data); i += 16 {
+		c.Encrypt(keyStream[:], counter[:])
+		n := min(16, len(data)-i)
+		for j := 0; j < n; j++ {
+			out[i+j] = data[i+j] ^ keyStream[j]
+		}
+		inc32(counter[:])
 	}
-	
-	// Calculate GHASH
-	tag := ghash(h, aad, ciphertext)
-	
-	// Encrypt tag with j0
-	j0[15] = 1
-	encJ0 := EncryptBlock(j0, key)
+}
+
+// gcmTag computes the GCM tag: GHASH(H, A, C) encrypted with j0
+func (c *Cipher) gcmTag(j0, aad, ciphertext []byte) []byte {
+	return c.gcmTagWithLengths(j0, aad, ciphertext, gcmLengthBlock(len(aad), len(ciphertext)))
+}
+
+// gcmTagWithLengths is gcmTag with the caller's GHASH length block
+func (c *Cipher) gcmTagWithLengths(j0, aad, ciphertext, lenBlock []byte) []byte {
+	var tag [16]byte
+	c.gcmTagInto(&tag, j0, aad, ciphertext, lenBlock)
+	return tag[:]
+}
+
+// gcmTagInto is gcmTagWithLengths writing into tag, so verifying a tag
+// needn't allocate. GHASH runs through the per-key table, which is faster
+// than gfMul and, reading the table with masks, just as constant-time.
+func (c *Cipher) gcmTagInto(tag *[16]byte, j0, aad, ciphertext, lenBlock []byte) {
+	c.ghashTable().ghashInto(tag, aad, ciphertext, lenBlock)
+	var encJ0 [16]byte
+	c.Encrypt(encJ0[:], j0)
 	for i := 0; i < 16; i++ {
 		tag[i] ^= encJ0[i]
 	}
-	
-	// Append tag to ciphertext
-	result := append(ciphertext, tag...)
-	return result, nil
 }
 
-// GCMDecrypt decrypts data using AES-GCM mode and verifies the tag
-func GCMDecrypt(ciphertextWithTag, key, nonce, aad []byte) ([]byte, error) {
-	if len(key) != 16 {
-		return nil, fmt.Errorf("AES-128 requires a 16-byte key")
+// GCM input limits from NIST SP 800-38D: plaintext up to 2^39-256 bits,
+// AAD up to 2^64-1 bits
+const (
+	gcmMaxPlaintextLen = 1<<36 - 32
+	gcmMaxAADLen       = 1<<61 - 1
+)
+
+var (
+	// ErrAuthFailed is wrapped by every tag, MAC and signature mismatch,
+	// whatever the mode
+	ErrAuthFailed       = errors.New("authentication failed")
+	ErrPlaintextTooLong = errors.New("GCM plaintext exceeds 2^39-256 bits")
+	ErrAADTooLong       = errors.New("GCM additional data exceeds 2^64-1 bits")
+)
+
+// checkPlaintextLength rejects plaintexts the 32-bit GCM counter can't cover
+func checkPlaintextLength(n uint64) error {
+	if n > gcmMaxPlaintextLen {
+		return ErrPlaintextTooLong
+	}
+	return nil
+}
+
+// checkAADLength rejects AAD whose bit length doesn't fit the length block
+func checkAADLength(n uint64) error {
+	if n > gcmMaxAADLen {
+		return ErrAADTooLong
+	}
+	return nil
+}
+
+// gcmSeal encrypts and authenticates plaintext under the pre-counter block j0
+// Returns: ciphertext || tag (16 bytes)
+func (c *Cipher) gcmSeal(plaintext, j0, aad []byte) ([]byte, error) {
+	if err := checkPlaintextLength(uint64(len(plaintext))); err != nil {
+		return nil, err
+	}
+	if err := checkAADLength(uint64(len(aad))); err != nil {
+		return nil, err
+	}
+	// Data encryption starts at inc32(j0)
+	counter := make([]byte, 16)
+	copy(counter, j0)
+	inc32(counter)
+	out := getBuffer(len(plaintext) + 16)
+	ciphertext := out[:len(plaintext)]
+	c.gcmCTR(ciphertext, plaintext, counter)
+	copy(out[len(plaintext):], c.gcmTag(j0, aad, ciphertext))
+	return out, nil
+}
+
+// gcmVerify checks the tag of ciphertextWithTag under j0 without decrypting
+func (c *Cipher) gcmVerify(ciphertextWithTag, j0, aad []byte) error {
+	return c.gcmVerifyTag(ciphertextWithTag, j0, aad, 16)
+}
+
+// gcmVerifyTag is gcmVerify for a tag truncated to tagLen bytes, which is
+// compared against the same prefix of the full tag
+func (c *Cipher) gcmVerifyTag(ciphertextWithTag, j0, aad []byte, tagLen int) error {
+	if len(ciphertextWithTag) < tagLen {
+		return fmt.Errorf("ciphertext too short (must include %d-byte tag)", tagLen)
 	}
-	if len(nonce) != 12 {
-		return nil, fmt.Errorf("GCM requires a 12-byte nonce")
+	if err := checkPlaintextLength(uint64(len(ciphertextWithTag) - tagLen)); err != nil {
+		return err
 	}
-	if len(ciphertextWithTag) < 16 {
-		return nil, fmt.Errorf("ciphertext too short (must include 16-byte tag)")
+	if err := checkAADLength(uint64(len(aad))); err != nil {
+		return err
 	}
-	
+
 	// Split ciphertext and tag
-	tagStart := len(ciphertextWithTag) - 16
+	tagStart := len(ciphertextWithTag) - tagLen
 	ciphertext := ciphertextWithTag[:tagStart]
 	receivedTag := ciphertextWithTag[tagStart:]
-	
-	// Generate H = E(K, 0^128)
-	h := EncryptBlock(make([]byte, 16), key)
-	
-	// Calculate expected tag
-	expectedTag := ghash(h, aad, ciphertext)
-	
-	// Encrypt tag with j0
-	j0 := make([]byte, 16)
-	copy(j0, nonce)
-	j0[15] = 1
-	encJ0 := EncryptBlock(j0, key)
-	for i := 0; i < 16; i++ {
-		expectedTag[i] ^= encJ0[i]
-	}
-	
-	// Constant-time comparison of tags
-	var tagMatch byte = 0
-	for i := 0; i < 16; i++ {
-		tagMatch |= receivedTag[i] ^ expectedTag[i]
+
+	var expectedTag [16]byte
+	c.gcmTagInto(&expectedTag, j0, aad, ciphertext, gcmLengthBlock(len(aad), len(ciphertext)))
+
+	if !tagsEqual(receivedTag, expectedTag[:tagLen]) {
+		return fmt.Errorf("%w: tag mismatch", ErrAuthFailed)
 	}
-	if tagMatch != 0 {
-		return nil, fmt.Errorf("authentication failed: tag mismatch")
+	return nil
+}
+
+// tagsEqual compares two tags in constant time. Every tag and MAC check in
+// the package goes through here (or hmac.Equal), never bytes.Equal, whose
+// early exit on the first differing byte would let an attacker forge a
+// tag one byte at a time. Only the length, which is public, can end the
+// comparison early.
+func tagsEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// gcmOpen verifies the tag and decrypts ciphertextWithTag under j0
+func (c *Cipher) gcmOpen(ciphertextWithTag, j0, aad []byte) ([]byte, error) {
+	return c.gcmOpenTag(ciphertextWithTag, j0, aad, 16)
+}
+
+// gcmOpenTag is gcmOpen for a tag truncated to tagLen bytes
+func (c *Cipher) gcmOpenTag(ciphertextWithTag, j0, aad []byte, tagLen int) ([]byte, error) {
+	if err := c.gcmVerifyTag(ciphertextWithTag, j0, aad, tagLen); err != nil {
+		return nil, err
 	}
-	
-	// Decrypt ciphertext using CTR mode
 	counter := make([]byte, 16)
-	copy(counter, nonce)
-	counter[15] = 1
-	incCounter(counter)
-	
-	plaintext, err := CTREncrypt(ciphertext, key, counter)
+	copy(counter, j0)
+	inc32(counter)
+	ciphertext := ciphertextWithTag[:len(ciphertextWithTag)-tagLen]
+	out := getBuffer(len(ciphertext))
+	c.gcmCTR(out, ciphertext, counter)
+	return out, nil
+}
+
+// GCMEncrypt encrypts data using AES-GCM mode. The nonce should be 12
+// bytes; other non-empty lengths are accepted for interoperability.
+// Returns: ciphertext || tag (16 bytes)
+func GCMEncrypt(plaintext, key, nonce, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	
-	return plaintext, nil
+	defer c.Close()
+	return c.GCMSeal(plaintext, nonce, aad)
 }
 
-// RandomNonce generates a random 12-byte nonce for GCM
-func RandomNonce() []byte {
-	nonce := make([]byte, 12)
-	_, err := rand.Read(nonce)
+// GCMDecrypt decrypts data using AES-GCM mode and verifies the tag
+func GCMDecrypt(ciphertextWithTag, key, nonce, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.GCMOpen(ciphertextWithTag, nonce, aad)
+}
+
+// GCMVerify checks the authentication tag without decrypting the ciphertext
+func GCMVerify(ciphertextWithTag, key, nonce, aad []byte) error {
+	c, err := NewCipher(key)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	j0, err := c.gcmJ0(nonce)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return nonce
+	return c.gcmVerify(ciphertextWithTag, j0, aad)
+}
+
+// RandomNonce generates a random 12-byte nonce for GCM
+func RandomNonce() ([]byte, error) {
+	return randomBytes(12)
 }
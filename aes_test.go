@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -131,7 +134,7 @@ func TestGCMInvalidInputs(t *testing.T) {
 	validKey := []byte("1234567890123456")
 	invalidKey := []byte("short")
 	validNonce := []byte("123456789012")
-	invalidNonce := []byte("short")
+	invalidNonce := []byte{}
 	plaintext := []byte("test")
 	aad := []byte("")
 
@@ -155,6 +158,74 @@ func TestGCMInvalidInputs(t *testing.T) {
 	}
 }
 
+func TestGCMNon96BitNonces(t *testing.T) {
+	// GCM spec test cases 5 (64-bit IV) and 6 (480-bit IV), where J0 is
+	// derived through GHASH
+	key := mustHex(t, "feffe9928665731c6d6a8f9467308308")
+	plaintext := mustHex(t, "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39")
+	aad := mustHex(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	for _, tc := range []struct{ nonce, ct, tag string }{
+		{
+			"cafebabefacedbad",
+			"61353b4c2806934a777ff51fa22a4755699b2a714fcdc6f83766e5f97b6c742373806900e49f24b22b097544d4896b424989b5e1ebac0f07c23f4598",
+			"3612d2e79e3b0785561be14aaca2fccb",
+		},
+		{
+			"9313225df88406e555909c5aff5269aa6a7a9538534f7da1e4c303d2a318a728c3c0c95156809539fcf0e2429a6b525416aedbf5a0de6a57a637b39b",
+			"8ce24998625615b603a033aca13fb894be9112a5c3a211a8ba262a3cca7e2ca701e4a9a4fba43c90ccdcb281d48c7c6fd62875d2aca417034c34aee5",
+			"619cc5aefffe0bfa462af43c1699d050",
+		},
+	} {
+		nonce := mustHex(t, tc.nonce)
+		want := append(mustHex(t, tc.ct), mustHex(t, tc.tag)...)
+		got, err := GCMEncrypt(plaintext, key, nonce, aad)
+		if err != nil {
+			t.Fatalf("%d-byte nonce: GCMEncrypt failed: %v", len(nonce), err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d-byte nonce: got %x, want %x", len(nonce), got, want)
+		}
+		pt, err := GCMDecrypt(want, key, nonce, aad)
+		if err != nil {
+			t.Fatalf("%d-byte nonce: GCMDecrypt failed: %v", len(nonce), err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("%d-byte nonce: decrypted text doesn't match", len(nonce))
+		}
+	}
+}
+
+func TestTagsEqual(t *testing.T) {
+	tag := []byte("0123456789abcdef")
+	if !tagsEqual(tag, append([]byte(nil), tag...)) {
+		t.Error("Equal tags rejected")
+	}
+	for i := range tag {
+		for bit := 0; bit < 8; bit++ {
+			other := append([]byte(nil), tag...)
+			other[i] ^= 1 << bit
+			if tagsEqual(tag, other) {
+				t.Fatalf("Tags differing in bit %d of byte %d accepted", bit, i)
+			}
+		}
+	}
+	if tagsEqual(tag, tag[:15]) || tagsEqual(tag[:15], tag) {
+		t.Error("Tags of different lengths accepted")
+	}
+
+	// The same through GCMDecrypt: only the last byte of the tag is wrong
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	ct, err := GCMEncrypt([]byte("last byte"), key, nonce, nil)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	ct[len(ct)-1] ^= 0x01
+	if _, err := GCMDecrypt(ct, key, nonce, nil); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for a tag differing in its last byte, got %v", err)
+	}
+}
+
 func TestCTRMode(t *testing.T) {
 	key := []byte("1234567890123456")
 	iv := []byte("1234567890123456")
@@ -195,6 +266,22 @@ func TestGFMul(t *testing.T) {
 	}
 }
 
+func TestGFMulKnownAnswers(t *testing.T) {
+	// Products from the bit-by-bit gfMul before it was made constant-time.
+	// The second is X1 = C1 * H from GCM test case 2.
+	for _, tc := range []struct{ x, y, want string }{
+		{"0102030405060708090a0b0c0d0e0f10", "1112131415161718191a1b1c1d1e1f20", "731efbe345db4ab9f281fe7c40444bca"},
+		{"0388dace60b6a392f328c2b971b2fe78", "66e94bd4ef8a2c3b884cfa59ca342b2e", "5e2ec746917062882c85b0685353deb7"},
+		{"80000000000000000000000000000000", "ffffffffffffffffffffffffffffffff", "ffffffffffffffffffffffffffffffff"},
+		{"ffffffffffffffffffffffffffffffff", "ffffffffffffffffffffffffffffffff", "f402aaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+		{"00000000000000000000000000000001", "00000000000000000000000000000001", "e6080000000000000000000000000003"},
+	} {
+		if got := gfMul(mustHex(t, tc.x), mustHex(t, tc.y)); !bytes.Equal(got, mustHex(t, tc.want)) {
+			t.Errorf("gfMul(%s, %s) = %x, want %s", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
 func TestCBCEncryptDecrypt(t *testing.T) {
 	key := []byte("1234567890123456")
 	iv := []byte("1234567890123456")
@@ -234,6 +321,374 @@ func TestPKCS7Padding(t *testing.T) {
 	}
 }
 
+func TestPKCS7UnpadCases(t *testing.T) {
+	block := func(prefix string, pad ...byte) []byte {
+		return append([]byte(prefix), pad...)
+	}
+	full := bytes.Repeat([]byte{16}, 16)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"one byte", block("fifteen bytes!!", 1), "fifteen bytes!!"},
+		{"four bytes", block("twelve bytes", 4, 4, 4, 4), "twelve bytes"},
+		{"full block", append([]byte("sixteen bytes!!!"), full...), "sixteen bytes!!!"},
+	} {
+		got, err := PKCS7Unpad(tc.data, 16)
+		if err != nil {
+			t.Errorf("%s: PKCS7Unpad failed: %v", tc.name, err)
+		} else if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a whole block", block("short", 1)},
+		{"zero pad value", block("fifteen bytes!!", 0)},
+		{"pad value past the block", block("fifteen bytes!!", 17)},
+		{"pad value 255", block("fifteen bytes!!", 255)},
+		{"wrong byte next to the length", block("twelve bytes", 4, 4, 3, 4)},
+		{"wrong byte at the far end", block("twelve bytes", 5, 4, 4, 4)},
+		{"full block with one bad byte", append([]byte("sixteen bytes!!!"), append([]byte{15}, full[1:]...)...)},
+	} {
+		if _, err := PKCS7Unpad(tc.data, 16); err != ErrInvalidPadding {
+			t.Errorf("%s: expected ErrInvalidPadding, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestGCMLengthLimits(t *testing.T) {
+	// Lengths are checked as plain numbers so the over-limit case can be
+	// exercised without allocating
+	if err := checkAADLength(1<<61 - 1); err != nil {
+		t.Errorf("AAD at the limit rejected: %v", err)
+	}
+	if err := checkAADLength(1 << 61); err != ErrAADTooLong {
+		t.Errorf("Expected ErrAADTooLong, got %v", err)
+	}
+	if err := checkPlaintextLength(1<<36 - 32); err != nil {
+		t.Errorf("Plaintext at the limit rejected: %v", err)
+	}
+	if err := checkPlaintextLength(1<<36 - 31); err != ErrPlaintextTooLong {
+		t.Errorf("Expected ErrPlaintextTooLong, got %v", err)
+	}
+}
+
+func TestGCMVerify(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("aad")
+	ciphertext, err := GCMEncrypt([]byte("verify without decrypting"), key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if err := GCMVerify(ciphertext, key, nonce, aad); err != nil {
+		t.Errorf("GCMVerify failed: %v", err)
+	}
+	ciphertext[0] ^= 0x01
+	if err := GCMVerify(ciphertext, key, nonce, aad); err == nil {
+		t.Error("Expected verification failure with tampered ciphertext")
+	}
+}
+
+func TestCTREncryptSkip(t *testing.T) {
+	key := []byte("1234567890123456")
+	data := []byte("protocol payload after reserved blocks")
+	for _, iv := range [][]byte{
+		[]byte("1234567890123456"),
+		// Low 64 bits about to wrap, so the skip has to carry
+		{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+	} {
+		for _, skip := range []int{0, 1, 3} {
+			ks, err := CTRKeystreamBytes(key, iv, skip*16+len(data))
+			if err != nil {
+				t.Fatalf("CTRKeystreamBytes failed: %v", err)
+			}
+			ct, err := CTREncryptSkip(data, key, iv, skip)
+			if err != nil {
+				t.Fatalf("CTREncryptSkip failed: %v", err)
+			}
+			for i := range data {
+				if ct[i] != data[i]^ks[skip*16+i] {
+					t.Fatalf("Skip %d: byte %d doesn't match the sliced keystream", skip, i)
+				}
+			}
+		}
+	}
+	if _, err := CTREncryptSkip(data, key, []byte("1234567890123456"), -1); err == nil {
+		t.Error("Expected error for negative skip")
+	}
+}
+
+// TestPaddingOracleResistance tampers with a CBC ciphertext the way a
+// padding oracle attack does and checks that every rejection is the same
+// error, whatever the cause
+func TestPaddingOracleResistance(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	ct, err := CBCEncrypt([]byte("attack at dawn, bring snacks"), key, iv)
+	if err != nil {
+		t.Fatalf("CBCEncrypt failed: %v", err)
+	}
+
+	check := func(what string, ct, iv []byte) {
+		t.Helper()
+		_, err := CBCDecrypt(ct, key, iv)
+		if err != nil && (err != ErrInvalidPadding || err.Error() != ErrInvalidPadding.Error()) {
+			t.Fatalf("%s: got distinguishable error %q", what, err)
+		}
+	}
+
+	// Every value of every byte of the block before the last, which is
+	// what shifts the padding bytes
+	prev := len(ct) - 32
+	for pos := prev; pos < prev+16; pos++ {
+		for v := 0; v < 256; v++ {
+			mod := append([]byte(nil), ct...)
+			mod[pos] = byte(v)
+			check(fmt.Sprintf("byte %d = %#x", pos, v), mod, iv)
+		}
+	}
+	// Same attack against the first block through the IV
+	for v := 0; v < 256; v++ {
+		modIV := append([]byte(nil), iv...)
+		modIV[15] = byte(v)
+		check(fmt.Sprintf("iv byte 15 = %#x", v), ct[:16], modIV)
+	}
+	// Lengths that aren't whole blocks fail the same way
+	for _, n := range []int{0, 1, 15, 17, len(ct) - 1} {
+		if _, err := CBCDecrypt(ct[:n], key, iv); err != ErrInvalidPadding {
+			t.Errorf("%d-byte ciphertext: expected ErrInvalidPadding, got %v", n, err)
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex in test vector: %v", err)
+	}
+	return b
+}
+
+func TestAES256BlockVector(t *testing.T) {
+	// FIPS-197 appendix C.3
+	key := mustHex(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	pt := mustHex(t, "00112233445566778899aabbccddeeff")
+	want := mustHex(t, "8ea2b7ca516745bfeafc49904b496089")
+	if ct := EncryptBlock(pt, key); !bytes.Equal(ct, want) {
+		t.Errorf("EncryptBlock = %x, want %x", ct, want)
+	}
+	if got := DecryptBlock(want, key); !bytes.Equal(got, pt) {
+		t.Errorf("DecryptBlock = %x, want %x", got, pt)
+	}
+}
+
+// SP 800-38A appendix F, shared by the CBC and CTR AES-256 vectors
+const sp80038aPlaintext = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+	"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+func TestAES256CBCVector(t *testing.T) {
+	// SP 800-38A F.2.5 CBC-AES256.Encrypt. CBCEncrypt appends a block of
+	// PKCS#7 padding, so only the first four blocks are compared.
+	key := mustHex(t, "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4")
+	iv := mustHex(t, "000102030405060708090a0b0c0d0e0f")
+	pt := mustHex(t, sp80038aPlaintext)
+	want := mustHex(t, "f58c4c04d6e5f1ba779eabfb5f7bfbd69cfc4e967edb808d679f777bc6702c7d"+
+		"39f23369a9d9bacfa530e26304231461b2eb05e2c39be9fcda6c19078c6a9d1b")
+	ct, err := CBCEncrypt(pt, key, iv)
+	if err != nil {
+		t.Fatalf("CBCEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(ct[:len(want)], want) {
+		t.Errorf("CBCEncrypt = %x, want %x...", ct, want)
+	}
+	got, err := CBCDecrypt(ct, key, iv)
+	if err != nil {
+		t.Fatalf("CBCDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, pt) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestAES256CTRVector(t *testing.T) {
+	// SP 800-38A F.5.5 CTR-AES256.Encrypt
+	key := mustHex(t, "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4")
+	iv := mustHex(t, "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	pt := mustHex(t, sp80038aPlaintext)
+	want := mustHex(t, "601ec313775789a5b7a7f504bbf3d228f443e3ca4d62b59aca84e990cacaf5c5"+
+		"2b0930daa23de94ce87017ba2d84988ddfc9c58db67aada613c2dd08457941a6")
+	ct, err := CTREncrypt(pt, key, iv)
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+	if !bytes.Equal(ct, want) {
+		t.Errorf("CTREncrypt = %x, want %x", ct, want)
+	}
+}
+
+func TestAES256GCMVector(t *testing.T) {
+	// GCM spec (McGrew and Viega) test case 16
+	key := mustHex(t, "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308")
+	nonce := mustHex(t, "cafebabefacedbaddecaf888")
+	aad := mustHex(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	pt := mustHex(t, "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72"+
+		"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39")
+	want := mustHex(t, "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa"+
+		"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662"+
+		"76fc6ece0f4e1768cddf8853bb2d551b")
+	ct, err := GCMEncrypt(pt, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(ct, want) {
+		t.Errorf("GCMEncrypt = %x, want %x", ct, want)
+	}
+	got, err := GCMDecrypt(want, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, pt) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestAES192BlockVector(t *testing.T) {
+	// FIPS-197 appendix C.2
+	key := mustHex(t, "000102030405060708090a0b0c0d0e0f1011121314151617")
+	pt := mustHex(t, "00112233445566778899aabbccddeeff")
+	want := mustHex(t, "dda97ca4864cdfe06eaf70a0ec0d7191")
+	if ct := EncryptBlock(pt, key); !bytes.Equal(ct, want) {
+		t.Errorf("EncryptBlock = %x, want %x", ct, want)
+	}
+	if got := DecryptBlock(want, key); !bytes.Equal(got, pt) {
+		t.Errorf("DecryptBlock = %x, want %x", got, pt)
+	}
+}
+
+func TestAES192GCMVector(t *testing.T) {
+	// GCM spec (McGrew and Viega) test case 10
+	key := mustHex(t, "feffe9928665731c6d6a8f9467308308feffe9928665731c")
+	nonce := mustHex(t, "cafebabefacedbaddecaf888")
+	aad := mustHex(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	pt := mustHex(t, "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72"+
+		"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39")
+	want := mustHex(t, "3980ca0b3c00e841eb06fac4872a2757859e1ceaa6efd984628593b40ca1e19c"+
+		"7d773d00c144c525ac619d18c84a3f4718e2448b2fe324d9ccda2710"+
+		"2519498e80f1478f37ba55bd6d27618c")
+	ct, err := GCMEncrypt(pt, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(ct, want) {
+		t.Errorf("GCMEncrypt = %x, want %x", ct, want)
+	}
+	got, err := GCMDecrypt(want, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, pt) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestAES192CBCEncryptDecrypt(t *testing.T) {
+	key := []byte("123456789012345678901234")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := []byte("AES-192 needs twelve rounds")
+	ct, err := CBCEncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("CBCEncrypt failed: %v", err)
+	}
+	got, err := CBCDecrypt(ct, key, iv)
+	if err != nil {
+		t.Fatalf("CBCDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestUnsupportedKeySizes(t *testing.T) {
+	for _, n := range []int{0, 8, 15, 17, 20, 33, 64} {
+		if _, err := GCMEncrypt(nil, make([]byte, n), []byte("123456789012"), nil); err == nil {
+			t.Errorf("Expected error for %d-byte key", n)
+		}
+		if _, err := CBCEncrypt(nil, make([]byte, n), []byte("abcdefghijklmnop")); err == nil {
+			t.Errorf("Expected error for %d-byte key", n)
+		}
+	}
+}
+
+func TestCBCPlaintextMaxLen(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	for _, n := range []int{0, 1, 15, 16, 17, 31, 32, 100} {
+		ct, err := CBCEncrypt(make([]byte, n), key, iv)
+		if err != nil {
+			t.Fatalf("CBCEncrypt failed: %v", err)
+		}
+		blobLen := len(iv) + len(ct)
+		max := CBCPlaintextMaxLen(blobLen)
+		if n > max {
+			t.Errorf("%d-byte plaintext: max %d for %d-byte file is too small", n, max, blobLen)
+		}
+		// A whole number of blocks carries a full padding block, so the
+		// bound is 15 bytes loose; otherwise it's within one block
+		if n%16 == 0 && max-n != 15 {
+			t.Errorf("%d-byte plaintext: max %d, want %d", n, max, n+15)
+		}
+		if max-n > 15 {
+			t.Errorf("%d-byte plaintext: max %d is more than a block too large", n, max)
+		}
+	}
+	for _, blobLen := range []int{-1, 0, 16, 20, 33} {
+		if max := CBCPlaintextMaxLen(blobLen); max != 0 {
+			t.Errorf("CBCPlaintextMaxLen(%d) = %d, want 0", blobLen, max)
+		}
+	}
+}
+
+func TestGCMVerifyTagNoAllocs(t *testing.T) {
+	c, err := NewCipher([]byte("1234567890123456"))
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	j0, _ := c.gcmJ0([]byte("123456789012"))
+	aad := []byte("header")
+	ct, err := c.gcmSeal(bytes.Repeat([]byte("x"), 100), j0, aad)
+	if err != nil {
+		t.Fatalf("gcmSeal failed: %v", err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := c.gcmVerify(ct, j0, aad); err != nil {
+			t.Fatalf("gcmVerify failed: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("gcmVerify allocated %.0f times per call, want 0", allocs)
+	}
+
+	// Tampering anywhere is still caught
+	for _, i := range []int{0, 50, len(ct) - 1} {
+		bad := append([]byte(nil), ct...)
+		bad[i] ^= 1
+		if err := c.gcmVerify(bad, j0, aad); err == nil {
+			t.Errorf("Expected tag mismatch with byte %d flipped", i)
+		}
+	}
+	if err := c.gcmVerify(ct, j0, []byte("other")); err == nil {
+		t.Error("Expected tag mismatch with different AAD")
+	}
+}
+
 func BenchmarkGCMEncrypt(b *testing.B) {
 	key := []byte("1234567890123456")
 	nonce := []byte("123456789012")
@@ -258,3 +713,18 @@ func BenchmarkGCMDecrypt(b *testing.B) {
 		_, _ = GCMDecrypt(ciphertext, key, nonce, aad)
 	}
 }
+
+// BenchmarkGCMVerifyTag measures recomputing and comparing the tag, which
+// should not allocate
+func BenchmarkGCMVerifyTag(b *testing.B) {
+	c, _ := NewCipher([]byte("1234567890123456"))
+	j0, _ := c.gcmJ0([]byte("123456789012"))
+	ct, _ := c.gcmSeal(make([]byte, 1024), j0, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.gcmVerify(ct, j0, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// DeriveKeyArgon2id derives a keyLen-byte key from password and salt with
+// Argon2id (RFC 9106). memory is in KiB; time is the number of passes.
+func DeriveKeyArgon2id(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	return argon2.IDKey(password, salt, time, memory, threads, keyLen)
+}
+
+// checkArgon2idParams rejects parameters argon2.IDKey would panic on or
+// RFC 9106 doesn't allow
+func checkArgon2idParams(time, memory uint32, threads uint8) error {
+	if time < 1 {
+		return fmt.Errorf("Argon2id time must be at least 1")
+	}
+	if threads < 1 {
+		return fmt.Errorf("Argon2id threads must be at least 1")
+	}
+	if memory < 8*uint32(threads) {
+		return fmt.Errorf("Argon2id memory must be at least 8 KiB per thread")
+	}
+	return nil
+}
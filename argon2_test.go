@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeriveKeyArgon2idDeterministic(t *testing.T) {
+	password := []byte("correct horse")
+	salt := []byte("0123456789abcdef")
+	a := DeriveKeyArgon2id(password, salt, 1, 64, 2, 32)
+	b := DeriveKeyArgon2id(password, salt, 1, 64, 2, 32)
+	if !bytes.Equal(a, b) {
+		t.Error("Same inputs gave different keys")
+	}
+	if len(a) != 32 {
+		t.Errorf("Key length %d, want 32", len(a))
+	}
+	if c := DeriveKeyArgon2id(password, []byte("fedcba9876543210"), 1, 64, 2, 32); bytes.Equal(a, c) {
+		t.Error("Different salts gave the same key")
+	}
+	if c := DeriveKeyArgon2id(password, salt, 2, 64, 2, 32); bytes.Equal(a, c) {
+		t.Error("Different time cost gave the same key")
+	}
+}
+
+func TestEncryptWithPasswordArgon2id(t *testing.T) {
+	plaintext := []byte("argon2id round trip")
+	opts := &Options{Argon2id: &Argon2idParams{Time: 1, Memory: 64, Threads: 1}}
+	blob, err := EncryptWithPassword(plaintext, []byte("pw"), opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	if blob[len(passwordMagic)] != passwordVersionArgon2 {
+		t.Errorf("Version byte %d, want %d", blob[len(passwordMagic)], passwordVersionArgon2)
+	}
+	pt, err := DecryptWithPassword(blob, []byte("pw"))
+	if err != nil {
+		t.Fatalf("DecryptWithPassword failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	if _, err := EncryptWithPassword(plaintext, []byte("pw"), &Options{Argon2id: &Argon2idParams{Time: 0, Memory: 64, Threads: 1}}); err == nil {
+		t.Error("Expected error for time 0")
+	}
+	both := &Options{Argon2id: opts.Argon2id, Scrypt: &ScryptParams{N: 16, R: 1, P: 1}}
+	if _, err := EncryptWithPassword(plaintext, []byte("pw"), both); err == nil {
+		t.Error("Expected error for both scrypt and Argon2id")
+	}
+}
+
+func TestDecryptWithPasswordArgon2idLimits(t *testing.T) {
+	opts := &Options{Argon2id: &Argon2idParams{Time: 1, Memory: 64, Threads: 1}}
+	blob, err := EncryptWithPassword([]byte("x"), []byte("pw"), opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	for _, tc := range []struct{ time, memory uint32 }{
+		{1, 4 << 20}, // 4 GiB
+		{1, 1<<20 + 1},
+		{17, 64},
+	} {
+		bad := append([]byte(nil), blob...)
+		params := bad[len(passwordMagic)+1:]
+		binary.BigEndian.PutUint32(params, tc.time)
+		binary.BigEndian.PutUint32(params[4:], tc.memory)
+		_, err := DecryptWithPassword(bad, []byte("pw"))
+		if err == nil || !strings.Contains(err.Error(), "limits") {
+			t.Errorf("t=%d m=%d: expected limit error, got %v", tc.time, tc.memory, err)
+		}
+	}
+}
+
+func TestEncryptWithPasswordArgon2idLimits(t *testing.T) {
+	// Time at the cap round-trips. Memory at the cap would need 1 GiB, so
+	// only the rejection just past it is exercised.
+	opts := &Options{Argon2id: &Argon2idParams{Time: passwordArgon2MaxTime, Memory: 64, Threads: 1}}
+	blob, err := EncryptWithPassword([]byte("x"), []byte("pw"), opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword at t=%d failed: %v", passwordArgon2MaxTime, err)
+	}
+	if pt, err := DecryptWithPassword(blob, []byte("pw")); err != nil || string(pt) != "x" {
+		t.Errorf("DecryptWithPassword at t=%d = %q, %v", passwordArgon2MaxTime, pt, err)
+	}
+
+	for _, ap := range []Argon2idParams{
+		{Time: passwordArgon2MaxTime + 1, Memory: 64, Threads: 1},
+		{Time: 1, Memory: passwordArgon2MaxMemory + 1, Threads: 1},
+	} {
+		_, err := EncryptWithPassword([]byte("x"), []byte("pw"), &Options{Argon2id: &ap})
+		if err == nil || !strings.Contains(err.Error(), "limits") {
+			t.Errorf("t=%d m=%d: expected limit error, got %v", ap.Time, ap.Memory, err)
+		}
+	}
+}
+
+func TestPasswordFlagArgon2id(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	ct := filepath.Join(dir, "ct.bin")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("argon2id from the CLI"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-password", "pw", "-kdf", "argon2id"}); err != nil {
+		t.Fatalf("encrypt-gcm -kdf argon2id failed: %v", err)
+	}
+	if err := cmdDecryptGCM([]string{"-in", ct, "-out", out, "-password", "pw"}); err != nil {
+		t.Fatalf("decrypt-gcm failed: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	if string(got) != "argon2id from the CLI" {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+)
+
+// Armored output is PEM: base64 in 64-character lines between
+//
+//	-----BEGIN AES MESSAGE-----
+//	-----END AES MESSAGE-----
+//
+// so ciphertext can be pasted into configs and emails.
+
+// armorType is the PEM block type of armored messages
+const armorType = "AES MESSAGE"
+
+// Armor base64-encodes data between BEGIN/END AES MESSAGE lines
+func Armor(data []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: armorType, Bytes: data}))
+}
+
+// Dearmor reverses Armor. Whitespace around the armor is ignored; anything
+// else before or after it is an error.
+func Dearmor(s string) ([]byte, error) {
+	data := bytes.TrimSpace([]byte(s))
+	block, rest := pem.Decode(data)
+	// pem.Decode skips any text before the BEGIN line
+	if block == nil || !isArmored(data) {
+		return nil, fmt.Errorf("no valid %s armor found", armorType)
+	}
+	if block.Type != armorType {
+		return nil, fmt.Errorf("armor type %q, want %q", block.Type, armorType)
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return nil, fmt.Errorf("unexpected data after armor")
+	}
+	return block.Bytes, nil
+}
+
+// isArmored reports whether data starts with an armor BEGIN line
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("-----BEGIN "+armorType+"-----"))
+}
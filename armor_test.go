@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestArmorRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 47, 48, 49, 1000} {
+		data := bytes.Repeat([]byte{0xa5, 0x00, 0x5a}, n)[:n]
+		s := Armor(data)
+		if !strings.HasPrefix(s, "-----BEGIN AES MESSAGE-----\n") || !strings.HasSuffix(s, "-----END AES MESSAGE-----\n") {
+			t.Errorf("%d bytes: armor missing BEGIN/END lines:\n%s", n, s)
+		}
+		for _, line := range strings.Split(s, "\n") {
+			if len(line) > 64 {
+				t.Errorf("%d bytes: line of %d characters", n, len(line))
+			}
+		}
+		got, err := Dearmor("\n  " + s + "\n\n")
+		if err != nil {
+			t.Fatalf("%d bytes: Dearmor failed: %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: round trip mismatch", n)
+		}
+	}
+}
+
+func TestDearmorRejectsBadInput(t *testing.T) {
+	good := Armor([]byte("some ciphertext"))
+	cases := map[string]string{
+		"empty":         "",
+		"binary":        "\x00\x01\x02",
+		"leading text":  "hello\n" + good,
+		"trailing text": good + "P.S.",
+		"other type":    strings.ReplaceAll(good, "AES MESSAGE", "CERTIFICATE"),
+		"no end line":   strings.TrimSuffix(good, "-----END AES MESSAGE-----\n"),
+		"bad base64":    strings.Replace(good, "c29t", "c2!t", 1),
+	}
+	for name, s := range cases {
+		if _, err := Dearmor(s); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
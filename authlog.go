@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// authLogMaxEntry bounds the entry length accepted when reading a log so a
+// corrupted length field can't trigger a huge allocation
+const authLogMaxEntry = 16 << 20
+
+// AuthLog is an append-only, tamper-evident encrypted log.
+// Each record is: nonce (12 bytes) || length (4 bytes) || ciphertext || tag (16 bytes)
+// and is GCM-encrypted with the previous record's tag as AAD (16 zero bytes
+// for the first record), so deleting or reordering records breaks the chain.
+// Dropping records from the end of the log is not detectable.
+type AuthLog struct {
+	w       io.Writer
+	key     []byte
+	prevTag []byte
+}
+
+// NewAuthLog starts a new log written to w
+func NewAuthLog(w io.Writer, key []byte) (*AuthLog, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	return &AuthLog{
+		w:       w,
+		key:     append([]byte(nil), key...),
+		prevTag: make([]byte, gcmTagSize),
+	}, nil
+}
+
+// Append encrypts entry and writes it as the next record
+func (l *AuthLog) Append(entry []byte) error {
+	if len(entry) > authLogMaxEntry {
+		return fmt.Errorf("log entry too large (%d bytes)", len(entry))
+	}
+	nonce, err := RandomNonce()
+	if err != nil {
+		return err
+	}
+	ct, err := GCMEncrypt(entry, l.key, nonce, l.prevTag)
+	if err != nil {
+		return err
+	}
+	rec := make([]byte, 0, len(nonce)+4+len(ct))
+	rec = append(rec, nonce...)
+	rec = binary.BigEndian.AppendUint32(rec, uint32(len(entry)))
+	rec = append(rec, ct...)
+	if _, err := l.w.Write(rec); err != nil {
+		return err
+	}
+	l.prevTag = ct[len(ct)-gcmTagSize:]
+	return nil
+}
+
+// ReadLog verifies the chain in r and returns the decrypted entries
+func ReadLog(r io.Reader, key []byte) ([][]byte, error) {
+	var entries [][]byte
+	prevTag := make([]byte, gcmTagSize)
+	hdr := make([]byte, gcmNonceSize+4)
+	for i := 0; ; i++ {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("record %d: truncated", i)
+		}
+		n := binary.BigEndian.Uint32(hdr[gcmNonceSize:])
+		if n > authLogMaxEntry {
+			return nil, fmt.Errorf("record %d: length %d too large", i, n)
+		}
+		ct := make([]byte, int(n)+gcmTagSize)
+		if _, err := io.ReadFull(r, ct); err != nil {
+			return nil, fmt.Errorf("record %d: truncated", i)
+		}
+		entry, err := GCMDecrypt(ct, key, hdr[:gcmNonceSize], prevTag)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		entries = append(entries, entry)
+		prevTag = ct[len(ct)-gcmTagSize:]
+	}
+}
+
+// VerifyLog checks that every record in r decrypts and chains correctly
+func VerifyLog(r io.Reader, key []byte) error {
+	_, err := ReadLog(r, key)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// buildAuthLog writes entries to a log and returns the raw record boundaries
+func buildAuthLog(t *testing.T, key []byte, entries []string) ([]byte, []int) {
+	t.Helper()
+	var buf bytes.Buffer
+	log, err := NewAuthLog(&buf, key)
+	if err != nil {
+		t.Fatalf("NewAuthLog failed: %v", err)
+	}
+	offsets := []int{0}
+	for _, e := range entries {
+		if err := log.Append([]byte(e)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		offsets = append(offsets, buf.Len())
+	}
+	return buf.Bytes(), offsets
+}
+
+func TestAuthLogRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	entries := []string{"login alice", "", "logout alice"}
+	raw, _ := buildAuthLog(t, key, entries)
+
+	if err := VerifyLog(bytes.NewReader(raw), key); err != nil {
+		t.Fatalf("VerifyLog failed: %v", err)
+	}
+	got, err := ReadLog(bytes.NewReader(raw), key)
+	if err != nil {
+		t.Fatalf("ReadLog failed: %v", err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("Got %d entries, want %d", len(got), len(entries))
+	}
+	for i := range entries {
+		if string(got[i]) != entries[i] {
+			t.Errorf("Entry %d = %q, want %q", i, got[i], entries[i])
+		}
+	}
+}
+
+func TestAuthLogTampering(t *testing.T) {
+	key := []byte("1234567890123456")
+	var entries []string
+	for i := 0; i < 4; i++ {
+		entries = append(entries, fmt.Sprintf("entry %d", i))
+	}
+	raw, off := buildAuthLog(t, key, entries)
+
+	// Remove the second record
+	removed := append(append([]byte(nil), raw[:off[1]]...), raw[off[2]:]...)
+	if err := VerifyLog(bytes.NewReader(removed), key); err == nil {
+		t.Error("Expected verification failure with a middle entry removed")
+	}
+
+	// Swap the second and third records
+	swapped := append([]byte(nil), raw[:off[1]]...)
+	swapped = append(swapped, raw[off[2]:off[3]]...)
+	swapped = append(swapped, raw[off[1]:off[2]]...)
+	swapped = append(swapped, raw[off[3]:]...)
+	if err := VerifyLog(bytes.NewReader(swapped), key); err == nil {
+		t.Error("Expected verification failure with reordered entries")
+	}
+
+	if err := VerifyLog(bytes.NewReader(raw[:len(raw)-1]), key); err == nil {
+		t.Error("Expected verification failure with truncated record")
+	}
+}
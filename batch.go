@@ -0,0 +1,67 @@
+package main
+
+import "fmt"
+
+// CBCEncryptBatch encrypts each plaintext under key with its own random IV.
+// The IVs are returned alongside the ciphertexts, index for index.
+func CBCEncryptBatch(plaintexts [][]byte, key []byte) (ciphertexts [][]byte, ivs [][]byte, err error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, nil, err
+	}
+	ciphertexts = make([][]byte, len(plaintexts))
+	ivs = make([][]byte, len(plaintexts))
+	seen := make(map[string]bool, len(plaintexts))
+	for i, pt := range plaintexts {
+		iv, err := RandomIV()
+		if err != nil {
+			return nil, nil, err
+		}
+		// A collision among 128-bit random IVs means the random source is
+		// broken, not bad luck
+		if seen[string(iv)] {
+			return nil, nil, fmt.Errorf("%w: IV repeated within batch", ErrBadRandom)
+		}
+		seen[string(iv)] = true
+		ct, err := CBCEncrypt(pt, key, iv)
+		if err != nil {
+			return nil, nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		ciphertexts[i] = ct
+		ivs[i] = iv
+	}
+	return ciphertexts, ivs, nil
+}
+
+// GCMRecord is one message for GCMVerifyBatch: Blob is nonce (12 bytes) ||
+// ciphertext || tag (16 bytes)
+type GCMRecord = struct {
+	Blob, AAD []byte
+}
+
+// GCMVerifyBatch checks the tag of every record under key without
+// decrypting, expanding the key once for the whole batch. errs[i] is nil
+// for each record that verifies.
+func GCMVerifyBatch(records []GCMRecord, key []byte) []error {
+	errs := make([]error, len(records))
+	c, err := NewCipher(key)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer c.Close()
+	for i, r := range records {
+		if len(r.Blob) < gcmNonceSize+gcmTagSize {
+			errs[i] = fmt.Errorf("blob too short (must have nonce + tag)")
+			continue
+		}
+		j0, err := c.gcmJ0(r.Blob[:gcmNonceSize])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		errs[i] = c.gcmVerify(r.Blob[gcmNonceSize:], j0, r.AAD)
+	}
+	return errs
+}
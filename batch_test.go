@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCBCEncryptBatch(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintexts := make([][]byte, 5000)
+	for i := range plaintexts {
+		plaintexts[i] = []byte(fmt.Sprintf("batch item %d", i))
+	}
+
+	cts, ivs, err := CBCEncryptBatch(plaintexts, key)
+	if err != nil {
+		t.Fatalf("CBCEncryptBatch failed: %v", err)
+	}
+	if len(cts) != len(plaintexts) || len(ivs) != len(plaintexts) {
+		t.Fatalf("Got %d ciphertexts and %d IVs for %d items", len(cts), len(ivs), len(plaintexts))
+	}
+	seen := make(map[string]bool)
+	for i := range plaintexts {
+		if seen[string(ivs[i])] {
+			t.Fatalf("IV %x repeated", ivs[i])
+		}
+		seen[string(ivs[i])] = true
+		pt, err := CBCDecrypt(cts[i], key, ivs[i])
+		if err != nil {
+			t.Fatalf("CBCDecrypt item %d failed: %v", i, err)
+		}
+		if !bytes.Equal(pt, plaintexts[i]) {
+			t.Errorf("Item %d doesn't round-trip", i)
+		}
+	}
+}
+
+func TestCBCEncryptBatchDetectsRepeatedIV(t *testing.T) {
+	saved := randReader
+	defer func() { randReader = saved }()
+	randReader = repeatReader{}
+
+	_, _, err := CBCEncryptBatch([][]byte{[]byte("a"), []byte("b")}, []byte("1234567890123456"))
+	if !errors.Is(err, ErrBadRandom) {
+		t.Errorf("Expected ErrBadRandom, got %v", err)
+	}
+}
+
+func makeGCMRecords(t testing.TB, key []byte, n int) []GCMRecord {
+	records := make([]GCMRecord, n)
+	for i := range records {
+		nonce, err := RandomNonce()
+		if err != nil {
+			t.Fatal(err)
+		}
+		aad := []byte(fmt.Sprintf("record %d", i))
+		ct, err := GCMEncrypt([]byte("batch verified payload"), key, nonce, aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[i] = GCMRecord{Blob: append(nonce, ct...), AAD: aad}
+	}
+	return records
+}
+
+func TestGCMVerifyBatch(t *testing.T) {
+	key := []byte("1234567890123456")
+	records := makeGCMRecords(t, key, 50)
+	records[7].Blob[20] ^= 1
+	records[13].AAD = []byte("wrong")
+	records[21].Blob = records[21].Blob[:10]
+
+	errs := GCMVerifyBatch(records, key)
+	if len(errs) != len(records) {
+		t.Fatalf("Got %d results for %d records", len(errs), len(records))
+	}
+	for i, err := range errs {
+		bad := i == 7 || i == 13 || i == 21
+		if bad && err == nil {
+			t.Errorf("Record %d: expected verification failure", i)
+		}
+		if !bad && err != nil {
+			t.Errorf("Record %d: unexpected error %v", i, err)
+		}
+	}
+
+	for i, err := range GCMVerifyBatch(records[:3], []byte("short")) {
+		if err == nil {
+			t.Errorf("Record %d: expected key size error", i)
+		}
+	}
+}
+
+func BenchmarkGCMVerifyBatch(b *testing.B) {
+	key := []byte("1234567890123456")
+	records := makeGCMRecords(b, key, 100)
+	b.Run("GCMDecrypt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range records {
+				GCMDecrypt(r.Blob[gcmNonceSize:], key, r.Blob[:gcmNonceSize], r.AAD)
+			}
+		}
+	})
+	b.Run("GCMVerifyBatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			GCMVerifyBatch(records, key)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CCM (RFC 3610, SP 800-38C): CBC-MAC over a formatted header, the AAD and
+// the plaintext, then CTR encryption of both the message and the MAC.
+//
+// The nonce is 7 to 13 bytes; the remaining 15-len(nonce) bytes of each
+// counter block hold the message length and block counter, so shorter
+// nonces allow longer messages. Tags are 4 to 16 bytes in steps of two.
+
+func checkCCMParams(nonce []byte, tagLen int) error {
+	if len(nonce) < 7 || len(nonce) > 13 {
+		return fmt.Errorf("CCM nonce must be 7 to 13 bytes")
+	}
+	if tagLen < 4 || tagLen > 16 || tagLen%2 != 0 {
+		return fmt.Errorf("CCM tag length must be 4, 6, 8, 10, 12, 14 or 16 bytes")
+	}
+	return nil
+}
+
+// ccmCounter returns counter block A_i: flags (L-1) || nonce || i
+func ccmCounter(nonce []byte, i uint64) []byte {
+	a := make([]byte, 16)
+	a[0] = byte(15 - len(nonce) - 1)
+	copy(a[1:], nonce)
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], i)
+	copy(a[1+len(nonce):], ctr[8-(15-len(nonce)):])
+	return a
+}
+
+// ccmMAC computes the untruncated CBC-MAC of B_0 || encoded AAD || plaintext
+func (c *Cipher) ccmMAC(plaintext, nonce, aad []byte, tagLen int) []byte {
+	l := 15 - len(nonce)
+	var mac [16]byte
+	mac[0] = byte((tagLen-2)/2<<3 | (l - 1))
+	if len(aad) > 0 {
+		mac[0] |= 0x40
+	}
+	copy(mac[1:], nonce)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(plaintext)))
+	copy(mac[1+len(nonce):], n[8-l:])
+	c.Encrypt(mac[:], mac[:])
+
+	absorb := func(data []byte) {
+		for i := 0; i < len(data); i += 16 {
+			for j, b := range data[i:min(i+16, len(data))] {
+				mac[j] ^= b
+			}
+			c.Encrypt(mac[:], mac[:])
+		}
+	}
+	if len(aad) > 0 {
+		var hdr []byte
+		switch {
+		case uint64(len(aad)) < 1<<16-1<<8:
+			hdr = binary.BigEndian.AppendUint16(nil, uint16(len(aad)))
+		case uint64(len(aad)) <= 0xffffffff:
+			hdr = binary.BigEndian.AppendUint32([]byte{0xff, 0xfe}, uint32(len(aad)))
+		default:
+			hdr = binary.BigEndian.AppendUint64([]byte{0xff, 0xff}, uint64(len(aad)))
+		}
+		// The length prefix and AAD are MACed as one zero-padded string
+		absorb(append(hdr, aad...))
+	}
+	absorb(plaintext)
+	return mac[:]
+}
+
+// CCMEncrypt encrypts and authenticates plaintext in CCM mode
+// Returns: ciphertext || tag (tagLen bytes)
+func CCMEncrypt(plaintext, key, nonce, aad []byte, tagLen int) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCCMParams(nonce, tagLen); err != nil {
+		return nil, err
+	}
+	if l := 15 - len(nonce); l < 8 && uint64(len(plaintext)) >= 1<<(8*l) {
+		return nil, fmt.Errorf("plaintext too long for a %d-byte CCM nonce", len(nonce))
+	}
+	mac := c.ccmMAC(plaintext, nonce, aad, tagLen)
+	ct, err := c.CTRXOR(plaintext, ccmCounter(nonce, 1))
+	if err != nil {
+		return nil, err
+	}
+	s0, err := c.CTRXOR(mac[:tagLen], ccmCounter(nonce, 0))
+	if err != nil {
+		return nil, err
+	}
+	return append(ct, s0...), nil
+}
+
+// CCMDecrypt verifies and decrypts the output of CCMEncrypt
+func CCMDecrypt(ciphertextWithTag, key, nonce, aad []byte, tagLen int) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCCMParams(nonce, tagLen); err != nil {
+		return nil, err
+	}
+	if len(ciphertextWithTag) < tagLen {
+		return nil, fmt.Errorf("ciphertext too short (must include %d-byte tag)", tagLen)
+	}
+	ct := ciphertextWithTag[:len(ciphertextWithTag)-tagLen]
+	pt, err := c.CTRXOR(ct, ccmCounter(nonce, 1))
+	if err != nil {
+		return nil, err
+	}
+	tag, err := c.CTRXOR(ciphertextWithTag[len(ct):], ccmCounter(nonce, 0))
+	if err != nil {
+		return nil, err
+	}
+	if !tagsEqual(tag, c.ccmMAC(pt, nonce, aad, tagLen)[:tagLen]) {
+		clear(pt)
+		return nil, fmt.Errorf("%w: tag mismatch", ErrAuthFailed)
+	}
+	return pt, nil
+}
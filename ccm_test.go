@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// RFC 3610 section 8, packet vectors 1 to 12. Every packet uses key
+// C0..CF and a plaintext packet of consecutive bytes from 00, whose first
+// hdrLen bytes are the AAD.
+var ccmVectors = []struct {
+	nonce          string
+	packetLen      int
+	hdrLen, tagLen int
+	out            string
+}{
+	{"00000003020100a0a1a2a3a4a5", 31, 8, 8, "588c979a61c663d2f066d0c2c0f989806d5f6b61dac38417e8d12cfdf926e0"},
+	{"00000004030201a0a1a2a3a4a5", 32, 8, 8, "72c91a36e135f8cf291ca894085c87e3cc15c439c9e43a3ba091d56e10400916"},
+	{"00000005040302a0a1a2a3a4a5", 33, 8, 8, "51b1e5f44a197d1da46b0f8e2d282ae871e838bb64da8596574adaa76fbd9fb0c5"},
+	{"00000006050403a0a1a2a3a4a5", 31, 12, 8, "a28c6865939a9a79faaa5c4c2a9d4a91cdac8c96c861b9c9e61ef1"},
+	{"00000007060504a0a1a2a3a4a5", 32, 12, 8, "dcf1fb7b5d9e23fb9d4e131253658ad86ebdca3e51e83f077d9c2d93"},
+	{"00000008070605a0a1a2a3a4a5", 33, 12, 8, "6fc1b011f006568b5171a42d953d469b2570a4bd87405a0443ac91cb94"},
+	{"00000009080706a0a1a2a3a4a5", 31, 8, 10, "0135d1b2c95f41d5d1d4fec185d166b8094e999dfed96c048c56602c97acbb7490"},
+	{"0000000a090807a0a1a2a3a4a5", 32, 8, 10, "7b75399ac0831dd2f0bbd75879a2fd8f6cae6b6cd9b7db24c17b4433f434963f34b4"},
+	{"0000000b0a0908a0a1a2a3a4a5", 33, 8, 10, "82531a60cc24945a4b8279181ab5c84df21ce7f9b73f42e197ea9c07e56b5eb17e5f4e"},
+	{"0000000c0b0a09a0a1a2a3a4a5", 31, 12, 10, "07342594157785152b074098330abb141b947b566aa9406b4d999988dd"},
+	{"0000000d0c0b0aa0a1a2a3a4a5", 32, 12, 10, "676bb20380b0e301e8ab79590a396da78b834934f53aa2e9107a8b6c022c"},
+	{"0000000e0d0c0ba0a1a2a3a4a5", 33, 12, 10, "c0ffa0d6f05bdb67f24d43a4338d2aa4bed7b20e43cd1aa31662e7ad65d6db"},
+}
+
+func TestCCMVectors(t *testing.T) {
+	key := mustHex(t, "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf")
+	for i, tc := range ccmVectors {
+		packet := make([]byte, tc.packetLen)
+		for j := range packet {
+			packet[j] = byte(j)
+		}
+		aad, plaintext := packet[:tc.hdrLen], packet[tc.hdrLen:]
+		nonce := mustHex(t, tc.nonce)
+		want := mustHex(t, tc.out)
+
+		ct, err := CCMEncrypt(plaintext, key, nonce, aad, tc.tagLen)
+		if err != nil {
+			t.Fatalf("packet %d: CCMEncrypt failed: %v", i+1, err)
+		}
+		if !bytes.Equal(ct, want) {
+			t.Errorf("packet %d: got %x, want %x", i+1, ct, want)
+		}
+		pt, err := CCMDecrypt(want, key, nonce, aad, tc.tagLen)
+		if err != nil {
+			t.Fatalf("packet %d: CCMDecrypt failed: %v", i+1, err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("packet %d: decrypted text doesn't match", i+1)
+		}
+	}
+}
+
+func TestCCMParameters(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := []byte("zigbee frame payload")
+	for _, nonceLen := range []int{7, 10, 13} {
+		for _, tagLen := range []int{4, 8, 16} {
+			nonce := bytes.Repeat([]byte{7}, nonceLen)
+			ct, err := CCMEncrypt(plaintext, key, nonce, []byte("hdr"), tagLen)
+			if err != nil {
+				t.Fatalf("nonce %d, tag %d: CCMEncrypt failed: %v", nonceLen, tagLen, err)
+			}
+			if len(ct) != len(plaintext)+tagLen {
+				t.Errorf("nonce %d, tag %d: output is %d bytes", nonceLen, tagLen, len(ct))
+			}
+			ct[0] ^= 1
+			if _, err := CCMDecrypt(ct, key, nonce, []byte("hdr"), tagLen); err == nil {
+				t.Errorf("nonce %d, tag %d: expected authentication failure", nonceLen, tagLen)
+			}
+		}
+	}
+	nonce := make([]byte, 13)
+	for _, tagLen := range []int{0, 3, 5, 18} {
+		if _, err := CCMEncrypt(plaintext, key, nonce, nil, tagLen); err == nil {
+			t.Errorf("Expected error for %d-byte tag", tagLen)
+		}
+	}
+	for _, n := range []int{6, 14} {
+		if _, err := CCMEncrypt(plaintext, key, make([]byte, n), nil, 8); err == nil {
+			t.Errorf("Expected error for %d-byte nonce", n)
+		}
+	}
+}
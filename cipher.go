@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Cipher is an AES key with its key schedule expanded once, unlike
+// EncryptBlock and DecryptBlock which expand the key on every call. The
+// decryption schedule is only built on the first Decrypt, so a Cipher that
+// only encrypts (CTR, GCM) never pays for it. A Cipher is safe for
+// concurrent use.
+//
+// The package-level mode functions (GCMEncrypt, CBCEncrypt, ...) build a
+// throwaway Cipher per call; keep one around to encrypt many messages
+// under the same key.
+type Cipher struct {
+	nr  int
+	enc [][4][4]byte
+
+	decOnce sync.Once
+	dec     [][4][4]byte
+
+	hOnce sync.Once
+	h     []byte      // GHASH key E(K, 0^128)
+	hTab  *ghashTable // multiples of h for GHASH
+}
+
+// NewCipher expands a 16-, 24- or 32-byte key
+func NewCipher(key []byte) (*Cipher, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	w := KeyExpansion(key)
+	nr := len(w)/Nb - 1
+	enc := make([][4][4]byte, nr+1)
+	for round := range enc {
+		enc[round] = RoundKeyMatrix(w, round)
+	}
+	clear(w)
+	return &Cipher{nr: nr, enc: enc}, nil
+}
+
+// Close wipes the key schedules and the GHASH key. The Cipher must not be
+// used afterwards. The package-level mode functions close their throwaway
+// Cipher before returning. Close always returns nil.
+func (c *Cipher) Close() error {
+	clear(c.enc)
+	clear(c.dec)
+	Zeroize(c.h)
+	if c.hTab != nil {
+		clear(c.hTab.products[:])
+	}
+	runtime.KeepAlive(c)
+	return nil
+}
+
+// BlockSize returns the AES block size, 16 bytes
+func (c *Cipher) BlockSize() int {
+	return 16
+}
+
+// decSchedule returns the round keys for the equivalent inverse cipher
+// (FIPS-197 section 5.3.5): the encryption keys in reverse order, with
+// InvMixColumns applied to all but the first and last
+func (c *Cipher) decSchedule() [][4][4]byte {
+	c.decOnce.Do(func() {
+		dec := make([][4][4]byte, c.nr+1)
+		dec[0] = c.enc[c.nr]
+		dec[c.nr] = c.enc[0]
+		for round := 1; round < c.nr; round++ {
+			dec[round] = c.enc[c.nr-round]
+			InvMixColumns(&dec[round])
+		}
+		c.dec = dec
+	})
+	return c.dec
+}
+
+func loadState(src []byte) [4][4]byte {
+	var state [4][4]byte
+	for i := 0; i < 16; i++ {
+		state[i%4][i/4] = src[i]
+	}
+	return state
+}
+
+func storeState(dst []byte, state *[4][4]byte) {
+	for i := 0; i < 16; i++ {
+		dst[i] = state[i%4][i/4]
+	}
+}
+
+// Encrypt encrypts the 16-byte block src into dst, which may overlap
+func (c *Cipher) Encrypt(dst, src []byte) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("Cipher.Encrypt requires 16-byte blocks")
+	}
+	state := loadState(src)
+	AddRoundKey(&state, c.enc[0])
+	for round := 1; round < c.nr; round++ {
+		SubBytes(&state)
+		ShiftRows(&state)
+		MixColumns(&state)
+		AddRoundKey(&state, c.enc[round])
+	}
+	SubBytes(&state)
+	ShiftRows(&state)
+	AddRoundKey(&state, c.enc[c.nr])
+	storeState(dst, &state)
+}
+
+// Decrypt decrypts the 16-byte block src into dst, which may overlap
+func (c *Cipher) Decrypt(dst, src []byte) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("Cipher.Decrypt requires 16-byte blocks")
+	}
+	dec := c.decSchedule()
+	state := loadState(src)
+	AddRoundKey(&state, dec[0])
+	for round := 1; round < c.nr; round++ {
+		InvSubBytes(&state)
+		InvShiftRows(&state)
+		InvMixColumns(&state)
+		AddRoundKey(&state, dec[round])
+	}
+	InvSubBytes(&state)
+	InvShiftRows(&state)
+	AddRoundKey(&state, dec[c.nr])
+	storeState(dst, &state)
+}
+
+// ghashKey returns H = E(K, 0^128), computed on first use
+func (c *Cipher) ghashKey() []byte {
+	c.initGHASH()
+	return c.h
+}
+
+// ghashTable returns the GHASH multiplication table for H, built on first
+// use alongside it
+func (c *Cipher) ghashTable() *ghashTable {
+	c.initGHASH()
+	return c.hTab
+}
+
+func (c *Cipher) initGHASH() {
+	c.hOnce.Do(func() {
+		c.h = make([]byte, 16)
+		c.Encrypt(c.h, c.h)
+		c.hTab = newGHASHTable(c.h)
+	})
+}
+
+// gcmJ0 builds the GCM pre-counter block (SP 800-38D section 7.1). A
+// 12-byte nonce gives J0 = nonce || 0^31 || 1; any other length is hashed,
+// J0 = GHASH(H, {}, nonce). Nonces of 12 bytes are recommended: longer or
+// shorter ones only add a GHASH pass and, when random, collide sooner.
+func (c *Cipher) gcmJ0(nonce []byte) ([]byte, error) {
+	if len(nonce) == 0 {
+		return nil, fmt.Errorf("GCM nonce must not be empty")
+	}
+	j0 := make([]byte, 16)
+	if len(nonce) == 12 {
+		copy(j0, nonce)
+		j0[15] = 1
+		return j0, nil
+	}
+	var h [16]byte
+	c.ghashTable().ghashInto(&h, nil, nonce, gcmLengthBlock(0, len(nonce)))
+	copy(j0, h[:])
+	return j0, nil
+}
+
+// GCMSeal is GCMEncrypt under c
+// Returns: ciphertext || tag (16 bytes)
+func (c *Cipher) GCMSeal(plaintext, nonce, aad []byte) ([]byte, error) {
+	j0, err := c.gcmJ0(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return c.gcmSeal(plaintext, j0, aad)
+}
+
+// GCMOpen is GCMDecrypt under c
+func (c *Cipher) GCMOpen(ciphertextWithTag, nonce, aad []byte) ([]byte, error) {
+	j0, err := c.gcmJ0(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return c.gcmOpen(ciphertextWithTag, j0, aad)
+}
+
+// CBCEncrypt is CBCEncrypt under c
+func (c *Cipher) CBCEncrypt(plaintext, iv []byte) ([]byte, error) {
+	if len(iv) != 16 {
+		return nil, fmt.Errorf("CBCEncrypt requires 16-byte IV")
+	}
+	padLen := 16 - len(plaintext)%16
+	pt := getBuffer(len(plaintext) + padLen)
+	copy(pt, plaintext)
+	for i := len(plaintext); i < len(pt); i++ {
+		pt[i] = byte(padLen)
+	}
+	out := getBuffer(len(pt))
+	prev := iv
+	for i := 0; i < len(pt); i += 16 {
+		block := out[i : i+16]
+		xorBlocks(block, pt[i:i+16], prev)
+		c.Encrypt(block, block)
+		prev = block
+	}
+	putBuffer(pt)
+	return out, nil
+}
+
+// CBCDecrypt is CBCDecrypt under c
+func (c *Cipher) CBCDecrypt(ciphertext, iv []byte) ([]byte, error) {
+	if len(iv) != 16 {
+		return nil, fmt.Errorf("CBCDecrypt requires 16-byte IV")
+	}
+	if len(ciphertext)%16 != 0 {
+		return nil, ErrInvalidPadding
+	}
+	out := getBuffer(len(ciphertext))
+	prev := iv
+	var ptBlock [16]byte
+	for i := 0; i < len(ciphertext); i += 16 {
+		block := ciphertext[i : i+16]
+		c.Decrypt(ptBlock[:], block)
+		xorBlocks(out[i:i+16], ptBlock[:], prev)
+		prev = block
+	}
+	unpadded, err := PKCS7Unpad(out, 16)
+	if err != nil {
+		putBuffer(out)
+		return nil, err
+	}
+	return unpadded, nil
+}
+
+// CTRXOR is CTREncrypt under c: it XORs data with the CTR keystream
+// starting at counter block iv
+func (c *Cipher) CTRXOR(data, iv []byte) ([]byte, error) {
+	if len(iv) != 16 {
+		return nil, fmt.Errorf("CTR mode requires 16-byte IV/counter")
+	}
+	out := getBuffer(len(data))
+	c.ctrXORInto(out, data, iv)
+	return out, nil
+}
+
+// ctrXORInto sets dst = src XOR the CTR keystream starting at counter
+// block iv. dst must be at least len(src) bytes.
+func (c *Cipher) ctrXORInto(dst, src, iv []byte) {
+	var counter, keyStream [16]byte
+	copy(counter[:], iv)
+	for i := 0; i < len(src); i += 16 {
+		c.Encrypt(keyStream[:], counter[:])
+		n := min(16, len(src)-i)
+		for j := 0; j < n; j++ {
+			dst[i+j] = src[i+j] ^ keyStream[j]
+		}
+		incCounter(counter[:])
+	}
+}
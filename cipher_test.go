@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCipherMatchesBlockFunctions(t *testing.T) {
+	block := make([]byte, 16)
+	for _, keySize := range []int{16, 24, 32} {
+		key := make([]byte, keySize)
+		rand.Read(key)
+		c, err := NewCipher(key)
+		if err != nil {
+			t.Fatalf("NewCipher failed: %v", err)
+		}
+		if c.dec != nil {
+			t.Fatal("Decryption schedule built before the first Decrypt")
+		}
+		for i := 0; i < 50; i++ {
+			rand.Read(block)
+			ct := make([]byte, 16)
+			c.Encrypt(ct, block)
+			if want := EncryptBlock(block, key); !bytes.Equal(ct, want) {
+				t.Fatalf("AES-%d: Cipher.Encrypt = %x, want %x", keySize*8, ct, want)
+			}
+			pt := make([]byte, 16)
+			c.Decrypt(pt, ct)
+			if want := DecryptBlock(ct, key); !bytes.Equal(pt, want) {
+				t.Fatalf("AES-%d: Cipher.Decrypt = %x, want %x", keySize*8, pt, want)
+			}
+			if !bytes.Equal(pt, block) {
+				t.Fatalf("AES-%d: block doesn't round-trip", keySize*8)
+			}
+		}
+		first := &c.decSchedule()[0]
+		c.Decrypt(block, block)
+		if &c.decSchedule()[0] != first {
+			t.Error("Decryption schedule was rebuilt")
+		}
+	}
+}
+
+func TestCipherFIPS197(t *testing.T) {
+	// FIPS-197 appendix C.1
+	key := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	pt := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	want := []byte{0x69, 0xc4, 0xe0, 0xd8, 0x6a, 0x7b, 0x04, 0x30, 0xd8, 0xcd, 0xb7, 0x80, 0x70, 0xb4, 0xc5, 0x5a}
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	buf := append([]byte(nil), pt...)
+	c.Encrypt(buf, buf)
+	if !bytes.Equal(buf, want) {
+		t.Errorf("Encrypt = %x, want %x", buf, want)
+	}
+	c.Decrypt(buf, buf)
+	if !bytes.Equal(buf, pt) {
+		t.Errorf("Decrypt = %x, want %x", buf, pt)
+	}
+}
+
+// BenchmarkDecryptSchedule compares DecryptBlock, which expands the key on
+// every call, with a Cipher that builds its decryption schedule once and
+// one rebuilt for every block
+func BenchmarkDecryptSchedule(b *testing.B) {
+	key := []byte("1234567890123456")
+	block := make([]byte, 16)
+	b.Run("DecryptBlock", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			block = DecryptBlock(block, key)
+		}
+	})
+	b.Run("NewCipherEachTime", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c, _ := NewCipher(key)
+			c.Decrypt(block, block)
+		}
+	})
+	b.Run("Cipher", func(b *testing.B) {
+		c, _ := NewCipher(key)
+		for i := 0; i < b.N; i++ {
+			c.Decrypt(block, block)
+		}
+	})
+}
+
+func TestCipherModesMatchPackageFunctions(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	nonce := []byte("123456789012")
+	aad := []byte("records")
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+
+	for _, n := range []int{0, 1, 16, 33, 100} {
+		msg := bytes.Repeat([]byte{byte(n)}, n)
+
+		sealed, err := c.GCMSeal(msg, nonce, aad)
+		if err != nil {
+			t.Fatalf("GCMSeal failed: %v", err)
+		}
+		want, _ := GCMEncrypt(msg, key, nonce, aad)
+		if !bytes.Equal(sealed, want) {
+			t.Errorf("%d bytes: GCMSeal doesn't match GCMEncrypt", n)
+		}
+		opened, err := c.GCMOpen(sealed, nonce, aad)
+		if err != nil || !bytes.Equal(opened, msg) {
+			t.Errorf("%d bytes: GCMOpen failed: %v", n, err)
+		}
+
+		ct, err := c.CBCEncrypt(msg, iv)
+		if err != nil {
+			t.Fatalf("CBCEncrypt failed: %v", err)
+		}
+		want, _ = CBCEncrypt(msg, key, iv)
+		if !bytes.Equal(ct, want) {
+			t.Errorf("%d bytes: Cipher.CBCEncrypt doesn't match CBCEncrypt", n)
+		}
+		pt, err := c.CBCDecrypt(ct, iv)
+		if err != nil || !bytes.Equal(pt, msg) {
+			t.Errorf("%d bytes: Cipher.CBCDecrypt failed: %v", n, err)
+		}
+
+		xored, err := c.CTRXOR(msg, iv)
+		if err != nil {
+			t.Fatalf("CTRXOR failed: %v", err)
+		}
+		want, _ = CTREncrypt(msg, key, iv)
+		if !bytes.Equal(xored, want) {
+			t.Errorf("%d bytes: CTRXOR doesn't match CTREncrypt", n)
+		}
+	}
+
+	sealed, _ := c.GCMSeal([]byte("tamper"), nonce, aad)
+	sealed[0] ^= 1
+	if _, err := c.GCMOpen(sealed, nonce, aad); err == nil {
+		t.Error("Expected authentication failure for tampered ciphertext")
+	}
+}
+
+// BenchmarkGCMSmallRecords seals 64-byte records with a fresh key schedule
+// per call and with one Cipher reused for every record
+func BenchmarkGCMSmallRecords(b *testing.B) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	record := make([]byte, 64)
+	b.Run("GCMEncrypt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			GCMEncrypt(record, key, nonce, nil)
+		}
+	})
+	b.Run("Cipher", func(b *testing.B) {
+		c, _ := NewCipher(key)
+		for i := 0; i < b.N; i++ {
+			c.GCMSeal(record, nonce, nil)
+		}
+	})
+}
+
+func TestCipherClose(t *testing.T) {
+	c, err := NewCipher([]byte("1234567890123456"))
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	block := make([]byte, 16)
+	c.Decrypt(block, block)
+	if _, err := c.GCMSeal([]byte("build the GHASH key"), []byte("123456789012"), nil); err != nil {
+		t.Fatalf("GCMSeal failed: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for _, schedule := range [][][4][4]byte{c.enc, c.dec} {
+		for round, rk := range schedule {
+			if rk != ([4][4]byte{}) {
+				t.Fatalf("Round key %d not wiped: %x", round, rk)
+			}
+		}
+	}
+	if !bytes.Equal(c.h, make([]byte, 16)) {
+		t.Errorf("GHASH key not wiped: %x", c.h)
+	}
+	if c.hTab.products != ([16]gfElement{}) {
+		t.Error("GHASH table not wiped")
+	}
+}
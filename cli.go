@@ -2,203 +2,1125 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 )
 
+// ErrInputTooLarge is returned when an input exceeds the -max-memory cap
+var ErrInputTooLarge = errors.New("input too large")
+
+// usageError marks errors caused by bad arguments rather than bad input;
+// main exits with status 2 for these
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+
+func (e *usageError) Unwrap() error { return e.err }
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage:\n")
-	fmt.Fprintf(os.Stderr, "  encrypt -in <infile> -out <outfile> -key <16-byte string>|-hexkey <32hex>\n")
-	fmt.Fprintf(os.Stderr, "  decrypt -in <infile> -out <outfile> -key <16-byte string>|-hexkey <32hex>\n")
-	fmt.Fprintf(os.Stderr, "  encrypt-gcm -in <infile> -out <outfile> -key <16-byte string>|-hexkey <32hex> [-aad <additional-data>]\n")
-	fmt.Fprintf(os.Stderr, "  decrypt-gcm -in <infile> -out <outfile> -key <16-byte string>|-hexkey <32hex> [-aad <additional-data>]\n")
+	fmt.Fprintf(os.Stderr, "  encrypt -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-framed] [-compress gzip|zstd|none] [-armor]\n")
+	fmt.Fprintf(os.Stderr, "  decrypt -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path>\n")
+	fmt.Fprintf(os.Stderr, "  encrypt-ctr -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-framed] [-compress gzip|zstd|none] [-armor]\n")
+	fmt.Fprintf(os.Stderr, "  decrypt-ctr -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path>\n")
+	fmt.Fprintf(os.Stderr, "  encrypt-gcm -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path>|-password <password> [-kdf pbkdf2|scrypt|argon2id] [-aad <additional-data>]... [-compat] [-framed] [-compress gzip|zstd|none] [-armor]\n")
+	fmt.Fprintf(os.Stderr, "  decrypt-gcm -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path>|-password <password> [-aad <additional-data>]... [-compat]\n")
+	fmt.Fprintf(os.Stderr, "  decrypt-auto -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-aad <additional-data>]... [-allow-unauthenticated]\n")
+	fmt.Fprintf(os.Stderr, "  encrypt-dir -in <dir> -out <dir> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-aad <additional-data>]...\n")
+	fmt.Fprintf(os.Stderr, "  decrypt-dir -in <dir> -out <dir> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-aad <additional-data>]...\n")
+	fmt.Fprintf(os.Stderr, "  verify-tree -in <dir> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-aad <additional-data>]... [-workers <n>]\n")
+	fmt.Fprintf(os.Stderr, "  encrypt-stream -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-chunk <bytes>] [-split <bytes>] [-progress]\n")
+	fmt.Fprintf(os.Stderr, "  decrypt-stream -in <infile> -out <outfile> -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> [-best-effort]\n")
+	fmt.Fprintf(os.Stderr, "  bench [-size <bytes>] [-n <count>] [-json]\n")
+	fmt.Fprintf(os.Stderr, "  genkey [-size 16|24|32] [-hex=false]\n")
+	fmt.Fprintf(os.Stderr, "  keystream -key <16|24|32-byte string>|-hexkey <32|48|64 hex>|-keyfile <path> -iv <32hex> -len <bytes> -out <outfile>\n")
+	fmt.Fprintf(os.Stderr, "encrypt, decrypt, encrypt-ctr, decrypt-ctr, encrypt-gcm, decrypt-gcm and decrypt-auto accept -max-memory <bytes> to cap input size\n")
+	fmt.Fprintf(os.Stderr, "encrypt, decrypt, encrypt-ctr, decrypt-ctr, encrypt-gcm, decrypt-gcm and decrypt-auto accept -v to print the key fingerprint\n")
+	fmt.Fprintf(os.Stderr, "encrypt, decrypt, encrypt-ctr, decrypt-ctr, encrypt-gcm, decrypt-gcm and decrypt-auto accept -inplace instead of -out to replace the input file\n")
+	fmt.Fprintf(os.Stderr, "the decrypt commands detect -armor output and decode it automatically\n")
+	fmt.Fprintf(os.Stderr, "without -key, -hexkey or -keyfile the key is read from $AES_KEY (hex or raw; prefix hex: or raw: if both would fit)\n")
+	fmt.Fprintf(os.Stderr, "every command accepts -dry-run to validate its arguments and input without writing anything\n")
+	fmt.Fprintf(os.Stderr, "put -json-errors before the command to report errors as one line of JSON on stderr\n")
 	os.Exit(2)
 }
 
-func parseKey(fs *flag.FlagSet) []byte {
+// parseKey reads the key from the command's flags. -key, -hexkey and
+// -keyfile are mutually exclusive; when none of them is given the key comes
+// from the AES_KEY environment variable, read as hex or raw bytes the same
+// way as a key file.
+func parseKey(fs *flag.FlagSet) ([]byte, error) {
 	k := fs.Lookup("key").Value.String()
 	h := fs.Lookup("hexkey").Value.String()
+	var kf string
+	if f := fs.Lookup("keyfile"); f != nil {
+		kf = f.Value.String()
+	}
+	env := os.Getenv("AES_KEY")
+	var key []byte
+	var err error
+	switch {
+	case kf != "" && (k != "" || h != ""):
+		err = fmt.Errorf("specify only one of -key, -hexkey or -keyfile")
+	case kf != "":
+		key, err = LoadKeyFile(kf)
+	case k == "" && h == "" && env != "":
+		if key, err = decodeKeyMaterial([]byte(env)); err != nil {
+			err = fmt.Errorf("AES_KEY: %w", err)
+		}
+	default:
+		key, err = decodeKey(k, h)
+	}
+	if err != nil {
+		return nil, &usageError{err}
+	}
+	return key, nil
+}
+
+// checkPasswordFlags rejects flags that don't apply with -password: the
+// key comes from the password, and the password format has its own header
+// (which is also its only AAD)
+func checkPasswordFlags(fs *flag.FlagSet) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "key", "hexkey", "keyfile", "aad", "framed", "compress", "compat", "v":
+			if err == nil {
+				err = &usageError{fmt.Errorf("-%s can't be combined with -password", f.Name)}
+			}
+		}
+	})
+	return err
+}
+
+// decodeKey turns the -key/-hexkey flag values into key bytes. The key
+// length selects the AES variant: 16 bytes for AES-128, 24 for AES-192 and
+// 32 for AES-256.
+func decodeKey(k, h string) ([]byte, error) {
 	if k != "" && h != "" {
-		fmt.Fprintln(os.Stderr, "specify only one of -key or -hexkey")
-		os.Exit(2)
+		return nil, fmt.Errorf("specify only one of -key or -hexkey")
 	}
 	if k == "" && h == "" {
-		fmt.Fprintln(os.Stderr, "key required")
-		os.Exit(2)
+		return nil, fmt.Errorf("key required")
 	}
 	if k != "" {
-		if len(k) != 16 {
-			fmt.Fprintln(os.Stderr, "key string must be exactly 16 bytes for AES-128")
-			os.Exit(2)
+		if checkKeySize([]byte(k)) != nil {
+			return nil, fmt.Errorf("key string must be exactly 16, 24 or 32 bytes (AES-128, AES-192 or AES-256)")
 		}
-		return []byte(k)
+		return []byte(k), nil
 	}
-	b, err := hex.DecodeString(h)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "bad hex key: %v\n", err)
-		os.Exit(2)
+	return decodeHexKey(h)
+}
+
+// writeOutput writes a command's result to path. Under -dry-run nothing is
+// written. A path of "-" writes to stdout.
+func writeOutput(path string, data []byte, dryRun bool) error {
+	if path == "-" {
+		if dryRun {
+			return nil
+		}
+		if _, err := stdout.Write(data); err != nil {
+			return fmt.Errorf("write stdout: %w", err)
+		}
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeResult is writeOutput for the commands that take -inplace, where
+// path is the input file and is replaced atomically
+func writeResult(path string, data []byte, inplace, dryRun bool) error {
+	if inplace && !dryRun {
+		return replaceFile(path, data)
+	}
+	return writeOutput(path, data, dryRun)
+}
+
+// writeCiphertext is writeResult for the encrypt commands, armoring the
+// output first under -armor
+func writeCiphertext(path string, data []byte, armor, inplace, dryRun bool) error {
+	if armor {
+		data = []byte(Armor(data))
+	}
+	return writeResult(path, data, inplace, dryRun)
+}
+
+// readCiphertext is readInput for the decrypt commands. Armored input is
+// detected and decoded, so decrypting doesn't need an -armor flag.
+func readCiphertext(path string, maxMemory int64) ([]byte, error) {
+	data, err := readInput(path, maxMemory)
+	if err != nil || !isArmored(data) {
+		return data, err
+	}
+	return Dearmor(string(data))
+}
+
+// stdin and stdout back -in - and -out -
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+)
+
+// infoOutput receives summary lines, except from commands whose data goes
+// to stdout (see summaryOutput)
+var infoOutput io.Writer = os.Stdout
+
+// summaryOutput returns where a command writing its result to path prints
+// its summary: stderr when the result goes to stdout, so the two don't mix
+func summaryOutput(path string) io.Writer {
+	if path == "-" {
+		return os.Stderr
 	}
-	if len(b) != 16 {
-		fmt.Fprintln(os.Stderr, "hex key must decode to 16 bytes")
-		os.Exit(2)
+	return infoOutput
+}
+
+// report prints a command's summary line to w, marking it when -dry-run
+// meant nothing was written
+func report(w io.Writer, dryRun bool, format string, args ...any) {
+	if dryRun {
+		format = "dry run, nothing written: " + format
 	}
-	return b
+	fmt.Fprintf(w, format, args...)
 }
 
-func cmdEncrypt(args []string) {
+func cmdEncrypt(args []string) error {
 	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
 	in := fs.String("in", "", "")
 	out := fs.String("out", "", "")
 	keyStr := fs.String("key", "", "")
 	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	framed := fs.Bool("framed", false, "Write a self-describing header readable by decrypt-auto")
+	compress := fs.String("compress", "none", "Compress before encrypting: gzip, zstd or none (implies -framed)")
+	armor := fs.Bool("armor", false, "Base64-encode the output between BEGIN/END AES MESSAGE lines")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
 	_ = keyStr
 	_ = hexKey
 	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
 	if *in == "" || *out == "" {
 		usage()
 	}
-	key := parseKey(fs)
-	data, err := os.ReadFile(*in)
+	info := summaryOutput(*out)
+	key, err := parseKey(fs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "read %s: %v\n", *in, err)
-		os.Exit(1)
+		return err
+	}
+	defer Zeroize(key)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
+	}
+	codec, err := CodecByName(*compress)
+	if err != nil {
+		return &usageError{err}
+	}
+	data, err := readInput(*in, *maxMem)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	if *framed || codec != CodecNone {
+		buf, err := SealEnvelopeCompressed(ModeCBC, codec, data, key, nil)
+		if err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+			return err
+		}
+		report(info, *dryRun, "encrypted %s -> %s (framed CBC, compression %s)\n", *in, *out, CodecName(codec))
+		return nil
+	}
+	iv, err := RandomIV()
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
 	}
-	iv := RandomIV()
 	ct, err := CBCEncrypt(data, key, iv)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "encrypt: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("encrypt: %w", err)
 	}
-	buf := append(iv, ct...)
-	if err := os.WriteFile(*out, buf, 0600); err != nil {
-		fmt.Fprintf(os.Stderr, "write %s: %v\n", *out, err)
-		os.Exit(1)
+	buf := prependIV(iv, ct)
+	if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+		return err
 	}
-	fmt.Printf("encrypted %s -> %s (%d bytes ciphertext + 16-byte IV prefix)\n", *in, *out, len(ct))
+	report(info, *dryRun, "encrypted %s -> %s (%d bytes ciphertext + 16-byte IV prefix)\n", *in, *out, len(ct))
+	return nil
 }
 
-func cmdDecrypt(args []string) {
+func cmdDecrypt(args []string) error {
 	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
 	in := fs.String("in", "", "")
 	out := fs.String("out", "", "")
 	keyStr := fs.String("key", "", "")
 	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
 	_ = keyStr
 	_ = hexKey
 	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
 	if *in == "" || *out == "" {
 		usage()
 	}
-	key := parseKey(fs)
-	data, err := os.ReadFile(*in)
+	info := summaryOutput(*out)
+	key, err := parseKey(fs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "read %s: %v\n", *in, err)
-		os.Exit(1)
+		return err
+	}
+	defer Zeroize(key)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
+	}
+	data, err := readCiphertext(*in, *maxMem)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
 	}
 	if len(data) < 16 {
-		fmt.Fprintln(os.Stderr, "ciphertext file too short")
-		os.Exit(1)
+		return fmt.Errorf("ciphertext file too short")
 	}
 	iv := data[:16]
 	ct := data[16:]
 	pt, err := CBCDecrypt(ct, key, iv)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("decrypt: %w", err)
 	}
-	if err := os.WriteFile(*out, pt, 0600); err != nil {
-		fmt.Fprintf(os.Stderr, "write %s: %v\n", *out, err)
-		os.Exit(1)
+	if err := writeResult(*out, pt, *inplace, *dryRun); err != nil {
+		return err
+	}
+	report(info, *dryRun, "decrypted %s -> %s\n", *in, *out)
+	return nil
+}
+
+// ctrWarning is printed by encrypt-ctr and decrypt-ctr
+const ctrWarning = "warning: CTR mode has no integrity protection; tampering goes undetected (use encrypt-gcm unless the file is authenticated separately)"
+
+func cmdEncryptCTR(args []string) error {
+	fs := flag.NewFlagSet("encrypt-ctr", flag.ExitOnError)
+	in := fs.String("in", "", "")
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	framed := fs.Bool("framed", false, "Write a self-describing header readable by decrypt-auto")
+	compress := fs.String("compress", "none", "Compress before encrypting: gzip, zstd or none (implies -framed)")
+	armor := fs.Bool("armor", false, "Base64-encode the output between BEGIN/END AES MESSAGE lines")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
+	if *in == "" || *out == "" {
+		usage()
+	}
+	info := summaryOutput(*out)
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	fmt.Fprintln(os.Stderr, ctrWarning)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
 	}
-	fmt.Printf("decrypted %s -> %s\n", *in, *out)
+	codec, err := CodecByName(*compress)
+	if err != nil {
+		return &usageError{err}
+	}
+	data, err := readInput(*in, *maxMem)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	if *framed || codec != CodecNone {
+		buf, err := SealEnvelopeCompressed(ModeCTR, codec, data, key, nil)
+		if err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+			return err
+		}
+		report(info, *dryRun, "encrypted %s -> %s (framed CTR, compression %s)\n", *in, *out, CodecName(codec))
+		return nil
+	}
+	iv, err := RandomIV()
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	ct, err := CTREncrypt(data, key, iv)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	buf := prependIV(iv, ct)
+	if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+		return err
+	}
+	report(info, *dryRun, "encrypted %s -> %s (CTR mode: %d bytes ciphertext + 16-byte IV prefix)\n", *in, *out, len(ct))
+	return nil
+}
+
+func cmdDecryptCTR(args []string) error {
+	fs := flag.NewFlagSet("decrypt-ctr", flag.ExitOnError)
+	in := fs.String("in", "", "")
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
+	if *in == "" || *out == "" {
+		usage()
+	}
+	info := summaryOutput(*out)
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	fmt.Fprintln(os.Stderr, ctrWarning)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
+	}
+	data, err := readCiphertext(*in, *maxMem)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	if len(data) < 16 {
+		return fmt.Errorf("ciphertext file too short")
+	}
+	pt, err := CTREncrypt(data[16:], key, data[:16])
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if err := writeResult(*out, pt, *inplace, *dryRun); err != nil {
+		return err
+	}
+	report(info, *dryRun, "decrypted %s -> %s (CTR mode, not authenticated)\n", *in, *out)
+	return nil
 }
 
-func cmdEncryptGCM(args []string) {
+func cmdEncryptGCM(args []string) error {
 	fs := flag.NewFlagSet("encrypt-gcm", flag.ExitOnError)
 	in := fs.String("in", "", "")
 	out := fs.String("out", "", "")
 	keyStr := fs.String("key", "", "")
 	hexKey := fs.String("hexkey", "", "")
-	aad := fs.String("aad", "", "Additional authenticated data")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	var aad aadFlag
+	fs.Var(&aad, "aad", "Additional authenticated data (repeat for multiple segments)")
+	compat := fs.Bool("compat", false, "Print the exact byte layout of GCM files")
+	framed := fs.Bool("framed", false, "Write a self-describing header readable by decrypt-auto")
+	compress := fs.String("compress", "none", "Compress before encrypting: gzip, zstd or none (implies -framed)")
+	armor := fs.Bool("armor", false, "Base64-encode the output between BEGIN/END AES MESSAGE lines")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
+	password := fs.String("password", "", "Derive the key from a password instead of -key/-hexkey")
+	kdf := fs.String("kdf", "pbkdf2", "Key derivation for -password: pbkdf2, scrypt or argon2id")
 	_ = keyStr
 	_ = hexKey
 	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
 	if *in == "" || *out == "" {
 		usage()
 	}
-	key := parseKey(fs)
-	data, err := os.ReadFile(*in)
+	info := summaryOutput(*out)
+	if *password != "" {
+		if err := checkPasswordFlags(fs); err != nil {
+			return err
+		}
+		var opts *Options
+		kdfName := "PBKDF2-HMAC-SHA256"
+		switch *kdf {
+		case "pbkdf2":
+		case "scrypt":
+			opts = &Options{Scrypt: &DefaultScryptParams}
+			kdfName = "scrypt"
+		case "argon2id":
+			opts = &Options{Argon2id: &DefaultArgon2idParams}
+			kdfName = "Argon2id"
+		default:
+			return &usageError{fmt.Errorf("unknown -kdf %q (want pbkdf2, scrypt or argon2id)", *kdf)}
+		}
+		data, err := readInput(*in, *maxMem)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", *in, err)
+		}
+		buf, err := EncryptWithPassword(data, []byte(*password), opts)
+		if err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+			return err
+		}
+		report(info, *dryRun, "encrypted %s -> %s (password, %s + AES-128-GCM)\n", *in, *out, kdfName)
+		return nil
+	}
+	if *kdf != "pbkdf2" {
+		return &usageError{fmt.Errorf("-kdf only applies with -password")}
+	}
+	key, err := parseKey(fs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "read %s: %v\n", *in, err)
-		os.Exit(1)
+		return err
+	}
+	defer Zeroize(key)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
 	}
-	nonce := RandomNonce()
-	ct, err := GCMEncrypt(data, key, nonce, []byte(*aad))
+	codec, err := CodecByName(*compress)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "encrypt: %v\n", err)
-		os.Exit(1)
+		return &usageError{err}
 	}
-	// Format: nonce (12 bytes) || ciphertext || tag (16 bytes)
-	buf := append(nonce, ct...)
-	if err := os.WriteFile(*out, buf, 0600); err != nil {
-		fmt.Fprintf(os.Stderr, "write %s: %v\n", *out, err)
-		os.Exit(1)
+	data, err := readInput(*in, *maxMem)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	if *framed || codec != CodecNone {
+		buf, err := SealEnvelopeCompressed(ModeGCM, codec, data, key, aad.bytes())
+		if err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+			return err
+		}
+		report(info, *dryRun, "encrypted %s -> %s (framed GCM, compression %s)\n", *in, *out, CodecName(codec))
+		return nil
+	}
+	nonce, err := RandomNonce()
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	buf, err := sealGCMFile(data, key, nonce, aad.bytes())
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
 	}
-	fmt.Printf("encrypted %s -> %s (GCM mode: %d bytes ciphertext+tag + 12-byte nonce prefix)\n", *in, *out, len(ct))
+	if err := writeCiphertext(*out, buf, *armor, *inplace, *dryRun); err != nil {
+		return err
+	}
+	report(info, *dryRun, "encrypted %s -> %s (AES-%d-GCM: %d bytes ciphertext+tag + %d-byte header)\n", *in, *out, len(key)*8, len(buf)-gcmFileHeaderSize, gcmFileHeaderSize)
+	if *compat {
+		fmt.Fprint(info, DescribeFormat())
+	}
+	return nil
 }
 
-func cmdDecryptGCM(args []string) {
+func cmdDecryptGCM(args []string) error {
 	fs := flag.NewFlagSet("decrypt-gcm", flag.ExitOnError)
 	in := fs.String("in", "", "")
 	out := fs.String("out", "", "")
 	keyStr := fs.String("key", "", "")
 	hexKey := fs.String("hexkey", "", "")
-	aad := fs.String("aad", "", "Additional authenticated data")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	var aad aadFlag
+	fs.Var(&aad, "aad", "Additional authenticated data (repeat for multiple segments)")
+	compat := fs.Bool("compat", false, "Print the exact byte layout of GCM files")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
+	password := fs.String("password", "", "Password the file was encrypted with (instead of -key/-hexkey)")
 	_ = keyStr
 	_ = hexKey
 	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
 	if *in == "" || *out == "" {
 		usage()
 	}
-	key := parseKey(fs)
-	data, err := os.ReadFile(*in)
+	info := summaryOutput(*out)
+	if *password != "" {
+		if err := checkPasswordFlags(fs); err != nil {
+			return err
+		}
+		data, err := readCiphertext(*in, *maxMem)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", *in, err)
+		}
+		pt, err := DecryptWithPassword(data, []byte(*password))
+		if err != nil {
+			return fmt.Errorf("decrypt: %w", err)
+		}
+		if err := writeResult(*out, pt, *inplace, *dryRun); err != nil {
+			return err
+		}
+		report(info, *dryRun, "decrypted and verified %s -> %s (password)\n", *in, *out)
+		return nil
+	}
+	key, err := parseKey(fs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "read %s: %v\n", *in, err)
-		os.Exit(1)
+		return err
 	}
-	if len(data) < 12+16 {
-		fmt.Fprintln(os.Stderr, "ciphertext file too short (must have nonce + tag)")
-		os.Exit(1)
+	defer Zeroize(key)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
 	}
-	nonce := data[:12]
-	ct := data[12:]
-	pt, err := GCMDecrypt(ct, key, nonce, []byte(*aad))
+	data, err := readCiphertext(*in, *maxMem)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("read %s: %w", *in, err)
 	}
-	if err := os.WriteFile(*out, pt, 0600); err != nil {
-		fmt.Fprintf(os.Stderr, "write %s: %v\n", *out, err)
-		os.Exit(1)
+	pt, err := openGCMFile(data, key, aad.bytes())
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if err := writeResult(*out, pt, *inplace, *dryRun); err != nil {
+		return err
+	}
+	report(info, *dryRun, "decrypted and verified %s -> %s (GCM mode)\n", *in, *out)
+	if *compat {
+		fmt.Fprint(info, DescribeFormat())
+	}
+	return nil
+}
+
+// cmdDecryptAuto decrypts a framed file in whichever mode its header names.
+// It is also available under its original name, open.
+func cmdDecryptAuto(args []string) error {
+	fs := flag.NewFlagSet("decrypt-auto", flag.ExitOnError)
+	in := fs.String("in", "", "")
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	maxMem := fs.Int64("max-memory", 0, "Refuse inputs larger than this many bytes (0 = no limit)")
+	var aad aadFlag
+	fs.Var(&aad, "aad", "Additional authenticated data, GCM only (repeat for multiple segments)")
+	unauthenticated := fs.Bool("allow-unauthenticated", false, "Also open framed CBC and CTR files, whose header and contents anyone can alter")
+	inplace := fs.Bool("inplace", false, "Replace the input file with the result instead of writing -out")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	verbose := fs.Bool("v", false, "Print the key fingerprint to stderr")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if err := checkInPlace(*inplace, *in, out); err != nil {
+		return err
+	}
+	if *in == "" || *out == "" {
+		usage()
+	}
+	info := summaryOutput(*out)
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "key id: %s\n", KeyFingerprint(key))
+	}
+	// Without the opt-in a GCM file relabelled as CTR is refused rather
+	// than decrypted with no tag check
+	allowed := []byte{ModeGCM}
+	if *unauthenticated {
+		allowed = append(allowed, ModeCBC, ModeCTR)
+	}
+	data, err := readCiphertext(*in, *maxMem)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	e, _, err := ParseEnvelope(data)
+	if err == ErrNotFramed {
+		return fmt.Errorf("%s: %w; files written without -framed need decrypt, decrypt-ctr or decrypt-gcm", *in, err)
+	}
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	pt, err := OpenEnvelope(data, key, aad.bytes(), allowed...)
+	if errors.Is(err, ErrModeNotAllowed) {
+		return fmt.Errorf("decrypt: %w; %s files are unauthenticated, pass -allow-unauthenticated to open them anyway", err, ModeName(e.Mode))
+	}
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if err := writeResult(*out, pt, *inplace, *dryRun); err != nil {
+		return err
+	}
+	report(info, *dryRun, "decrypted %s -> %s (%s mode, compression %s)\n", *in, *out, ModeName(e.Mode), CodecName(e.Codec))
+	return nil
+}
+
+func cmdEncryptStream(args []string) error {
+	fs := flag.NewFlagSet("encrypt-stream", flag.ExitOnError)
+	in := fs.String("in", "", "")
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	chunk := fs.Int("chunk", 0, "Frame size in bytes (0 picks one from the input size)")
+	split := fs.Int64("split", 0, "Spread the output over <out>.001, <out>.002, ... of at most this many bytes")
+	showProgress := fs.Bool("progress", false, "Print how much of the input has been encrypted to stderr")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if *in == "" || *out == "" {
+		usage()
+	}
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	src, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	defer src.Close()
+	chunkSize := *chunk
+	if chunkSize == 0 {
+		st, err := src.Stat()
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", *in, err)
+		}
+		chunkSize = AdaptiveChunkSize(st.Size())
+	}
+	// Every split file must hold at least one full frame (the first also
+	// carries the header)
+	if minSplit := int64(streamHeaderSize + streamFrameHdrSize + chunkSize + gcmTagSize); *split != 0 && *split < minSplit {
+		return &usageError{fmt.Errorf("split size must be at least %d bytes for %d-byte frames", minSplit, chunkSize)}
+	}
+	if *dryRun {
+		// Building a writer checks the key and chunk size; the input itself
+		// is only opened, so a dry run stays cheap on large files
+		if _, err := NewGCMStreamWriter(io.Discard, key, chunkSize); err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		report(infoOutput, true, "encrypted %s -> %s (GCM stream, %d-byte frames)\n", *in, *out, chunkSize)
+		return nil
+	}
+	var input io.Reader = src
+	var prog *progress
+	if *showProgress {
+		// Pipes and devices have no useful size, so they only get a counter
+		var total int64
+		if st, err := src.Stat(); err == nil && st.Mode().IsRegular() {
+			total = st.Size()
+		}
+		prog = newProgress(os.Stderr, total)
+		input = io.TeeReader(src, prog)
+	}
+	if *split != 0 {
+		return encryptStreamSplit(input, prog, *in, *out, key, chunkSize, *split)
+	}
+	dst, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", *out, err)
+	}
+	sw, err := NewGCMStreamWriter(dst, key, chunkSize)
+	if err == nil {
+		_, err = io.Copy(sw, input)
+		if err == nil {
+			err = sw.Close()
+		}
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	prog.done()
+	if err != nil {
+		os.Remove(*out)
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	report(infoOutput, false, "encrypted %s -> %s (GCM stream, %d-byte frames)\n", *in, *out, chunkSize)
+	return nil
+}
+
+// encryptStreamSplit is encrypt-stream -split: the stream goes to numbered
+// files that each end on a frame boundary. prog, if not nil, is finished
+// before the summary line.
+func encryptStreamSplit(src io.Reader, prog *progress, in, out string, key []byte, chunkSize int, limit int64) error {
+	dst := newSplitWriter(out, limit)
+	sw, err := NewGCMStreamWriter(dst, key, chunkSize)
+	if err == nil {
+		_, err = io.Copy(sw, src)
+		if err == nil {
+			err = sw.Close()
+		}
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	prog.done()
+	if err != nil {
+		dst.remove()
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	report(infoOutput, false, "encrypted %s -> %s..%s (GCM stream, %d-byte frames, %d files)\n",
+		in, dst.parts[0], dst.parts[len(dst.parts)-1], chunkSize, len(dst.parts))
+	return nil
+}
+
+func cmdDecryptStream(args []string) error {
+	fs := flag.NewFlagSet("decrypt-stream", flag.ExitOnError)
+	in := fs.String("in", "", "")
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	bestEffort := fs.Bool("best-effort", false, "Keep every frame that verified before a truncated or corrupt one")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if *in == "" || *out == "" {
+		usage()
+	}
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	// A name ending in .001 also reads the rest of a -split output
+	src, err := openSplitParts(*in)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *in, err)
+	}
+	defer src.Close()
+	sr, err := NewGCMStreamReader(src, key)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if *dryRun {
+		// Every frame is still authenticated, the plaintext is just dropped
+		n, err := io.Copy(io.Discard, sr)
+		if err != nil && *bestEffort {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			report(infoOutput, true, "recovered %d verified bytes from %s -> %s (stream incomplete)\n", n, *in, *out)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decrypt: %w", err)
+		}
+		report(infoOutput, true, "decrypted and verified %s -> %s (GCM stream)\n", *in, *out)
+		return nil
+	}
+	dst, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", *out, err)
+	}
+	// The reader only hands out frames that verified, so whatever was
+	// copied before an error is authenticated plaintext
+	n, err := io.Copy(dst, sr)
+	if cerr := dst.Close(); cerr != nil {
+		os.Remove(*out)
+		return fmt.Errorf("write %s: %w", *out, cerr)
 	}
-	fmt.Printf("decrypted and verified %s -> %s (GCM mode)\n", *in, *out)
+	if err != nil && *bestEffort {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		report(infoOutput, false, "recovered %d verified bytes from %s -> %s (stream incomplete)\n", n, *in, *out)
+		return nil
+	}
+	if err != nil {
+		// Never leave unauthenticated plaintext behind
+		os.Remove(*out)
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	report(infoOutput, false, "decrypted and verified %s -> %s (GCM stream)\n", *in, *out)
+	return nil
+}
+
+func cmdKeystream(args []string) error {
+	fs := flag.NewFlagSet("keystream", flag.ExitOnError)
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	ivHex := fs.String("iv", "", "16-byte CTR counter block as hex")
+	n := fs.Int("len", -1, "Number of keystream bytes")
+	dryRun := fs.Bool("dry-run", false, "Validate everything but write no output")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if *out == "" || *ivHex == "" || *n < 0 {
+		usage()
+	}
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	iv, err := hex.DecodeString(*ivHex)
+	if err != nil || len(iv) != 16 {
+		return &usageError{fmt.Errorf("iv must be 32 hex characters (16 bytes)")}
+	}
+	if !*dryRun {
+		fmt.Fprintln(os.Stderr, "warning: never reuse a keystream; XORing two messages with the same keystream reveals both")
+		if err := writeKeystream(*out, key, iv, *n); err != nil {
+			return fmt.Errorf("keystream: %w", err)
+		}
+	}
+	report(infoOutput, *dryRun, "wrote %d bytes of CTR keystream -> %s\n", *n, *out)
+	return nil
+}
+
+// generateKey returns a fresh random AES key of size bytes
+func generateKey(size int) ([]byte, error) {
+	if size != 16 && size != 24 && size != 32 {
+		return nil, fmt.Errorf("key size must be 16, 24 or 32 bytes, got %d", size)
+	}
+	return randomBytes(size)
+}
+
+func cmdGenKey(args []string) error {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	size := fs.Int("size", 32, "Key size in bytes: 16, 24 or 32")
+	asHex := fs.Bool("hex", true, "Print the key as hex (-hex=false writes the raw bytes)")
+	dryRun := fs.Bool("dry-run", false, "Validate the arguments without generating a key")
+	fs.Parse(args)
+	if *size != 16 && *size != 24 && *size != 32 {
+		return &usageError{fmt.Errorf("-size must be 16, 24 or 32, got %d", *size)}
+	}
+	if *dryRun {
+		// The key itself goes to stdout, so the summary never does
+		report(os.Stderr, true, "would generate a %d-byte key\n", *size)
+		return nil
+	}
+	key, err := generateKey(*size)
+	if err != nil {
+		return fmt.Errorf("genkey: %w", err)
+	}
+	defer Zeroize(key)
+	if *asHex {
+		_, err = fmt.Fprintln(stdout, hex.EncodeToString(key))
+	} else {
+		_, err = stdout.Write(key)
+	}
+	return err
+}
+
+// writeKeystream writes n bytes of CTR keystream to path
+func writeKeystream(path string, key, iv []byte, n int) error {
+	ks, err := CTRKeystreamBytes(key, iv, n)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, ks, 0600)
+}
+
+// readInput reads a whole input file, refusing anything over maxMemory bytes
+// (0 means no limit) before allocating for it. A path of "-" reads stdin.
+func readInput(path string, maxMemory int64) ([]byte, error) {
+	var r io.Reader = stdin
+	if path != "-" {
+		if maxMemory <= 0 {
+			return os.ReadFile(path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		st, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if st.Size() > maxMemory {
+			return nil, fmt.Errorf("%w: %d bytes is over the -max-memory cap of %d (use encrypt-stream for large files)", ErrInputTooLarge, st.Size(), maxMemory)
+		}
+		r = f
+	}
+	if maxMemory <= 0 {
+		return io.ReadAll(r)
+	}
+	// The file may grow after Stat, so never read past the cap
+	data, err := io.ReadAll(io.LimitReader(r, maxMemory+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxMemory {
+		return nil, fmt.Errorf("%w: over the -max-memory cap of %d", ErrInputTooLarge, maxMemory)
+	}
+	return data, nil
+}
+
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+
+	// encrypt-gcm files start with one byte holding the key length in bytes
+	// followed by the nonce
+	gcmFileHeaderSize = 1 + gcmNonceSize
+)
+
+// sealGCMFile produces the encrypt-gcm file layout described by DescribeFormat
+func sealGCMFile(data, key, nonce, aad []byte) ([]byte, error) {
+	ct, err := GCMEncrypt(data, key, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	// Format: key size (1 byte) || nonce (12 bytes) || ciphertext || tag (16 bytes)
+	buf := make([]byte, 0, 1+len(nonce)+len(ct))
+	buf = append(buf, byte(len(key)))
+	buf = append(buf, nonce...)
+	return append(buf, ct...), nil
+}
+
+// prependIV returns iv || ct in a new buffer. Appending to iv directly
+// would write ct into iv's spare capacity if it had any.
+func prependIV(iv, ct []byte) []byte {
+	buf := make([]byte, 0, len(iv)+len(ct))
+	buf = append(buf, iv...)
+	return append(buf, ct...)
+}
+
+// splitGCMFile checks the header of an encrypt-gcm file against key and
+// returns its nonce and ciphertext||tag
+func splitGCMFile(data, key []byte) (nonce, ct []byte, err error) {
+	if len(data) < gcmFileHeaderSize+gcmTagSize {
+		return nil, nil, fmt.Errorf("ciphertext file too short (must have header + tag)")
+	}
+	keySize := int(data[0])
+	if keySize != 16 && keySize != 24 && keySize != 32 {
+		return nil, nil, fmt.Errorf("unknown key size %d in header", keySize)
+	}
+	if keySize != len(key) {
+		return nil, nil, fmt.Errorf("file was encrypted with AES-%d but a %d-byte key was given", keySize*8, len(key))
+	}
+	return data[1:gcmFileHeaderSize], data[gcmFileHeaderSize:], nil
+}
+
+// legacyGCMFile reports whether data could be an encrypt-gcm file from
+// before the key size byte was added: nonce (12 bytes) || ciphertext || tag,
+// always under a 16-byte key. The first byte of such a file is random, so it
+// can't be told apart from the current layout by looking; callers try it
+// only when the current layout fails.
+func legacyGCMFile(data, key []byte) bool {
+	return len(key) == 16 && len(data) >= gcmNonceSize+gcmTagSize
+}
+
+// openGCMFile parses and decrypts a file produced by sealGCMFile, falling
+// back to the legacy layout without a key size byte
+func openGCMFile(data, key, aad []byte) ([]byte, error) {
+	nonce, ct, err := splitGCMFile(data, key)
+	if err == nil {
+		var pt []byte
+		if pt, err = GCMDecrypt(ct, key, nonce, aad); err == nil {
+			return pt, nil
+		}
+	}
+	if legacyGCMFile(data, key) {
+		if pt, lerr := GCMDecrypt(data[gcmNonceSize:], key, data[:gcmNonceSize], aad); lerr == nil {
+			return pt, nil
+		}
+	}
+	return nil, err
+}
+
+// verifyGCMFile checks the tag of a file produced by sealGCMFile without
+// decrypting it, accepting the legacy layout as openGCMFile does
+func verifyGCMFile(data, key, aad []byte) error {
+	nonce, ct, err := splitGCMFile(data, key)
+	if err == nil {
+		if err = GCMVerify(ct, key, nonce, aad); err == nil {
+			return nil
+		}
+	}
+	if legacyGCMFile(data, key) && GCMVerify(data[gcmNonceSize:], key, data[:gcmNonceSize], aad) == nil {
+		return nil
+	}
+	return err
+}
+
+// DescribeFormat returns the byte layout of files written by encrypt-gcm so
+// other tools can consume them
+func DescribeFormat() string {
+	return fmt.Sprintf(`encrypt-gcm file layout (AES-GCM, N = plaintext length):
+  offset 0, 1 byte: key size in bytes (16 = AES-128, 24 = AES-192, 32 = AES-256)
+  offset 1, %[1]d bytes: nonce (random)
+  offset %[2]d, N bytes: ciphertext
+  offset %[2]d+N, %[3]d bytes: authentication tag
+  total size: N+%[4]d bytes
+J0 = nonce || 0x00000001; the AAD is authenticated but not stored
+`, gcmNonceSize, gcmFileHeaderSize, gcmTagSize, gcmFileHeaderSize+gcmTagSize)
 }
 
 func main() {
 	if len(os.Args) < 2 {
 		usage()
 	}
-	switch os.Args[1] {
+	// -json-errors goes before the command and applies to all of them
+	jsonErrors := os.Args[1] == "-json-errors"
+	args := os.Args[1:]
+	if jsonErrors {
+		args = args[1:]
+		if len(args) == 0 {
+			usage()
+		}
+	}
+	if err := CheckRandom(); err != nil {
+		writeError(os.Stderr, err, jsonErrors)
+		os.Exit(1)
+	}
+	var err error
+	switch args[0] {
 	case "encrypt":
-		cmdEncrypt(os.Args[2:])
+		err = cmdEncrypt(args[1:])
 	case "decrypt":
-		cmdDecrypt(os.Args[2:])
+		err = cmdDecrypt(args[1:])
+	case "encrypt-ctr":
+		err = cmdEncryptCTR(args[1:])
+	case "decrypt-ctr":
+		err = cmdDecryptCTR(args[1:])
 	case "encrypt-gcm":
-		cmdEncryptGCM(os.Args[2:])
+		err = cmdEncryptGCM(args[1:])
 	case "decrypt-gcm":
-		cmdDecryptGCM(os.Args[2:])
+		err = cmdDecryptGCM(args[1:])
+	case "decrypt-auto", "open":
+		err = cmdDecryptAuto(args[1:])
+	case "encrypt-dir":
+		err = cmdEncryptDir(args[1:])
+	case "decrypt-dir":
+		err = cmdDecryptDir(args[1:])
+	case "verify-tree":
+		err = cmdVerifyTree(args[1:])
+	case "bench":
+		err = cmdBench(args[1:])
+	case "gen-vectors":
+		err = cmdGenVectors(args[1:])
+	case "keystream":
+		err = cmdKeystream(args[1:])
+	case "genkey":
+		err = cmdGenKey(args[1:])
+	case "encrypt-stream":
+		err = cmdEncryptStream(args[1:])
+	case "decrypt-stream":
+		err = cmdDecryptStream(args[1:])
 	default:
 		usage()
 	}
+	if err != nil {
+		writeError(os.Stderr, err, jsonErrors)
+		os.Exit(exitCode(err))
+	}
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// benchResult is one line of bench output
+type benchResult struct {
+	Mode        string  `json:"mode"`
+	KeySize     int     `json:"key_size"`
+	PayloadSize int     `json:"payload_size"`
+	MBPerSec    float64 `json:"mb_per_s"`
+	NsPerOp     int64   `json:"ns_per_op"`
+}
+
+// benchModes are the modes bench measures, each as an encrypt function
+var benchModes = []struct {
+	name string
+	run  func(data, key []byte) error
+}{
+	{"cbc", func(data, key []byte) error {
+		_, err := CBCEncrypt(data, key, make([]byte, 16))
+		return err
+	}},
+	{"ctr", func(data, key []byte) error {
+		_, err := CTREncrypt(data, key, make([]byte, 16))
+		return err
+	}},
+	{"gcm", func(data, key []byte) error {
+		_, err := GCMEncrypt(data, key, make([]byte, 12), nil)
+		return err
+	}},
+}
+
+// runBench encrypts a payloadSize-byte buffer iterations times in every mode
+// and key size and reports the average cost of one encryption
+func runBench(payloadSize, iterations int) ([]benchResult, error) {
+	data := make([]byte, payloadSize)
+	var results []benchResult
+	for _, m := range benchModes {
+		for _, keySize := range []int{16, 32} {
+			key := make([]byte, keySize)
+			start := time.Now()
+			for i := 0; i < iterations; i++ {
+				if err := m.run(data, key); err != nil {
+					return nil, fmt.Errorf("%s: %w", m.name, err)
+				}
+			}
+			elapsed := time.Since(start)
+			nsPerOp := elapsed.Nanoseconds() / int64(iterations)
+			var mbps float64
+			if elapsed > 0 {
+				mbps = float64(payloadSize) * float64(iterations) / elapsed.Seconds() / 1e6
+			}
+			results = append(results, benchResult{
+				Mode:        m.name,
+				KeySize:     keySize * 8,
+				PayloadSize: payloadSize,
+				MBPerSec:    mbps,
+				NsPerOp:     nsPerOp,
+			})
+		}
+	}
+	return results, nil
+}
+
+// writeBenchResults prints results as a table, or as a JSON array when
+// asJSON is set
+func writeBenchResults(w io.Writer, results []benchResult, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	fmt.Fprintf(w, "%-5s %-8s %12s %10s %14s\n", "mode", "key", "payload", "MB/s", "ns/op")
+	for _, r := range results {
+		fmt.Fprintf(w, "%-5s AES-%-4d %12d %10.2f %14d\n", r.Mode, r.KeySize, r.PayloadSize, r.MBPerSec, r.NsPerOp)
+	}
+	return nil
+}
+
+func cmdBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	size := fs.Int("size", 64<<10, "Payload size in bytes")
+	n := fs.Int("n", 5, "Encryptions per mode and key size")
+	asJSON := fs.Bool("json", false, "Print results as JSON")
+	dryRun := fs.Bool("dry-run", false, "Validate the arguments without running")
+	fs.Parse(args)
+	if *size < 0 || *n < 1 {
+		return &usageError{fmt.Errorf("-size must not be negative and -n must be at least 1")}
+	}
+	if *dryRun {
+		report(infoOutput, true, "would benchmark %d modes x 2 key sizes, %d x %d bytes each\n", len(benchModes), *n, *size)
+		return nil
+	}
+	results, err := runBench(*size, *n)
+	if err != nil {
+		return fmt.Errorf("bench: %w", err)
+	}
+	return writeBenchResults(os.Stdout, results, *asJSON)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestBenchJSON(t *testing.T) {
+	results, err := runBench(1024, 2)
+	if err != nil {
+		t.Fatalf("runBench failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeBenchResults(&buf, results, true); err != nil {
+		t.Fatalf("writeBenchResults failed: %v", err)
+	}
+
+	var parsed []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(parsed) != len(benchModes)*2 {
+		t.Fatalf("Got %d results, want %d", len(parsed), len(benchModes)*2)
+	}
+	for _, r := range parsed {
+		for _, field := range []string{"mode", "key_size", "payload_size", "mb_per_s", "ns_per_op"} {
+			if _, ok := r[field]; !ok {
+				t.Errorf("Result %v missing field %q", r, field)
+			}
+		}
+		if r["payload_size"] != float64(1024) {
+			t.Errorf("payload_size = %v, want 1024", r["payload_size"])
+		}
+		if ks := r["key_size"]; ks != float64(128) && ks != float64(256) {
+			t.Errorf("key_size = %v, want 128 or 256", ks)
+		}
+	}
+}
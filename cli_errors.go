@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// exitCode maps a command error to the process exit status: 2 for usage
+// errors, 1 for everything else
+func exitCode(err error) int {
+	var ue *usageError
+	if errors.As(err, &ue) {
+		return 2
+	}
+	return 1
+}
+
+// errorKind classifies a command error for -json-errors output
+func errorKind(err error) string {
+	var ue *usageError
+	switch {
+	case errors.As(err, &ue):
+		return "usage"
+	case errors.Is(err, ErrAuthFailed):
+		return "auth_failure"
+	case errors.Is(err, os.ErrNotExist):
+		return "not_found"
+	case errors.Is(err, ErrInputTooLarge):
+		return "input_too_large"
+	}
+	return "error"
+}
+
+// writeError prints err to w, as a single-line JSON object
+// {"error":..., "code":..., "kind":...} if asJSON is set
+func writeError(w io.Writer, err error, asJSON bool) {
+	if !asJSON {
+		fmt.Fprintln(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+		Kind  string `json:"kind"`
+	}{err.Error(), exitCode(err), errorKind(err)})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONErrorsAuthFailure(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	ct := filepath.Join(dir, "ct.bin")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("json errors"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-key", "1234567890123456"}); err != nil {
+		t.Fatalf("encrypt-gcm failed: %v", err)
+	}
+	err := cmdDecryptGCM([]string{"-in", ct, "-out", out, "-key", "6543210987654321"})
+	if err == nil {
+		t.Fatal("Expected decrypt-gcm to fail with the wrong key")
+	}
+
+	var stderr bytes.Buffer
+	writeError(&stderr, err, true)
+	line := stderr.Bytes()
+	if bytes.Count(line, []byte("\n")) != 1 || line[len(line)-1] != '\n' {
+		t.Errorf("Expected a single line, got %q", line)
+	}
+	var got struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+		Kind  string `json:"kind"`
+	}
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Kind != "auth_failure" {
+		t.Errorf("Kind %q, want auth_failure", got.Kind)
+	}
+	if got.Code != 1 {
+		t.Errorf("Code %d, want 1", got.Code)
+	}
+	if got.Error != err.Error() {
+		t.Errorf("Error %q, want %q", got.Error, err.Error())
+	}
+}
+
+func TestJSONErrorsUsage(t *testing.T) {
+	err := cmdEncryptGCM([]string{"-in", "x", "-out", "y", "-key", "short"})
+	var stderr bytes.Buffer
+	writeError(&stderr, err, true)
+	var got struct {
+		Code int    `json:"code"`
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(stderr.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Code != 2 || got.Kind != "usage" {
+		t.Errorf("Got code %d kind %q, want 2 usage", got.Code, got.Kind)
+	}
+}
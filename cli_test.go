@@ -0,0 +1,818 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDescribeFormatMatchesEncoder(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	plaintext := []byte("layout check")
+	aad := []byte("aad")
+
+	buf, err := sealGCMFile(plaintext, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("sealGCMFile failed: %v", err)
+	}
+
+	desc := DescribeFormat()
+	for _, want := range []string{
+		"offset 0, 1 byte: key size",
+		fmt.Sprintf("offset 1, %d bytes: nonce", len(nonce)),
+		fmt.Sprintf("offset %d, N bytes: ciphertext", 1+len(nonce)),
+		fmt.Sprintf("offset %d+N, 16 bytes: authentication tag", 1+len(nonce)),
+		fmt.Sprintf("total size: N+%d bytes", len(buf)-len(plaintext)),
+	} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("DescribeFormat missing %q", want)
+		}
+	}
+
+	// Split the encoder output exactly as the description says
+	n := len(plaintext)
+	if buf[0] != byte(len(key)) {
+		t.Errorf("Key size byte %d, want %d", buf[0], len(key))
+	}
+	if !bytes.Equal(buf[1:1+gcmNonceSize], nonce) {
+		t.Error("Nonce not found at offset 1")
+	}
+	ct := buf[gcmFileHeaderSize : gcmFileHeaderSize+n]
+	tag := buf[gcmFileHeaderSize+n:]
+	if len(tag) != gcmTagSize {
+		t.Fatalf("Tag length %d, want %d", len(tag), gcmTagSize)
+	}
+	want, err := GCMEncrypt(plaintext, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(ct, want[:n]) || !bytes.Equal(tag, want[n:]) {
+		t.Error("Ciphertext/tag don't sit where DescribeFormat says")
+	}
+
+	pt, err := openGCMFile(buf, key, aad)
+	if err != nil {
+		t.Fatalf("openGCMFile failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Error("Decrypted text doesn't match")
+	}
+}
+
+func TestGCMFileAES256(t *testing.T) {
+	hexKey := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	key, err := decodeKey("", hexKey)
+	if err != nil {
+		t.Fatalf("decodeKey failed: %v", err)
+	}
+	if len(key) != 32 {
+		t.Fatalf("Key length %d, want 32", len(key))
+	}
+	plaintext := []byte("AES-256-GCM through the CLI helpers")
+
+	buf, err := sealGCMFile(plaintext, key, []byte("123456789012"), nil)
+	if err != nil {
+		t.Fatalf("sealGCMFile failed: %v", err)
+	}
+	if buf[0] != 32 {
+		t.Errorf("Key size byte %d, want 32", buf[0])
+	}
+	pt, err := openGCMFile(buf, key, nil)
+	if err != nil {
+		t.Fatalf("openGCMFile failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Error("Decrypted text doesn't match")
+	}
+
+	// Decrypting an AES-256 file with a 16-byte key is rejected up front
+	_, err = openGCMFile(buf, []byte("1234567890123456"), nil)
+	if err == nil || !strings.Contains(err.Error(), "AES-256") {
+		t.Errorf("Expected key size mismatch error, got %v", err)
+	}
+
+	if _, err := decodeKey("", hexKey[:40]); err == nil {
+		t.Error("Expected error for 20-byte hex key")
+	}
+
+	key, err = decodeKey("12345678901234567890123456789012", "")
+	if err != nil || len(key) != 32 {
+		t.Errorf("Expected 32-byte -key to select AES-256, got %d bytes, %v", len(key), err)
+	}
+	if _, err := decodeKey("12345678901234567890", ""); err == nil {
+		t.Error("Expected error for 20-byte key string")
+	}
+}
+
+func TestGCMFileLegacyLayout(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := []byte("written before the key size byte")
+	aad := []byte("aad")
+
+	// The baseline encrypt-gcm wrote nonce || ciphertext || tag. A nonce
+	// starting with 16 also parses as a current header for this key.
+	for _, nonce := range [][]byte{[]byte("123456789012"), append([]byte{16}, "23456789012"...)} {
+		ct, err := GCMEncrypt(plaintext, key, nonce, aad)
+		if err != nil {
+			t.Fatalf("GCMEncrypt failed: %v", err)
+		}
+		legacy := append(append([]byte(nil), nonce...), ct...)
+
+		pt, err := openGCMFile(legacy, key, aad)
+		if err != nil {
+			t.Fatalf("openGCMFile of legacy file (nonce %x) failed: %v", nonce, err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Error("Decrypted text doesn't match")
+		}
+		if err := verifyGCMFile(legacy, key, aad); err != nil {
+			t.Errorf("verifyGCMFile of legacy file failed: %v", err)
+		}
+
+		legacy[len(legacy)-1] ^= 0x01
+		if _, err := openGCMFile(legacy, key, aad); err == nil {
+			t.Error("Expected tampered legacy file to fail")
+		}
+		if err := verifyGCMFile(legacy, key, aad); err == nil {
+			t.Error("Expected tampered legacy file to fail verification")
+		}
+	}
+}
+
+func TestWriteKeystreamMatchesCTR(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	data := []byte("file contents that will be XORed with the keystream")
+
+	path := filepath.Join(t.TempDir(), "ks.bin")
+	if err := writeKeystream(path, key, iv, len(data)); err != nil {
+		t.Fatalf("writeKeystream failed: %v", err)
+	}
+	ks, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(ks) != len(data) {
+		t.Fatalf("Keystream length %d, want %d", len(ks), len(data))
+	}
+
+	xored := make([]byte, len(data))
+	for i := range data {
+		xored[i] = data[i] ^ ks[i]
+	}
+	want, err := CTREncrypt(data, key, iv)
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+	if !bytes.Equal(xored, want) {
+		t.Error("Data XOR keystream doesn't match CTREncrypt")
+	}
+}
+
+func TestReadInputMaxMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.bin")
+	if err := os.WriteFile(path, make([]byte, 1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readInput(path, 999); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge, got %v", err)
+	}
+	for _, limit := range []int64{0, 1000, 1 << 20} {
+		data, err := readInput(path, limit)
+		if err != nil {
+			t.Fatalf("readInput(%d) failed: %v", limit, err)
+		}
+		if len(data) != 1000 {
+			t.Errorf("readInput(%d) returned %d bytes, want 1000", limit, len(data))
+		}
+	}
+}
+
+func TestDryRunWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "plain.txt")
+	out := filepath.Join(dir, "out.bin")
+	if err := os.WriteFile(in, []byte("dry run input"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := map[string]func([]string) error{
+		"encrypt":        cmdEncrypt,
+		"encrypt-ctr":    cmdEncryptCTR,
+		"encrypt-gcm":    cmdEncryptGCM,
+		"encrypt-stream": cmdEncryptStream,
+	}
+	for name, cmd := range cmds {
+		err := cmd([]string{"-in", in, "-out", out, "-key", "1234567890123456", "-dry-run"})
+		if err != nil {
+			t.Fatalf("%s -dry-run failed: %v", name, err)
+		}
+		if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s -dry-run created output file", name)
+		}
+
+		// Validation still runs and is reported
+		err = cmd([]string{"-in", in, "-out", out, "-key", "short", "-dry-run"})
+		var ue *usageError
+		if !errors.As(err, &ue) {
+			t.Errorf("%s -dry-run with bad key: expected usage error, got %v", name, err)
+		}
+		err = cmd([]string{"-in", filepath.Join(dir, "missing"), "-out", out, "-key", "1234567890123456", "-dry-run"})
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s -dry-run with missing input: expected ErrNotExist, got %v", name, err)
+		}
+	}
+
+	err := cmdEncrypt([]string{"-in", in, "-out", out, "-key", "1234567890123456", "-max-memory", "4", "-dry-run"})
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge, got %v", err)
+	}
+
+	// Decryption under -dry-run still authenticates the input
+	ct := filepath.Join(dir, "ct.bin")
+	if err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-key", "1234567890123456"}); err != nil {
+		t.Fatalf("encrypt-gcm failed: %v", err)
+	}
+	if err := cmdDecryptGCM([]string{"-in", ct, "-out", out, "-key", "1234567890123456", "-dry-run"}); err != nil {
+		t.Fatalf("decrypt-gcm -dry-run failed: %v", err)
+	}
+	if err := cmdDecryptGCM([]string{"-in", ct, "-out", out, "-key", "6543210987654321", "-dry-run"}); err == nil {
+		t.Error("Expected decrypt-gcm -dry-run to fail with the wrong key")
+	}
+	if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+		t.Error("decrypt-gcm -dry-run created output file")
+	}
+}
+
+func TestDryRunReportsThroughInfoOutput(t *testing.T) {
+	saved := infoOutput
+	defer func() { infoOutput = saved }()
+	var buf bytes.Buffer
+	infoOutput = &buf
+
+	dir := t.TempDir()
+	for name, run := range map[string]func() error{
+		"verify-tree": func() error { return cmdVerifyTree([]string{"-in", dir, "-key", "1234567890123456", "-dry-run"}) },
+		"bench":       func() error { return cmdBench([]string{"-dry-run"}) },
+		"gen-vectors": func() error { return cmdGenVectors([]string{"-dry-run"}) },
+	} {
+		buf.Reset()
+		if err := run(); err != nil {
+			t.Fatalf("%s -dry-run failed: %v", name, err)
+		}
+		if !strings.HasPrefix(buf.String(), "dry run, nothing written: would ") {
+			t.Errorf("%s -dry-run reported %q", name, buf.String())
+		}
+	}
+}
+
+func TestDecryptStreamBestEffort(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("1234567890123456")
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var buf bytes.Buffer
+	sw, err := NewGCMStreamWriter(&buf, key, 100)
+	if err != nil {
+		t.Fatalf("NewGCMStreamWriter failed: %v", err)
+	}
+	sw.Write(data)
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// Cut the file halfway through the fifth frame
+	frameSize := streamFrameHdrSize + 100 + 16
+	truncated := buf.Bytes()[:streamHeaderSize+4*frameSize+frameSize/2]
+	in := filepath.Join(dir, "truncated.aess")
+	if err := os.WriteFile(in, truncated, 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "recovered.bin")
+	args := []string{"-in", in, "-out", out, "-key", string(key)}
+
+	if err := cmdDecryptStream(args); !errors.Is(err, ErrStreamTruncated) {
+		t.Errorf("Expected ErrStreamTruncated without -best-effort, got %v", err)
+	}
+	if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Output left behind without -best-effort")
+	}
+
+	if err := cmdDecryptStream(append(args, "-best-effort")); err != nil {
+		t.Fatalf("decrypt-stream -best-effort failed: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(got, data[:400]) {
+		t.Errorf("Recovered %d bytes, want the first 400", len(got))
+	}
+}
+
+func TestRepeatedAADFlags(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "plain.txt")
+	ct := filepath.Join(dir, "ct.gcm")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("two aad segments"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	base := []string{"-key", "1234567890123456"}
+
+	err := cmdEncryptGCM(append([]string{"-in", in, "-out", ct, "-aad", "first", "-aad", "second"}, base...))
+	if err != nil {
+		t.Fatalf("encrypt-gcm failed: %v", err)
+	}
+	err = cmdDecryptGCM(append([]string{"-in", ct, "-out", out, "-aad", "first", "-aad", "second"}, base...))
+	if err != nil {
+		t.Fatalf("decrypt-gcm with the same -aad order failed: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil || string(got) != "two aad segments" {
+		t.Errorf("Decrypted %q, %v", got, err)
+	}
+
+	err = cmdDecryptGCM(append([]string{"-in", ct, "-out", out, "-aad", "second", "-aad", "first"}, base...))
+	if err == nil {
+		t.Error("Expected decrypt-gcm to fail with -aad values reordered")
+	}
+	err = cmdDecryptGCM(append([]string{"-in", ct, "-out", out, "-aad", "firstsecond"}, base...))
+	if err == nil {
+		t.Error("Expected decrypt-gcm to fail with -aad values joined")
+	}
+}
+
+func TestOpenTruncatedFramedFile(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "plain.txt")
+	ct := filepath.Join(dir, "ct.bin")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("framed input"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-key", "1234567890123456", "-framed"}); err != nil {
+		t.Fatalf("encrypt-gcm -framed failed: %v", err)
+	}
+	data, err := os.ReadFile(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		n    int
+		want error
+	}{
+		{envelopeHeaderSize - 2, ErrTruncatedHeader},
+		{envelopeHeaderSize, ErrTruncatedBody},
+	} {
+		truncated := filepath.Join(dir, "truncated.bin")
+		if err := os.WriteFile(truncated, data[:tc.n], 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := cmdDecryptAuto([]string{"-in", truncated, "-out", out, "-key", "1234567890123456"})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%d bytes: expected %v, got %v", tc.n, tc.want, err)
+		}
+	}
+}
+
+func TestPasswordFlagRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	ct := filepath.Join(dir, "ct.bin")
+	out := filepath.Join(dir, "out.txt")
+	plaintext := []byte("typed a password instead of a key")
+	if err := os.WriteFile(in, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-password", "correct horse"}); err != nil {
+		t.Fatalf("encrypt-gcm -password failed: %v", err)
+	}
+	blob, err := os.ReadFile(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(blob, []byte(passwordMagic)) {
+		t.Error("Output doesn't start with the password header")
+	}
+	if err := cmdDecryptGCM([]string{"-in", ct, "-out", out, "-password", "correct horse"}); err != nil {
+		t.Fatalf("decrypt-gcm -password failed: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	err = cmdDecryptGCM([]string{"-in", ct, "-out", out, "-password", "wrong horse"})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for wrong password, got %v", err)
+	}
+	err = cmdEncryptGCM([]string{"-in", in, "-out", ct, "-password", "pw", "-key", "1234567890123456"})
+	if _, ok := err.(*usageError); !ok {
+		t.Errorf("Expected usage error for -password with -key, got %v", err)
+	}
+}
+
+func TestStdinStdoutPipe(t *testing.T) {
+	savedIn, savedOut, savedInfo := stdin, stdout, infoOutput
+	defer func() { stdin, stdout, infoOutput = savedIn, savedOut, savedInfo }()
+	var info bytes.Buffer
+	infoOutput = &info
+	key := []string{"-key", "1234567890123456"}
+	plaintext := []byte("piped through the tool")
+
+	for _, cmd := range []struct {
+		name    string
+		enc     func([]string) error
+		dec     func([]string) error
+		extract func([]byte) ([]byte, error)
+	}{
+		{"cbc", cmdEncrypt, cmdDecrypt, nil},
+		{"gcm", cmdEncryptGCM, cmdDecryptGCM, func(b []byte) ([]byte, error) {
+			return openGCMFile(b, []byte("1234567890123456"), nil)
+		}},
+	} {
+		var ct bytes.Buffer
+		stdin, stdout = bytes.NewReader(plaintext), &ct
+		if err := cmd.enc(append([]string{"-in", "-", "-out", "-"}, key...)); err != nil {
+			t.Fatalf("%s: encrypt from stdin failed: %v", cmd.name, err)
+		}
+		// Only ciphertext goes to stdout; the summary line moves to stderr
+		if cmd.extract != nil {
+			if _, err := cmd.extract(ct.Bytes()); err != nil {
+				t.Errorf("%s: stdout isn't a clean ciphertext: %v", cmd.name, err)
+			}
+		}
+		if bytes.Contains(ct.Bytes(), []byte("encrypted")) {
+			t.Errorf("%s: summary line mixed into stdout", cmd.name)
+		}
+		if info.Len() != 0 {
+			t.Errorf("%s: summary line went to infoOutput, not stderr: %q", cmd.name, info.String())
+		}
+
+		var pt bytes.Buffer
+		stdin, stdout = bytes.NewReader(ct.Bytes()), &pt
+		if err := cmd.dec(append([]string{"-in", "-", "-out", "-"}, key...)); err != nil {
+			t.Fatalf("%s: decrypt from stdin failed: %v", cmd.name, err)
+		}
+		if !bytes.Equal(pt.Bytes(), plaintext) {
+			t.Errorf("%s: piped round trip doesn't match: %q", cmd.name, pt.Bytes())
+		}
+	}
+
+	// -max-memory still applies to stdin
+	stdin, stdout = bytes.NewReader(make([]byte, 100)), io.Discard
+	err := cmdEncryptGCM(append([]string{"-in", "-", "-out", "-", "-max-memory", "10"}, key...))
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge from stdin, got %v", err)
+	}
+}
+
+func TestCTRCommandsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	ct := filepath.Join(dir, "ct.bin")
+	out := filepath.Join(dir, "out.txt")
+	plaintext := []byte("counter mode from the command line")
+	if err := os.WriteFile(in, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+	if err := cmdEncryptCTR(append([]string{"-in", in, "-out", ct}, key...)); err != nil {
+		t.Fatalf("encrypt-ctr failed: %v", err)
+	}
+	blob, err := os.ReadFile(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blob) != 16+len(plaintext) {
+		t.Errorf("Output length %d, want IV + %d", len(blob), len(plaintext))
+	}
+	want, err := CTREncrypt(plaintext, []byte("1234567890123456"), blob[:16])
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+	if !bytes.Equal(blob[16:], want) {
+		t.Error("encrypt-ctr output isn't IV || CTREncrypt")
+	}
+	if err := cmdDecryptCTR(append([]string{"-in", ct, "-out", out}, key...)); err != nil {
+		t.Fatalf("decrypt-ctr failed: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestDecryptAutoDetectsMode(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	plaintext := []byte("one command decrypts them all")
+	if err := os.WriteFile(in, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+	for name, encrypt := range map[string]func([]string) error{
+		"encrypt":     cmdEncrypt,
+		"encrypt-ctr": cmdEncryptCTR,
+		"encrypt-gcm": cmdEncryptGCM,
+	} {
+		ct := filepath.Join(dir, name+".aesx")
+		out := filepath.Join(dir, name+".out")
+		if err := encrypt(append([]string{"-in", in, "-out", ct, "-framed"}, key...)); err != nil {
+			t.Fatalf("%s -framed failed: %v", name, err)
+		}
+		if name != "encrypt-gcm" {
+			// Unauthenticated modes need the opt-in
+			err := cmdDecryptAuto(append([]string{"-in", ct, "-out", out}, key...))
+			if !errors.Is(err, ErrModeNotAllowed) {
+				t.Errorf("decrypt-auto of %s output without -allow-unauthenticated: %v", name, err)
+			}
+		}
+		if err := cmdDecryptAuto(append([]string{"-in", ct, "-out", out, "-allow-unauthenticated"}, key...)); err != nil {
+			t.Fatalf("decrypt-auto of %s output failed: %v", name, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: decrypted text doesn't match", name)
+		}
+	}
+}
+
+func TestDecryptAutoRejectsForgedMode(t *testing.T) {
+	dir := t.TempDir()
+	ct := filepath.Join(dir, "ct.aesx")
+	key := []byte("1234567890123456")
+	blob, err := SealEnvelope(ModeGCM, []byte("pay alice $100"), key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	forged := forgeCTREnvelope(t, blob)
+	forged[len(forged)-4] ^= '$' ^ ','
+	if err := os.WriteFile(ct, forged, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.txt")
+	err = cmdDecryptAuto([]string{"-in", ct, "-out", out, "-key", string(key)})
+	if !errors.Is(err, ErrModeNotAllowed) {
+		t.Errorf("decrypt-auto of a relabelled GCM file: %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("Output written for a forged file: %v", err)
+	}
+}
+
+func TestPrependIVDoesNotAlias(t *testing.T) {
+	// An IV with spare capacity, as a future RandomIV might return
+	backing := bytes.Repeat([]byte{0xee}, 64)
+	iv := backing[:16]
+	copy(iv, "abcdefghijklmnop")
+	ct := bytes.Repeat([]byte{0x11}, 32)
+
+	buf := prependIV(iv, ct)
+	if !bytes.Equal(buf, append(append([]byte(nil), iv...), ct...)) {
+		t.Fatal("prependIV output isn't iv || ct")
+	}
+	if !bytes.Equal(backing[16:], bytes.Repeat([]byte{0xee}, 48)) {
+		t.Error("prependIV wrote into the IV's spare capacity")
+	}
+	buf[0] ^= 0xff
+	if iv[0] != 'a' {
+		t.Error("prependIV output shares memory with the IV")
+	}
+
+	nonce := make([]byte, 12, 40)
+	out, err := sealGCMFile([]byte("data"), []byte("1234567890123456"), nonce, nil)
+	if err != nil {
+		t.Fatalf("sealGCMFile failed: %v", err)
+	}
+	out[1] ^= 0xff
+	if nonce[0] != 0 || !bytes.Equal(nonce[:cap(nonce)], make([]byte, 40)) {
+		t.Error("sealGCMFile output shares memory with the nonce")
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		a, err := generateKey(size)
+		if err != nil {
+			t.Fatalf("generateKey(%d) failed: %v", size, err)
+		}
+		b, _ := generateKey(size)
+		if len(a) != size || len(b) != size {
+			t.Errorf("generateKey(%d) returned %d bytes", size, len(a))
+		}
+		if bytes.Equal(a, b) {
+			t.Errorf("generateKey(%d) returned the same key twice", size)
+		}
+		if _, err := NewCipher(a); err != nil {
+			t.Errorf("generateKey(%d) gave an unusable key: %v", size, err)
+		}
+	}
+	if _, err := generateKey(20); err == nil {
+		t.Error("Expected error for a 20-byte key")
+	}
+}
+
+func TestGenKeyCommand(t *testing.T) {
+	saved := stdout
+	defer func() { stdout = saved }()
+
+	var out bytes.Buffer
+	stdout = &out
+	if err := cmdGenKey([]string{"-size", "24"}); err != nil {
+		t.Fatalf("genkey failed: %v", err)
+	}
+	key, err := decodeHexKey(strings.TrimSuffix(out.String(), "\n"))
+	if err != nil || len(key) != 24 {
+		t.Errorf("genkey printed %q, want 48 hex characters: %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := cmdGenKey([]string{"-hex=false"}); err != nil {
+		t.Fatalf("genkey -hex=false failed: %v", err)
+	}
+	if out.Len() != 32 {
+		t.Errorf("genkey -hex=false wrote %d bytes, want 32", out.Len())
+	}
+
+	var usage *usageError
+	if err := cmdGenKey([]string{"-size", "8"}); !errors.As(err, &usage) {
+		t.Errorf("Expected usage error for -size 8, got %v", err)
+	}
+}
+
+// keyFlagSet has the key flags every command defines
+func keyFlagSet(args ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("key", "", "")
+	fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	fs.Parse(args)
+	return fs
+}
+
+func TestParseKeyFile(t *testing.T) {
+	t.Setenv("AES_KEY", "")
+	dir := t.TempDir()
+	want := []byte("1234567890123456")
+	for name, data := range map[string]string{
+		"raw":        "1234567890123456",
+		"raw-lf":     "1234567890123456\n",
+		"raw-crlf":   "1234567890123456\r\n",
+		"hex-spaces": "  31323334353637383930313233343536  \n",
+		"hex-prefix": "hex:31323334353637383930313233343536\n",
+		"raw-prefix": "raw:1234567890123456\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		key, err := parseKey(keyFlagSet("-keyfile", path))
+		if err != nil {
+			t.Errorf("%s: parseKey failed: %v", name, err)
+		} else if !bytes.Equal(key, want) {
+			t.Errorf("%s: key %q, want %q", name, key, want)
+		}
+	}
+
+	// A raw key whose last byte happens to be a newline keeps it
+	path := filepath.Join(dir, "raw-nl-byte")
+	nlKey := []byte("123456789012345\n")
+	if err := os.WriteFile(path, nlKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := parseKey(keyFlagSet("-keyfile", path)); err != nil || !bytes.Equal(key, nlKey) {
+		t.Errorf("Key ending in a newline byte read as %q, %v", key, err)
+	}
+
+	// 32 hex digits are also a 32-byte raw key, so without a prefix the
+	// file is rejected rather than read as either
+	path = filepath.Join(dir, "ambiguous")
+	if err := os.WriteFile(path, []byte("31323334353637383930313233343536\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseKey(keyFlagSet("-keyfile", path)); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected ambiguous key error, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("raw:31323334353637383930313233343536"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := parseKey(keyFlagSet("-keyfile", path)); err != nil || string(key) != "31323334353637383930313233343536" {
+		t.Errorf("raw: key file read as %q, %v", key, err)
+	}
+
+	path = filepath.Join(dir, "short")
+	if err := os.WriteFile(path, []byte("too short\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseKey(keyFlagSet("-keyfile", path)); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected error naming %s, got %v", path, err)
+	}
+	if _, err := parseKey(keyFlagSet("-keyfile", filepath.Join(dir, "missing"))); err == nil {
+		t.Error("Expected error for missing key file")
+	}
+	if _, err := parseKey(keyFlagSet("-keyfile", path, "-key", "1234567890123456")); err == nil {
+		t.Error("Expected error for -keyfile with -key")
+	}
+}
+
+func TestParseKeyEnv(t *testing.T) {
+	t.Setenv("AES_KEY", "hex:31323334353637383930313233343536")
+	key, err := parseKey(keyFlagSet())
+	if err != nil || string(key) != "1234567890123456" {
+		t.Errorf("AES_KEY hex read as %q, %v", key, err)
+	}
+
+	t.Setenv("AES_KEY", "31323334353637383930313233343536")
+	if _, err := parseKey(keyFlagSet()); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected ambiguous AES_KEY error, got %v", err)
+	}
+
+	// Flags take precedence over the environment
+	key, err = parseKey(keyFlagSet("-key", "abcdefghijklmnop"))
+	if err != nil || string(key) != "abcdefghijklmnop" {
+		t.Errorf("-key with AES_KEY set read as %q, %v", key, err)
+	}
+
+	t.Setenv("AES_KEY", "abcdefghijklmnopqrstuvwx")
+	if key, err := parseKey(keyFlagSet()); err != nil || len(key) != 24 {
+		t.Errorf("AES_KEY raw read as %q, %v", key, err)
+	}
+
+	t.Setenv("AES_KEY", "short")
+	if _, err := parseKey(keyFlagSet()); err == nil || !strings.Contains(err.Error(), "AES_KEY") {
+		t.Errorf("Expected error naming AES_KEY, got %v", err)
+	}
+
+	t.Setenv("AES_KEY", "")
+	if _, err := parseKey(keyFlagSet()); err == nil {
+		t.Error("Expected error with no key given")
+	}
+}
+
+func TestArmorCommandsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	plaintext := []byte("paste me into an email")
+	if err := os.WriteFile(in, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+	pairs := []struct {
+		name    string
+		encrypt func([]string) error
+		decrypt func([]string) error
+		extra   []string
+	}{
+		{"cbc", cmdEncrypt, cmdDecrypt, nil},
+		{"ctr", cmdEncryptCTR, cmdDecryptCTR, nil},
+		{"gcm", cmdEncryptGCM, cmdDecryptGCM, nil},
+		{"framed", cmdEncryptGCM, cmdDecryptAuto, []string{"-framed"}},
+	}
+	for _, p := range pairs {
+		ct := filepath.Join(dir, p.name+".asc")
+		out := filepath.Join(dir, p.name+".txt")
+		args := append([]string{"-in", in, "-out", ct, "-armor"}, p.extra...)
+		if err := p.encrypt(append(args, key...)); err != nil {
+			t.Fatalf("%s: encrypt -armor failed: %v", p.name, err)
+		}
+		armored, err := os.ReadFile(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(armored), "-----BEGIN AES MESSAGE-----\n") {
+			t.Errorf("%s: output isn't armored: %q", p.name, armored)
+		}
+		if err := p.decrypt(append([]string{"-in", ct, "-out", out}, key...)); err != nil {
+			t.Fatalf("%s: decrypt of armored file failed: %v", p.name, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: decrypted text doesn't match", p.name)
+		}
+	}
+}
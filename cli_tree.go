@@ -0,0 +1,250 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// treeFailure records a file that failed verification
+type treeFailure struct {
+	Path string
+	Err  error
+}
+
+// treeFiles returns every regular file under dir
+func treeFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// verifyTree checks the GCM tag of every regular file under dir using the
+// given number of workers. It returns the number of files that passed and
+// the failures sorted by path.
+func verifyTree(dir string, key, aad []byte, workers int) (int, []treeFailure, error) {
+	paths, err := treeFiles(dir)
+	if err != nil {
+		return 0, nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan string)
+	var (
+		mu       sync.Mutex
+		passed   int
+		failures []treeFailure
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				data, err := os.ReadFile(path)
+				if err == nil {
+					err = verifyGCMFile(data, key, aad)
+				}
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, treeFailure{Path: path, Err: err})
+				} else {
+					passed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Path < failures[j].Path })
+	return passed, failures, nil
+}
+
+func cmdVerifyTree(args []string) error {
+	fs := flag.NewFlagSet("verify-tree", flag.ExitOnError)
+	in := fs.String("in", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	var aad aadFlag
+	fs.Var(&aad, "aad", "Additional authenticated data (repeat for multiple segments)")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of files verified in parallel")
+	dryRun := fs.Bool("dry-run", false, "List the files that would be verified without reading them")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if *in == "" {
+		usage()
+	}
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+	if *dryRun {
+		paths, err := treeFiles(*in)
+		if err != nil {
+			return fmt.Errorf("verify-tree: %w", err)
+		}
+		report(infoOutput, true, "would verify %d files under %s\n", len(paths), *in)
+		return nil
+	}
+	passed, failures, err := verifyTree(*in, key, aad.bytes(), *workers)
+	if err != nil {
+		return fmt.Errorf("verify-tree: %w", err)
+	}
+	for _, f := range failures {
+		fmt.Fprintf(infoOutput, "FAIL %s: %v\n", f.Path, f.Err)
+	}
+	report(infoOutput, false, "verified %d files: %d passed, %d failed\n", passed+len(failures), passed, len(failures))
+	if len(failures) > 0 {
+		return fmt.Errorf("verify-tree: %d of %d files failed verification", len(failures), passed+len(failures))
+	}
+	return nil
+}
+
+// transformTree applies fn to every regular file under src and writes the
+// result to the same relative path under dst. Symlinks are skipped, not
+// followed. A file that can't be read, transformed or written is recorded
+// as a failure and the walk carries on; only a bad src or dst stops it.
+// Under dryRun nothing is written.
+func transformTree(src, dst string, dryRun bool, fn func([]byte) ([]byte, error)) (done, skipped int, failures []treeFailure, err error) {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	// Writing into the tree being walked would process the outputs too. Only
+	// a leading ".." element leads out of src; "..out" is a name inside it.
+	if rel, err := filepath.Rel(absSrc, absDst); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return 0, 0, nil, fmt.Errorf("output directory %s is inside %s", dst, src)
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == src {
+				return err
+			}
+			failures = append(failures, treeFailure{Path: path, Err: err})
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			skipped++
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if err := transformFile(path, filepath.Join(dst, rel), dryRun, fn); err != nil {
+			failures = append(failures, treeFailure{Path: path, Err: err})
+			return nil
+		}
+		done++
+		return nil
+	})
+	return done, skipped, failures, err
+}
+
+// transformFile is one file's worth of transformTree
+func transformFile(in, out string, dryRun bool, fn func([]byte) ([]byte, error)) error {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	result, err := fn(data)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(out, result, 0600)
+}
+
+// cmdTransformTree runs encrypt-dir or decrypt-dir
+func cmdTransformTree(name string, args []string, encrypt bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	in := fs.String("in", "", "")
+	out := fs.String("out", "", "")
+	keyStr := fs.String("key", "", "")
+	hexKey := fs.String("hexkey", "", "")
+	fs.String("keyfile", "", "")
+	var aad aadFlag
+	fs.Var(&aad, "aad", "Additional authenticated data (repeat for multiple segments)")
+	dryRun := fs.Bool("dry-run", false, "Process every file but write no output")
+	_ = keyStr
+	_ = hexKey
+	fs.Parse(args)
+	if *in == "" || *out == "" {
+		usage()
+	}
+	key, err := parseKey(fs)
+	if err != nil {
+		return err
+	}
+	defer Zeroize(key)
+
+	verb := "decrypted"
+	fn := func(data []byte) ([]byte, error) { return openGCMFile(data, key, aad.bytes()) }
+	if encrypt {
+		verb = "encrypted"
+		fn = func(data []byte) ([]byte, error) {
+			nonce, err := RandomNonce()
+			if err != nil {
+				return nil, err
+			}
+			return sealGCMFile(data, key, nonce, aad.bytes())
+		}
+	}
+	done, skipped, failures, err := transformTree(*in, *out, *dryRun, fn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, f := range failures {
+		fmt.Fprintf(infoOutput, "FAIL %s: %v\n", f.Path, f.Err)
+	}
+	report(infoOutput, *dryRun, "%s %d files from %s into %s: %d failed, %d symlinks skipped\n", verb, done, *in, *out, len(failures), skipped)
+	if len(failures) > 0 {
+		return fmt.Errorf("%s: %d of %d files failed", name, len(failures), done+len(failures))
+	}
+	return nil
+}
+
+func cmdEncryptDir(args []string) error {
+	return cmdTransformTree("encrypt-dir", args, true)
+}
+
+func cmdDecryptDir(args []string) error {
+	return cmdTransformTree("decrypt-dir", args, false)
+}
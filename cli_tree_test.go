@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyTreeReportsCorruptedFile(t *testing.T) {
+	key := []byte("1234567890123456")
+	dir := t.TempDir()
+	files := []string{"a.gcm", "sub/b.gcm", "sub/deeper/c.gcm"}
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		nonce, err := RandomNonce()
+		if err != nil {
+			t.Fatalf("RandomNonce failed: %v", err)
+		}
+		buf, err := sealGCMFile([]byte("backup "+name), key, nonce, nil)
+		if err != nil {
+			t.Fatalf("sealGCMFile failed: %v", err)
+		}
+		if err := os.WriteFile(path, buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	corrupted := filepath.Join(dir, "sub/b.gcm")
+	data, err := os.ReadFile(corrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-20] ^= 0x01
+	if err := os.WriteFile(corrupted, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	passed, failures, err := verifyTree(dir, key, nil, 4)
+	if err != nil {
+		t.Fatalf("verifyTree failed: %v", err)
+	}
+	if passed != 2 {
+		t.Errorf("Passed = %d, want 2", passed)
+	}
+	if len(failures) != 1 || failures[0].Path != corrupted {
+		t.Errorf("Failures = %v, want only %s", failures, corrupted)
+	}
+}
+
+func TestEncryptDecryptDir(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"top.txt":          "top level",
+		"sub/a.txt":        "nested once",
+		"sub/deeper/b.txt": "nested twice",
+		"sub/deeper/empty": "",
+		"other/c.bin":      "\x00\x01\x02",
+	}
+	for name, data := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(src, "top.txt"), filepath.Join(src, "sub/link")); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := filepath.Join(t.TempDir(), "enc")
+	dec := filepath.Join(t.TempDir(), "dec")
+	key := []string{"-key", "1234567890123456"}
+	if err := cmdEncryptDir(append([]string{"-in", src, "-out", enc}, key...)); err != nil {
+		t.Fatalf("encrypt-dir failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(enc, "sub/link")); !os.IsNotExist(err) {
+		t.Errorf("Symlink was not skipped: %v", err)
+	}
+	passed, failures, err := verifyTree(enc, []byte("1234567890123456"), nil, 2)
+	if err != nil || passed != len(files) || len(failures) != 0 {
+		t.Errorf("verifyTree = %d passed, %v, %v; want %d passed", passed, failures, err, len(files))
+	}
+
+	if err := cmdDecryptDir(append([]string{"-in", enc, "-out", dec}, key...)); err != nil {
+		t.Fatalf("decrypt-dir failed: %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dec, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if string(got) != want {
+			t.Errorf("%s decrypted to %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDecryptDirReportsBadFile(t *testing.T) {
+	key := []byte("1234567890123456")
+	src := t.TempDir()
+	for _, name := range []string{"a", "sub/b"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		nonce, err := RandomNonce()
+		if err != nil {
+			t.Fatalf("RandomNonce failed: %v", err)
+		}
+		buf, err := sealGCMFile([]byte("file "+name), key, nonce, nil)
+		if err != nil {
+			t.Fatalf("sealGCMFile failed: %v", err)
+		}
+		if err := os.WriteFile(path, buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bad := filepath.Join(src, "sub/bad")
+	if err := os.WriteFile(bad, []byte("not encrypted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	open := func(data []byte) ([]byte, error) { return openGCMFile(data, key, nil) }
+	done, skipped, failures, err := transformTree(src, dst, false, open)
+	if err != nil {
+		t.Fatalf("transformTree failed: %v", err)
+	}
+	if done != 2 || skipped != 0 {
+		t.Errorf("Done = %d, skipped = %d, want 2 and 0", done, skipped)
+	}
+	if len(failures) != 1 || failures[0].Path != bad {
+		t.Errorf("Failures = %v, want only %s", failures, bad)
+	}
+	if got, err := os.ReadFile(filepath.Join(dst, "sub/b")); err != nil || string(got) != "file sub/b" {
+		t.Errorf("sub/b after a failed sibling = %q, %v", got, err)
+	}
+
+	if _, _, _, err := transformTree(src, filepath.Join(src, "out"), false, open); err == nil {
+		t.Error("Expected error for output inside input")
+	}
+}
+
+func TestTransformTreeRejectsDestinationInsideSource(t *testing.T) {
+	parent := t.TempDir()
+	src := filepath.Join(parent, "src")
+	if err := os.Mkdir(src, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+
+	for _, dst := range []string{src, filepath.Join(src, "out"), filepath.Join(src, "..out")} {
+		err := cmdEncryptDir(append([]string{"-in", src, "-out", dst}, key...))
+		if err == nil || !strings.Contains(err.Error(), "inside") {
+			t.Errorf("-out %s: expected inside-source error, got %v", dst, err)
+		}
+	}
+
+	// Siblings, including ones whose names start with "..", are fine
+	for _, dst := range []string{filepath.Join(parent, "out"), filepath.Join(parent, "..out")} {
+		if err := cmdEncryptDir(append([]string{"-in", src, "-out", dst}, key...)); err != nil {
+			t.Errorf("-out %s: encrypt-dir failed: %v", dst, err)
+		}
+	}
+}
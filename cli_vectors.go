@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+)
+
+// testVector is one known-answer vector emitted by gen-vectors. All byte
+// fields are hex.
+type testVector struct {
+	Mode       string `json:"mode"`
+	KeySize    int    `json:"key_size"`
+	Key        string `json:"key"`
+	IV         string `json:"iv"`
+	AAD        string `json:"aad,omitempty"`
+	Plaintext  string `json:"plaintext"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// vectorModes lists every mode gen-vectors covers. For GCM, iv is the nonce
+// and the ciphertext includes the tag.
+var vectorModes = []struct {
+	name    string
+	ivSize  int
+	encrypt func(pt, key, iv, aad []byte) ([]byte, error)
+	decrypt func(ct, key, iv, aad []byte) ([]byte, error)
+}{
+	{"cbc", 16,
+		func(pt, key, iv, _ []byte) ([]byte, error) { return CBCEncrypt(pt, key, iv) },
+		func(ct, key, iv, _ []byte) ([]byte, error) { return CBCDecrypt(ct, key, iv) }},
+	{"ctr", 16,
+		func(pt, key, iv, _ []byte) ([]byte, error) { return CTREncrypt(pt, key, iv) },
+		func(ct, key, iv, _ []byte) ([]byte, error) { return CTREncrypt(ct, key, iv) }},
+	{"cfb", 16,
+		func(pt, key, iv, _ []byte) ([]byte, error) { return CFBEncrypt(pt, key, iv) },
+		func(ct, key, iv, _ []byte) ([]byte, error) { return CFBDecrypt(ct, key, iv) }},
+	{"ofb", 16,
+		func(pt, key, iv, _ []byte) ([]byte, error) { return OFBEncrypt(pt, key, iv) },
+		func(ct, key, iv, _ []byte) ([]byte, error) { return OFBDecrypt(ct, key, iv) }},
+	{"gcm", 12, GCMEncrypt, GCMDecrypt},
+}
+
+// vectorSeed fixes the generator so every run emits the same vectors
+var vectorSeed = [32]byte{'a', 'e', 's', '-', 'v', 'e', 'c', 't', 'o', 'r', 's'}
+
+// genVectors builds the known-answer vectors for every mode and key size.
+// Keys, IVs and nonces come from a ChaCha8 generator with a fixed seed.
+func genVectors() ([]testVector, error) {
+	rng := rand.NewChaCha8(vectorSeed)
+	plaintexts := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("exactly sixteen!"),
+		[]byte("a message that spans more than two AES blocks"),
+	}
+	aad := []byte("gen-vectors aad")
+
+	var vectors []testVector
+	for _, m := range vectorModes {
+		for _, keySize := range []int{16, 32} {
+			for _, pt := range plaintexts {
+				key := make([]byte, keySize)
+				iv := make([]byte, m.ivSize)
+				rng.Read(key)
+				rng.Read(iv)
+				v := testVector{
+					Mode:      m.name,
+					KeySize:   keySize * 8,
+					Key:       hex.EncodeToString(key),
+					IV:        hex.EncodeToString(iv),
+					Plaintext: hex.EncodeToString(pt),
+				}
+				var vaad []byte
+				if m.name == "gcm" {
+					vaad = aad
+					v.AAD = hex.EncodeToString(aad)
+				}
+				ct, err := m.encrypt(pt, key, iv, vaad)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", m.name, err)
+				}
+				v.Ciphertext = hex.EncodeToString(ct)
+				vectors = append(vectors, v)
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func writeVectors(w io.Writer, vectors []testVector) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(vectors)
+}
+
+// cmdGenVectors emits known-answer vectors for interop testing. It is not
+// listed in usage.
+func cmdGenVectors(args []string) error {
+	fs := flag.NewFlagSet("gen-vectors", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Generate the vectors but print nothing")
+	fs.Parse(args)
+	vectors, err := genVectors()
+	if err != nil {
+		return fmt.Errorf("gen-vectors: %w", err)
+	}
+	if *dryRun {
+		report(infoOutput, true, "would print %d vectors\n", len(vectors))
+		return nil
+	}
+	return writeVectors(os.Stdout, vectors)
+}
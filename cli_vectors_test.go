@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestGenVectorsRoundTrip(t *testing.T) {
+	vectors, err := genVectors()
+	if err != nil {
+		t.Fatalf("genVectors failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeVectors(&buf, vectors); err != nil {
+		t.Fatalf("writeVectors failed: %v", err)
+	}
+	var parsed []testVector
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if len(parsed) == 0 {
+		t.Fatal("No vectors generated")
+	}
+
+	decrypters := make(map[string]func(ct, key, iv, aad []byte) ([]byte, error))
+	for _, m := range vectorModes {
+		decrypters[m.name] = m.decrypt
+	}
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("Bad hex %q: %v", s, err)
+		}
+		return b
+	}
+	for _, v := range parsed {
+		decrypt, ok := decrypters[v.Mode]
+		if !ok {
+			t.Fatalf("Unknown mode %q", v.Mode)
+		}
+		key := decode(v.Key)
+		if len(key)*8 != v.KeySize {
+			t.Errorf("%s: key is %d bits, header says %d", v.Mode, len(key)*8, v.KeySize)
+		}
+		pt, err := decrypt(decode(v.Ciphertext), key, decode(v.IV), decode(v.AAD))
+		if err != nil {
+			t.Fatalf("%s/%d: decrypt failed: %v", v.Mode, v.KeySize, err)
+		}
+		if !bytes.Equal(pt, decode(v.Plaintext)) {
+			t.Errorf("%s/%d: vector doesn't round-trip", v.Mode, v.KeySize)
+		}
+	}
+
+	// A fixed seed means the output never changes
+	again, err := genVectors()
+	if err != nil {
+		t.Fatalf("genVectors failed: %v", err)
+	}
+	var buf2 bytes.Buffer
+	writeVectors(&buf2, again)
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Error("gen-vectors output is not deterministic")
+	}
+}
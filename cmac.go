@@ -0,0 +1,58 @@
+package main
+
+// cmacDouble multiplies a block by x in GF(2^128) with the CMAC/S2V
+// polynomial x^128 + x^7 + x^2 + x + 1, in the big-endian bit order of
+// RFC 4493 (unlike GHASH, which reflects the bits)
+func cmacDouble(b [16]byte) [16]byte {
+	var d [16]byte
+	for i := 0; i < 15; i++ {
+		d[i] = b[i]<<1 | b[i+1]>>7
+	}
+	d[15] = b[15]<<1 ^ 0x87&-(b[0]>>7)
+	return d
+}
+
+// cmac computes AES-CMAC (RFC 4493) of msg under c
+func (c *Cipher) cmac(msg []byte) [16]byte {
+	var l [16]byte
+	c.Encrypt(l[:], l[:])
+	k1 := cmacDouble(l)
+
+	var mac [16]byte
+	// Every block but the last is plain CBC-MAC
+	for len(msg) > 16 {
+		for i := range mac {
+			mac[i] ^= msg[i]
+		}
+		c.Encrypt(mac[:], mac[:])
+		msg = msg[16:]
+	}
+	// The last block is masked with K1 if complete, or padded with
+	// 10* and masked with K2
+	last := k1
+	if len(msg) < 16 {
+		last = cmacDouble(k1)
+		last[len(msg)] ^= 0x80
+	}
+	for i := range msg {
+		last[i] ^= msg[i]
+	}
+	for i := range mac {
+		mac[i] ^= last[i]
+	}
+	c.Encrypt(mac[:], mac[:])
+	return mac
+}
+
+// CMAC returns the 16-byte AES-CMAC (RFC 4493) tag of message under key.
+// It authenticates without encrypting; compare tags with a constant-time
+// comparison such as crypto/subtle.ConstantTimeCompare.
+func CMAC(key, message []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	tag := c.cmac(message)
+	return tag[:], nil
+}
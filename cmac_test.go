@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCMACRFC4493(t *testing.T) {
+	key := mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := mustHex(t, sp80038aPlaintext)
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	} {
+		if got := c.cmac(msg[:tc.n]); !bytes.Equal(got[:], mustHex(t, tc.want)) {
+			t.Errorf("CMAC of %d bytes = %x, want %s", tc.n, got, tc.want)
+		}
+		got, err := CMAC(key, msg[:tc.n])
+		if err != nil {
+			t.Fatalf("CMAC failed: %v", err)
+		}
+		if !bytes.Equal(got, mustHex(t, tc.want)) {
+			t.Errorf("CMAC(%d bytes) = %x, want %s", tc.n, got, tc.want)
+		}
+	}
+	if _, err := CMAC([]byte("short"), nil); err == nil {
+		t.Error("Expected error for invalid key length")
+	}
+}
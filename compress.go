@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor compresses plaintext before encryption. Compressing after
+// encryption is pointless since ciphertext doesn't compress.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Codec identifiers stored in the envelope header
+const (
+	CodecNone byte = 0
+	CodecGzip byte = 1
+	CodecZstd byte = 2
+)
+
+type noneCompressor struct{}
+
+func (noneCompressor) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (noneCompressor) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) Compress(data []byte) ([]byte, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil), nil
+}
+
+func (zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return dec.DecodeAll(data, nil)
+}
+
+// CompressorFor returns the Compressor for a codec ID
+func CompressorFor(codec byte) (Compressor, error) {
+	switch codec {
+	case CodecNone:
+		return noneCompressor{}, nil
+	case CodecGzip:
+		return gzipCompressor{}, nil
+	case CodecZstd:
+		return zstdCompressor{}, nil
+	}
+	return nil, fmt.Errorf("unknown compression codec %d", codec)
+}
+
+// CodecByName maps a -compress flag value to a codec ID
+func CodecByName(name string) (byte, error) {
+	switch name {
+	case "", "none":
+		return CodecNone, nil
+	case "gzip":
+		return CodecGzip, nil
+	case "zstd":
+		return CodecZstd, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q (want gzip, zstd or none)", name)
+}
+
+// CodecName returns the -compress name for a codec ID
+func CodecName(codec byte) string {
+	switch codec {
+	case CodecNone:
+		return "none"
+	case CodecGzip:
+		return "gzip"
+	case CodecZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("codec %d", codec)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressedEnvelopes(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := bytes.Repeat([]byte("compressible log line\n"), 500)
+
+	for _, name := range []string{"none", "gzip", "zstd"} {
+		codec, err := CodecByName(name)
+		if err != nil {
+			t.Fatalf("CodecByName(%q) failed: %v", name, err)
+		}
+		for _, mode := range []byte{ModeCBC, ModeGCM} {
+			blob, err := SealEnvelopeCompressed(mode, codec, plaintext, key, nil)
+			if err != nil {
+				t.Fatalf("%s/%s: SealEnvelopeCompressed failed: %v", name, ModeName(mode), err)
+			}
+			e, _, err := ParseEnvelope(blob)
+			if err != nil {
+				t.Fatalf("ParseEnvelope failed: %v", err)
+			}
+			if e.Codec != codec {
+				t.Errorf("%s: header codec = %s", name, CodecName(e.Codec))
+			}
+			if codec != CodecNone && len(blob) >= len(plaintext)/4 {
+				t.Errorf("%s: %d-byte blob for %d-byte compressible input", name, len(blob), len(plaintext))
+			}
+			decrypted, err := OpenEnvelope(blob, key, nil, mode)
+			if err != nil {
+				t.Fatalf("%s/%s: OpenEnvelope failed: %v", name, ModeName(mode), err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("%s/%s: Decrypted text doesn't match", name, ModeName(mode))
+			}
+		}
+	}
+}
+
+func TestCompressedEnvelopeCodecAuthenticated(t *testing.T) {
+	key := []byte("1234567890123456")
+	blob, err := SealEnvelopeCompressed(ModeGCM, CodecGzip, []byte("payload"), key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelopeCompressed failed: %v", err)
+	}
+	blob[6] = CodecZstd
+	if _, err := OpenEnvelope(blob, key, nil); err == nil {
+		t.Error("Expected error after changing the codec byte")
+	}
+}
+
+func TestEnvelopeReadsVersion1(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	header := []byte("AESX\x01\x03")
+	ct, err := GCMEncrypt([]byte("written before codecs"), key, nonce, header)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	blob := append(append(header, nonce...), ct...)
+	pt, err := OpenEnvelope(blob, key, nil)
+	if err != nil {
+		t.Fatalf("OpenEnvelope failed on a version 1 blob: %v", err)
+	}
+	if string(pt) != "written before codecs" {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestSecureConnRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	c1, c2 := net.Pipe()
+	a, err := NewSecureConn(c1, key, true)
+	if err != nil {
+		t.Fatalf("NewSecureConn failed: %v", err)
+	}
+	b, err := NewSecureConn(c2, key, false)
+	if err != nil {
+		t.Fatalf("NewSecureConn failed: %v", err)
+	}
+
+	msg := []byte("hello over an encrypted pipe")
+	big := bytes.Repeat([]byte("x"), 3*secureConnChunk+5)
+	go func() {
+		a.Write(msg)
+		a.Write(big)
+		a.Close()
+	}()
+
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(b, got); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("Received %q, want %q", got, msg)
+	}
+	rest, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(rest, big) {
+		t.Errorf("Large write doesn't match (%d bytes, want %d)", len(rest), len(big))
+	}
+}
+
+func TestSecureConnDetectsTampering(t *testing.T) {
+	key := []byte("1234567890123456")
+
+	// Produce what a SecureConn would put on the wire, then flip a byte
+	var wire bytes.Buffer
+	w, err := newGCMStreamWriter(&wire, key, secureConnChunk)
+	if err != nil {
+		t.Fatalf("newGCMStreamWriter failed: %v", err)
+	}
+	w.label = secureConnInitiatorLabel
+	w.Write([]byte("attack at dawn"))
+	w.flush()
+	raw := wire.Bytes()
+	raw[streamHeaderSize+streamFrameHdrSize] ^= 1
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	b, err := NewSecureConn(c2, key, false)
+	if err != nil {
+		t.Fatalf("NewSecureConn failed: %v", err)
+	}
+	go c1.Write(raw)
+
+	buf := make([]byte, 64)
+	if _, err := b.Read(buf); err == nil {
+		t.Error("Expected read error for tampered frame")
+	}
+}
+
+func TestSecureConnRejectsReflection(t *testing.T) {
+	key := []byte("1234567890123456")
+
+	// Capture what the initiator sends
+	c1, c2 := net.Pipe()
+	a, err := NewSecureConn(c1, key, true)
+	if err != nil {
+		t.Fatalf("NewSecureConn failed: %v", err)
+	}
+	go func() {
+		a.Write([]byte("transfer 100 to bob"))
+		a.Close()
+	}()
+	wire, err := io.ReadAll(c2)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	// Echo it straight back to an initiator
+	d1, d2 := net.Pipe()
+	defer d2.Close()
+	echo, err := NewSecureConn(d1, key, true)
+	if err != nil {
+		t.Fatalf("NewSecureConn failed: %v", err)
+	}
+	go d2.Write(wire)
+	buf := make([]byte, 64)
+	if n, err := echo.Read(buf); err == nil {
+		t.Errorf("Initiator accepted its own reflected stream: %q", buf[:n])
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Framed files start with a self-describing header so a single command can
+// decrypt them without being told the mode:
+//
+//	"AESX" || version (1) || mode (1) || codec (1) || mode-specific body
+//
+// CBC body: IV (16 bytes) || ciphertext
+// CTR body: IV (16 bytes) || ciphertext
+// GCM body: nonce (12 bytes) || ciphertext || tag (16 bytes), with
+// header || aad as the GCM AAD so the header itself is authenticated
+//
+// The codec names the Compressor applied to the plaintext before
+// encryption. Version 1 headers have no codec byte and are uncompressed.
+const (
+	envelopeMagic        = "AESX"
+	envelopeVersion      = 2
+	envelopeHeaderSizeV1 = len(envelopeMagic) + 2
+	envelopeHeaderSize   = len(envelopeMagic) + 3
+)
+
+// Mode identifiers stored in the envelope header
+const (
+	ModeCBC byte = 1
+	ModeCTR byte = 2
+	ModeGCM byte = 3
+)
+
+// supportedVersions lists the envelope versions this build can read. Bump
+// envelopeVersion whenever the body layout changes and keep reading the old
+// versions here for as long as they are supported.
+var supportedVersions = map[byte]bool{
+	1:               true,
+	envelopeVersion: true,
+}
+
+var (
+	// ErrNotFramed is returned when data doesn't start with an envelope header
+	ErrNotFramed = errors.New("not a framed file (missing AESX header)")
+	// ErrTruncatedHeader is returned when data ends inside the envelope
+	// header itself
+	ErrTruncatedHeader = errors.New("framed file truncated inside its header")
+	// ErrTruncatedBody is returned when the header is complete but the
+	// body is too short for the mode it names
+	ErrTruncatedBody = errors.New("framed file truncated after its header")
+	// ErrUnsupportedVersion is returned for files written in a format
+	// version this build doesn't understand
+	ErrUnsupportedVersion = errors.New("unsupported format version")
+	// ErrModeNotAllowed is returned when a framed file's header names a
+	// mode the caller didn't allow
+	ErrModeNotAllowed = errors.New("envelope mode not allowed")
+)
+
+// FormatVersion returns the envelope format version written by SealEnvelope
+func FormatVersion() byte {
+	return envelopeVersion
+}
+
+// Envelope is the parsed header of a framed file
+type Envelope struct {
+	Version byte
+	Mode    byte
+	Codec   byte
+}
+
+// envelopeAAD builds header || aad in a fresh slice so it never writes into
+// the caller's buffer
+func envelopeAAD(header, aad []byte) []byte {
+	out := make([]byte, 0, len(header)+len(aad))
+	return append(append(out, header...), aad...)
+}
+
+func (e Envelope) marshal() []byte {
+	return append([]byte(envelopeMagic), e.Version, e.Mode, e.Codec)
+}
+
+// ParseEnvelope reads the header from blob and returns it with the
+// remaining body
+func ParseEnvelope(blob []byte) (Envelope, []byte, error) {
+	if !bytes.HasPrefix(blob, []byte(envelopeMagic)) {
+		if bytes.HasPrefix([]byte(envelopeMagic), blob) {
+			return Envelope{}, nil, ErrTruncatedHeader
+		}
+		return Envelope{}, nil, ErrNotFramed
+	}
+	if len(blob) < envelopeHeaderSizeV1 {
+		return Envelope{}, nil, ErrTruncatedHeader
+	}
+	e := Envelope{Version: blob[4], Mode: blob[5]}
+	if !supportedVersions[e.Version] {
+		return Envelope{}, nil, fmt.Errorf("%w: envelope version %d (this build writes version %d)", ErrUnsupportedVersion, e.Version, envelopeVersion)
+	}
+	if e.Version == 1 {
+		return e, blob[envelopeHeaderSizeV1:], nil
+	}
+	if len(blob) < envelopeHeaderSize {
+		return Envelope{}, nil, ErrTruncatedHeader
+	}
+	e.Codec = blob[6]
+	return e, blob[envelopeHeaderSize:], nil
+}
+
+// WriteHeader writes the envelope header for e to w
+func WriteHeader(w io.Writer, e Envelope) error {
+	_, err := w.Write(e.marshal())
+	return err
+}
+
+// ReadHeader reads an envelope header from r, leaving r positioned at the
+// start of the body. It returns the same errors as ParseEnvelope.
+func ReadHeader(r io.Reader) (Envelope, error) {
+	buf := make([]byte, envelopeHeaderSize)
+	n, err := io.ReadFull(r, buf[:envelopeHeaderSizeV1])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Envelope{}, err
+	}
+	// Only version 1 headers end before the codec byte
+	if n == envelopeHeaderSizeV1 && bytes.HasPrefix(buf, []byte(envelopeMagic)) && buf[4] != 1 {
+		m, err := io.ReadFull(r, buf[n:])
+		if err != nil && err != io.EOF {
+			return Envelope{}, err
+		}
+		n += m
+	}
+	e, _, err := ParseEnvelope(buf[:n])
+	return e, err
+}
+
+// SealEnvelope encrypts plaintext in the given mode and frames the result.
+// aad is only supported for GCM. CTR, like CBC, is unauthenticated, and
+// OpenEnvelope only opens either when the caller allows it.
+func SealEnvelope(mode byte, plaintext, key, aad []byte) ([]byte, error) {
+	return SealEnvelopeCompressed(mode, CodecNone, plaintext, key, aad)
+}
+
+// SealEnvelopeCompressed is SealEnvelope with the plaintext compressed by
+// codec first. The codec is recorded in the header, so OpenEnvelope
+// decompresses automatically.
+func SealEnvelopeCompressed(mode, codec byte, plaintext, key, aad []byte) ([]byte, error) {
+	c, err := CompressorFor(codec)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err = c.Compress(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+	header := Envelope{Version: envelopeVersion, Mode: mode, Codec: codec}.marshal()
+	switch mode {
+	case ModeCBC:
+		if len(aad) != 0 {
+			return nil, fmt.Errorf("CBC mode does not support additional data")
+		}
+		iv, err := RandomIV()
+		if err != nil {
+			return nil, err
+		}
+		ct, err := CBCEncrypt(plaintext, key, iv)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(header, iv...), ct...), nil
+	case ModeCTR:
+		if len(aad) != 0 {
+			return nil, fmt.Errorf("CTR mode does not support additional data")
+		}
+		iv, err := RandomIV()
+		if err != nil {
+			return nil, err
+		}
+		ct, err := CTREncrypt(plaintext, key, iv)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(header, iv...), ct...), nil
+	case ModeGCM:
+		nonce, err := RandomNonce()
+		if err != nil {
+			return nil, err
+		}
+		ct, err := GCMEncrypt(plaintext, key, nonce, envelopeAAD(header, aad))
+		if err != nil {
+			return nil, err
+		}
+		return append(append(header, nonce...), ct...), nil
+	}
+	return nil, fmt.Errorf("unknown mode %d", mode)
+}
+
+// OpenEnvelope decrypts a framed blob, dispatching on the mode and codec in
+// its header. The mode must be one of allowed, or GCM if allowed is empty.
+// Only GCM authenticates the header, so anyone can relabel a GCM blob as
+// CTR (counter nonce || 00000002, tag dropped) and edit the plaintext;
+// allow CBC or CTR only for files known to be authenticated another way.
+func OpenEnvelope(blob, key, aad []byte, allowed ...byte) ([]byte, error) {
+	e, body, err := ParseEnvelope(blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		allowed = []byte{ModeGCM}
+	}
+	if !bytes.Contains(allowed, []byte{e.Mode}) {
+		return nil, fmt.Errorf("%w: %s", ErrModeNotAllowed, ModeName(e.Mode))
+	}
+	c, err := CompressorFor(e.Codec)
+	if err != nil {
+		return nil, err
+	}
+	header := blob[:len(blob)-len(body)]
+	var pt []byte
+	switch e.Mode {
+	case ModeCBC:
+		if len(body) < 16 {
+			return nil, fmt.Errorf("%w: CBC body needs an IV, got %d bytes", ErrTruncatedBody, len(body))
+		}
+		pt, err = CBCDecrypt(body[16:], key, body[:16])
+	case ModeCTR:
+		if len(body) < 16 {
+			return nil, fmt.Errorf("%w: CTR body needs an IV, got %d bytes", ErrTruncatedBody, len(body))
+		}
+		pt, err = CTREncrypt(body[16:], key, body[:16])
+	case ModeGCM:
+		if len(body) < gcmNonceSize+gcmTagSize {
+			return nil, fmt.Errorf("%w: GCM body needs nonce + tag, got %d bytes", ErrTruncatedBody, len(body))
+		}
+		pt, err = GCMDecrypt(body[gcmNonceSize:], key, body[:gcmNonceSize], envelopeAAD(header, aad))
+	default:
+		return nil, fmt.Errorf("unknown mode %d in header", e.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pt, err = c.Decompress(pt); err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	return pt, nil
+}
+
+// ModeName returns a human-readable name for an envelope mode
+func ModeName(mode byte) string {
+	switch mode {
+	case ModeCBC:
+		return "CBC"
+	case ModeCTR:
+		return "CTR"
+	case ModeGCM:
+		return "GCM"
+	}
+	return fmt.Sprintf("mode %d", mode)
+}
+
+// SecureEncrypt is the "just do the safe thing" API: it always encrypts with
+// AES-GCM under a fresh random nonce and frames the result, so the output is
+// authenticated and self-describing
+func SecureEncrypt(plaintext, key []byte) ([]byte, error) {
+	return SealEnvelope(ModeGCM, plaintext, key, nil)
+}
+
+// SecureDecrypt reverses SecureEncrypt. It only accepts framed GCM, so a
+// CBC or CTR blob can't be swapped in to skip authentication.
+func SecureDecrypt(blob, key []byte) ([]byte, error) {
+	return OpenEnvelope(blob, key, nil, ModeGCM)
+}
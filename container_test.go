@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEnvelopeOpenDetectsMode(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := []byte("framed file contents")
+
+	for _, mode := range []byte{ModeCBC, ModeCTR, ModeGCM} {
+		blob, err := SealEnvelope(mode, plaintext, key, nil)
+		if err != nil {
+			t.Fatalf("SealEnvelope(%s) failed: %v", ModeName(mode), err)
+		}
+		e, _, err := ParseEnvelope(blob)
+		if err != nil {
+			t.Fatalf("ParseEnvelope failed: %v", err)
+		}
+		if e.Mode != mode || e.Version != envelopeVersion {
+			t.Errorf("Header = %+v, want mode %d version %d", e, mode, envelopeVersion)
+		}
+		decrypted, err := OpenEnvelope(blob, key, nil, mode)
+		if err != nil {
+			t.Fatalf("OpenEnvelope(%s) failed: %v", ModeName(mode), err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%s: decrypted text doesn't match", ModeName(mode))
+		}
+	}
+}
+
+func TestEnvelopeGCMWithAAD(t *testing.T) {
+	key := []byte("1234567890123456")
+	blob, err := SealEnvelope(ModeGCM, []byte("data"), key, []byte("aad"))
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	if _, err := OpenEnvelope(blob, key, []byte("aad")); err != nil {
+		t.Errorf("OpenEnvelope failed: %v", err)
+	}
+	if _, err := OpenEnvelope(blob, key, []byte("other")); err == nil {
+		t.Error("Expected authentication failure with wrong AAD")
+	}
+	if _, err := SealEnvelope(ModeCBC, []byte("data"), key, []byte("aad")); err == nil {
+		t.Error("Expected error for AAD with CBC")
+	}
+	if _, err := SealEnvelope(ModeCTR, []byte("data"), key, []byte("aad")); err == nil {
+		t.Error("Expected error for AAD with CTR")
+	}
+}
+
+func TestEnvelopeRejectsLegacyFiles(t *testing.T) {
+	key := []byte("1234567890123456")
+	legacy, err := sealGCMFile([]byte("old format"), key, []byte("123456789012"), nil)
+	if err != nil {
+		t.Fatalf("sealGCMFile failed: %v", err)
+	}
+	if _, err := OpenEnvelope(legacy, key, nil); err != ErrNotFramed {
+		t.Errorf("Expected ErrNotFramed, got %v", err)
+	}
+}
+
+func TestEnvelopeRejectsNewerVersion(t *testing.T) {
+	key := []byte("1234567890123456")
+	blob, err := SealEnvelope(ModeGCM, []byte("from the future"), key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	if blob[4] != FormatVersion() {
+		t.Fatalf("Version byte = %d, want %d", blob[4], FormatVersion())
+	}
+	blob[4] = FormatVersion() + 1
+	if _, err := OpenEnvelope(blob, key, nil); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestSecureEncrypt(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := []byte("the safe default")
+
+	blob, err := SecureEncrypt(plaintext, key)
+	if err != nil {
+		t.Fatalf("SecureEncrypt failed: %v", err)
+	}
+	e, _, err := ParseEnvelope(blob)
+	if err != nil {
+		t.Fatalf("ParseEnvelope failed: %v", err)
+	}
+	if e.Mode != ModeGCM {
+		t.Errorf("Mode = %s, want GCM", ModeName(e.Mode))
+	}
+	decrypted, err := SecureDecrypt(blob, key)
+	if err != nil {
+		t.Fatalf("SecureDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	blob[len(blob)-1] ^= 1
+	if _, err := SecureDecrypt(blob, key); err == nil {
+		t.Error("Expected error for tampered blob")
+	}
+
+	cbc, err := SealEnvelope(ModeCBC, plaintext, key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	if _, err := SecureDecrypt(cbc, key); err == nil {
+		t.Error("Expected SecureDecrypt to refuse a framed CBC blob")
+	}
+}
+
+func TestEnvelopeTruncationErrors(t *testing.T) {
+	key := []byte("1234567890123456")
+	blob, err := SealEnvelope(ModeGCM, []byte("truncate me"), key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	for _, n := range []int{0, 3, envelopeHeaderSizeV1, envelopeHeaderSize - 1} {
+		if _, err := OpenEnvelope(blob[:n], key, nil); !errors.Is(err, ErrTruncatedHeader) {
+			t.Errorf("%d bytes: expected ErrTruncatedHeader, got %v", n, err)
+		}
+	}
+	for _, n := range []int{envelopeHeaderSize, envelopeHeaderSize + gcmNonceSize, envelopeHeaderSize + gcmNonceSize + gcmTagSize - 1} {
+		if _, err := OpenEnvelope(blob[:n], key, nil); !errors.Is(err, ErrTruncatedBody) {
+			t.Errorf("%d bytes: expected ErrTruncatedBody, got %v", n, err)
+		}
+	}
+	if _, err := OpenEnvelope([]byte("AESY"), key, nil); err != ErrNotFramed {
+		t.Errorf("Expected ErrNotFramed, got %v", err)
+	}
+}
+
+func TestWriteReadHeader(t *testing.T) {
+	for _, e := range []Envelope{
+		{Version: envelopeVersion, Mode: ModeCBC, Codec: CodecNone},
+		{Version: envelopeVersion, Mode: ModeCTR, Codec: CodecGzip},
+		{Version: envelopeVersion, Mode: ModeGCM, Codec: CodecZstd},
+	} {
+		var buf bytes.Buffer
+		if err := WriteHeader(&buf, e); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		buf.WriteString("body")
+		got, err := ReadHeader(&buf)
+		if err != nil {
+			t.Fatalf("ReadHeader(%s) failed: %v", ModeName(e.Mode), err)
+		}
+		if got != e {
+			t.Errorf("ReadHeader = %+v, want %+v", got, e)
+		}
+		if buf.String() != "body" {
+			t.Errorf("%s: ReadHeader left %q unread, want the body", ModeName(e.Mode), buf.String())
+		}
+	}
+
+	// Version 1 headers have no codec byte
+	got, err := ReadHeader(bytes.NewReader([]byte("AESX\x01\x01body")))
+	if err != nil || got != (Envelope{Version: 1, Mode: ModeCBC}) {
+		t.Errorf("ReadHeader(v1) = %+v, %v", got, err)
+	}
+
+	for _, tc := range []struct {
+		data string
+		want error
+	}{
+		{"", ErrTruncatedHeader},
+		{"AES", ErrTruncatedHeader},
+		{"AESX\x02\x03", ErrTruncatedHeader},
+		{"AESY\x02\x03\x00", ErrNotFramed},
+		{"AESX\x09\x03\x00", ErrUnsupportedVersion},
+	} {
+		if _, err := ReadHeader(bytes.NewReader([]byte(tc.data))); !errors.Is(err, tc.want) {
+			t.Errorf("ReadHeader(%q): expected %v, got %v", tc.data, tc.want, err)
+		}
+	}
+}
+
+func TestEnvelopeCTRIsUnauthenticated(t *testing.T) {
+	key := []byte("1234567890123456")
+	blob, err := SealEnvelope(ModeCTR, []byte("flip me"), key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	if len(blob) != envelopeHeaderSize+16+len("flip me") {
+		t.Errorf("CTR envelope is %d bytes, want header + IV + plaintext length", len(blob))
+	}
+	blob[len(blob)-1] ^= 1
+	pt, err := OpenEnvelope(blob, key, nil, ModeCTR)
+	if err != nil {
+		t.Fatalf("OpenEnvelope failed: %v", err)
+	}
+	if string(pt) != "flip md" {
+		t.Errorf("Decrypted %q, want the bit flip to carry through", pt)
+	}
+	if _, err := SecureDecrypt(blob, key); err == nil {
+		t.Error("Expected SecureDecrypt to refuse a framed CTR blob")
+	}
+	if _, err := OpenEnvelope(blob[:envelopeHeaderSize+15], key, nil, ModeCTR); !errors.Is(err, ErrTruncatedBody) {
+		t.Errorf("Expected ErrTruncatedBody, got %v", err)
+	}
+}
+
+func TestOpenEnvelopeAllowedModes(t *testing.T) {
+	key := []byte("1234567890123456")
+	for _, mode := range []byte{ModeCBC, ModeCTR} {
+		blob, err := SealEnvelope(mode, []byte("unauthenticated"), key, nil)
+		if err != nil {
+			t.Fatalf("SealEnvelope(%s) failed: %v", ModeName(mode), err)
+		}
+		if _, err := OpenEnvelope(blob, key, nil); !errors.Is(err, ErrModeNotAllowed) {
+			t.Errorf("%s opened with the default modes: %v", ModeName(mode), err)
+		}
+		if _, err := OpenEnvelope(blob, key, nil, ModeGCM); !errors.Is(err, ErrModeNotAllowed) {
+			t.Errorf("%s opened with only GCM allowed: %v", ModeName(mode), err)
+		}
+	}
+
+	blob, err := SealEnvelope(ModeGCM, []byte("pay alice $100"), key, nil)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+	if _, err := OpenEnvelope(blob, key, nil, ModeCBC); !errors.Is(err, ErrModeNotAllowed) {
+		t.Errorf("GCM opened with only CBC allowed: %v", err)
+	}
+	forged := forgeCTREnvelope(t, blob)
+	if _, err := OpenEnvelope(forged, key, nil); !errors.Is(err, ErrModeNotAllowed) {
+		t.Errorf("Forged CTR envelope opened with the default modes: %v", err)
+	}
+}
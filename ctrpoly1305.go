@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// AES-CTR-Poly1305 follows the ChaCha20-Poly1305 construction (RFC 8439
+// section 2.8) with AES-CTR in place of ChaCha20. It is NOT a standard
+// AEAD and nothing else will read its output; use GCM for interoperability.
+//
+// With counter blocks nonce (12 bytes) || uint32 counter (big-endian), the
+// AES encryptions of counters 0 and 1 form the one-time Poly1305 key and
+// the plaintext is encrypted from counter 2. The tag is Poly1305 over
+// aad || pad16 || ciphertext || pad16 || len(aad) || len(ciphertext), the
+// lengths as little-endian 64-bit byte counts.
+//
+//	output: ciphertext || tag (16 bytes)
+
+// ctrPoly1305MaxLen keeps the 32-bit block counter from wrapping
+const ctrPoly1305MaxLen = (1<<32 - 2) * 16
+
+// ctrPoly1305Setup derives the Poly1305 key and the first data counter
+func ctrPoly1305Setup(key, nonce []byte) (c *Cipher, polyKey *[32]byte, counter []byte, err error) {
+	if len(nonce) != gcmNonceSize {
+		return nil, nil, nil, fmt.Errorf("nonce must be %d bytes", gcmNonceSize)
+	}
+	if c, err = NewCipher(key); err != nil {
+		return nil, nil, nil, err
+	}
+	counter = make([]byte, 16)
+	copy(counter, nonce)
+	polyKey = new([32]byte)
+	c.Encrypt(polyKey[:16], counter)
+	counter[15] = 1
+	c.Encrypt(polyKey[16:], counter)
+	counter[15] = 2
+	return c, polyKey, counter, nil
+}
+
+// ctrPoly1305Tag computes the tag the way RFC 8439 lays out the MAC input
+func ctrPoly1305Tag(polyKey *[32]byte, aad, ciphertext []byte) [16]byte {
+	pad := func(n int) int { return (16 - n%16) % 16 }
+	msg := make([]byte, 0, len(aad)+len(ciphertext)+48)
+	msg = append(msg, aad...)
+	msg = append(msg, make([]byte, pad(len(aad)))...)
+	msg = append(msg, ciphertext...)
+	msg = append(msg, make([]byte, pad(len(ciphertext)))...)
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(aad)))
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(ciphertext)))
+	var tag [16]byte
+	poly1305Sum(&tag, msg, polyKey)
+	return tag
+}
+
+// CTRPoly1305Encrypt encrypts plaintext with the non-standard
+// AES-CTR-Poly1305 AEAD described above, using a 12-byte nonce that must
+// never repeat under the same key.
+// Returns: ciphertext || tag (16 bytes)
+func CTRPoly1305Encrypt(plaintext, key, nonce, aad []byte) ([]byte, error) {
+	c, polyKey, counter, err := ctrPoly1305Setup(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(polyKey[:])
+	if uint64(len(plaintext)) > ctrPoly1305MaxLen {
+		return nil, fmt.Errorf("plaintext exceeds %d bytes", uint64(ctrPoly1305MaxLen))
+	}
+	ct, err := c.CTRXOR(plaintext, counter)
+	if err != nil {
+		return nil, err
+	}
+	tag := ctrPoly1305Tag(polyKey, aad, ct)
+	return append(ct, tag[:]...), nil
+}
+
+// CTRPoly1305Decrypt verifies the tag and decrypts the output of
+// CTRPoly1305Encrypt. Nothing is decrypted unless the tag matches.
+func CTRPoly1305Decrypt(ciphertextWithTag, key, nonce, aad []byte) ([]byte, error) {
+	c, polyKey, counter, err := ctrPoly1305Setup(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(polyKey[:])
+	if len(ciphertextWithTag) < gcmTagSize {
+		return nil, fmt.Errorf("ciphertext too short (must include %d-byte tag)", gcmTagSize)
+	}
+	n := len(ciphertextWithTag) - gcmTagSize
+	ct, tag := ciphertextWithTag[:n], ciphertextWithTag[n:]
+	want := ctrPoly1305Tag(polyKey, aad, ct)
+	if !tagsEqual(tag, want[:]) {
+		return nil, fmt.Errorf("%w: tag mismatch", ErrAuthFailed)
+	}
+	return c.CTRXOR(ct, counter)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPoly1305Vector(t *testing.T) {
+	// RFC 8439 section 2.5.2
+	var key [32]byte
+	copy(key[:], mustHex(t, "85d6be7857556d337f4452fe42d506a80103808afb0db2fd4abff6af4149f51b"))
+	want := mustHex(t, "a8061dc1305136c6c22b8baf0c0127a9")
+	var tag [16]byte
+	poly1305Sum(&tag, []byte("Cryptographic Forum Research Group"), &key)
+	if !bytes.Equal(tag[:], want) {
+		t.Errorf("Poly1305: got %x, want %x", tag, want)
+	}
+}
+
+func TestCTRPoly1305RoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("header")
+	for _, n := range []int{0, 1, 15, 16, 17, 100} {
+		plaintext := bytes.Repeat([]byte("p"), n)
+		ct, err := CTRPoly1305Encrypt(plaintext, key, nonce, aad)
+		if err != nil {
+			t.Fatalf("CTRPoly1305Encrypt failed: %v", err)
+		}
+		if len(ct) != n+gcmTagSize {
+			t.Errorf("Output length %d, want %d", len(ct), n+gcmTagSize)
+		}
+		pt, err := CTRPoly1305Decrypt(ct, key, nonce, aad)
+		if err != nil {
+			t.Fatalf("CTRPoly1305Decrypt failed: %v", err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("Decrypted text doesn't match for %d bytes", n)
+		}
+	}
+}
+
+func TestCTRPoly1305Tamper(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("header")
+	ct, err := CTRPoly1305Encrypt([]byte("tamper with me please"), key, nonce, aad)
+	if err != nil {
+		t.Fatalf("CTRPoly1305Encrypt failed: %v", err)
+	}
+	for i := range ct {
+		bad := append([]byte(nil), ct...)
+		bad[i] ^= 0x80
+		if _, err := CTRPoly1305Decrypt(bad, key, nonce, aad); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("Byte %d: expected ErrAuthFailed, got %v", i, err)
+		}
+	}
+}
+
+func TestCTRPoly1305AuthFailure(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	ct, err := CTRPoly1305Encrypt([]byte("secret"), key, nonce, []byte("aad"))
+	if err != nil {
+		t.Fatalf("CTRPoly1305Encrypt failed: %v", err)
+	}
+	if _, err := CTRPoly1305Decrypt(ct, []byte("6543210987654321"), nonce, []byte("aad")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for wrong key, got %v", err)
+	}
+	if _, err := CTRPoly1305Decrypt(ct, key, []byte("210987654321"), []byte("aad")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for wrong nonce, got %v", err)
+	}
+	if _, err := CTRPoly1305Decrypt(ct, key, nonce, []byte("bad")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for wrong AAD, got %v", err)
+	}
+	if _, err := CTRPoly1305Decrypt(ct[:10], key, nonce, nil); err == nil {
+		t.Error("Expected error for short ciphertext")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// ctrStreamChunk is how much the CTR writer encrypts per write to the
+// underlying writer
+const ctrStreamChunk = 32 << 10
+
+// ctrState is a CTR keystream that can be consumed in pieces of any size
+type ctrState struct {
+	c       *Cipher
+	counter [16]byte
+	ks      [16]byte
+	used    int // bytes of ks already consumed
+}
+
+func newCTRState(key, iv []byte) (*ctrState, error) {
+	if len(iv) != 16 {
+		return nil, fmt.Errorf("CTR mode requires 16-byte IV/counter")
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	s := &ctrState{c: c, used: 16}
+	copy(s.counter[:], iv)
+	return s, nil
+}
+
+// xor sets dst = src XOR the next len(src) keystream bytes
+func (s *ctrState) xor(dst, src []byte) {
+	for i := range src {
+		if s.used == 16 {
+			s.c.Encrypt(s.ks[:], s.counter[:])
+			incCounter(s.counter[:])
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.ks[s.used]
+		s.used++
+	}
+}
+
+type ctrWriter struct {
+	w      io.Writer
+	s      *ctrState
+	buf    []byte
+	closed bool
+}
+
+// NewCTRWriter returns a writer that CTR-encrypts everything written to it
+// and writes the ciphertext to w, producing the same bytes as CTREncrypt
+// over the concatenated input. Close does not close w.
+func NewCTRWriter(w io.Writer, key, iv []byte) (io.WriteCloser, error) {
+	s, err := newCTRState(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &ctrWriter{w: w, s: s, buf: make([]byte, ctrStreamChunk)}, nil
+}
+
+func (cw *ctrWriter) Write(p []byte) (int, error) {
+	if cw.closed {
+		return 0, fmt.Errorf("write to closed stream")
+	}
+	n := 0
+	for len(p) > 0 {
+		k := min(len(p), len(cw.buf))
+		cw.s.xor(cw.buf[:k], p[:k])
+		if _, err := cw.w.Write(cw.buf[:k]); err != nil {
+			return n, err
+		}
+		n += k
+		p = p[k:]
+	}
+	return n, nil
+}
+
+func (cw *ctrWriter) Close() error {
+	cw.closed = true
+	return nil
+}
+
+type ctrReader struct {
+	r io.Reader
+	s *ctrState
+}
+
+// NewCTRReader returns a reader that CTR-decrypts (or encrypts, CTR is
+// symmetric) everything read from r
+func NewCTRReader(r io.Reader, key, iv []byte) (io.Reader, error) {
+	s, err := newCTRState(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &ctrReader{r: r, s: s}, nil
+}
+
+func (cr *ctrReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.s.xor(p[:n], p[:n])
+	return n, err
+}
+
+// CTRKeystream is a CTR keystream that can be repositioned, for decrypting
+// a byte range without starting from the beginning. It implements
+// cipher.Stream.
+type CTRKeystream struct {
+	s  *ctrState
+	iv [16]byte
+}
+
+// NewCTRKeystream returns the keystream for key starting at counter block iv
+func NewCTRKeystream(key, iv []byte) (*CTRKeystream, error) {
+	s, err := newCTRState(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &CTRKeystream{s: s, iv: s.counter}, nil
+}
+
+// XORKeyStream sets dst = src XOR the next len(src) keystream bytes. dst
+// and src may overlap entirely or not at all.
+func (k *CTRKeystream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("CTRKeystream.XORKeyStream output smaller than input")
+	}
+	k.s.xor(dst[:len(src)], src)
+}
+
+// Seek moves to the start of block blockOffset, counted from iv, so the
+// next byte used is byte 16*blockOffset of the keystream
+func (k *CTRKeystream) Seek(blockOffset uint64) {
+	k.s.counter = k.iv
+	addCounter(k.s.counter[:], blockOffset)
+	k.s.used = 16
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestCTRStreamMatchesOneShot(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := make([]byte, 1<<20)
+	rand.Read(plaintext)
+	want, err := CTREncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewCTRWriter(&buf, key, iv)
+	if err != nil {
+		t.Fatalf("NewCTRWriter failed: %v", err)
+	}
+	// Odd write sizes cross block and chunk boundaries
+	for p := plaintext; len(p) > 0; {
+		n := min(len(p), 12345)
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatal("Streamed ciphertext doesn't match CTREncrypt")
+	}
+
+	r, err := NewCTRReader(&buf, key, iv)
+	if err != nil {
+		t.Fatalf("NewCTRReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestCTRStreamBadIV(t *testing.T) {
+	key := []byte("1234567890123456")
+	if _, err := NewCTRWriter(io.Discard, key, make([]byte, 12)); err == nil {
+		t.Error("Expected error for 12-byte IV")
+	}
+	if _, err := NewCTRReader(bytes.NewReader(nil), key, make([]byte, 12)); err == nil {
+		t.Error("Expected error for 12-byte IV")
+	}
+}
+
+func TestCTRKeystreamSeek(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := make([]byte, 200*16+7)
+	rand.Read(plaintext)
+	ct, err := CTREncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+
+	ks, err := NewCTRKeystream(key, iv)
+	if err != nil {
+		t.Fatalf("NewCTRKeystream failed: %v", err)
+	}
+	var stream cipher.Stream = ks
+
+	// Decrypt a range starting at block 100 without touching the blocks
+	// before it, in two uneven pieces
+	ks.Seek(100)
+	got := make([]byte, 50)
+	stream.XORKeyStream(got[:21], ct[1600:1621])
+	stream.XORKeyStream(got[21:], ct[1621:1650])
+	if !bytes.Equal(got, plaintext[1600:1650]) {
+		t.Error("Range decrypted after Seek(100) doesn't match")
+	}
+
+	// Seeking back to 0 restarts the keystream, in place
+	ks.Seek(0)
+	buf := append([]byte(nil), ct...)
+	stream.XORKeyStream(buf, buf)
+	if !bytes.Equal(buf, plaintext) {
+		t.Error("Whole buffer decrypted after Seek(0) doesn't match")
+	}
+
+	if _, err := NewCTRKeystream(key, iv[:8]); err == nil {
+		t.Error("Expected error for short IV")
+	}
+}
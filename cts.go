@@ -0,0 +1,106 @@
+package main
+
+import "fmt"
+
+// CBC with ciphertext stealing, variant CS3 (NIST SP 800-38A addendum, the
+// variant Kerberos uses in RFC 3962). The last plaintext block is
+// zero-padded for encryption, and the last two ciphertext blocks are
+// swapped with the padding's share cut off. The output is then exactly as
+// long as the input. A single block is plain CBC.
+//
+// As with CBC, nothing is authenticated.
+
+// ctsSplit returns where the final, possibly partial, block starts
+func ctsSplit(n int) int {
+	return (n - 1) / 16 * 16
+}
+
+func checkCTSInput(n int, iv []byte) error {
+	if len(iv) != 16 {
+		return fmt.Errorf("CBC-CTS requires 16-byte IV")
+	}
+	if n < 16 {
+		return fmt.Errorf("CBC-CTS requires at least one full block (16 bytes), got %d", n)
+	}
+	return nil
+}
+
+// CBCEncryptCTS encrypts plaintext of at least 16 bytes in CBC mode with
+// CS3 ciphertext stealing. Returns: ciphertext, the same length as plaintext
+func CBCEncryptCTS(plaintext, key, iv []byte) ([]byte, error) {
+	if err := checkCTSInput(len(plaintext), iv); err != nil {
+		return nil, err
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	n := len(plaintext)
+	split := ctsSplit(n)
+	out := make([]byte, n)
+	prev := iv
+	for i := 0; i < split; i += 16 {
+		block := out[i : i+16]
+		xorBlocks(block, plaintext[i:i+16], prev)
+		c.Encrypt(block, block)
+		prev = block
+	}
+	var last [16]byte
+	copy(last[:], plaintext[split:])
+	xorBlocks(last[:], last[:], prev)
+	c.Encrypt(last[:], last[:])
+	if split == 0 {
+		copy(out, last[:])
+		return out, nil
+	}
+	// prev is the second-to-last block, which moves to the end and keeps
+	// only as many bytes as the final plaintext block has
+	copy(out[split:], prev[:n-split])
+	copy(out[split-16:split], last[:])
+	return out, nil
+}
+
+// CBCDecryptCTS decrypts the output of CBCEncryptCTS
+func CBCDecryptCTS(ciphertext, key, iv []byte) ([]byte, error) {
+	if err := checkCTSInput(len(ciphertext), iv); err != nil {
+		return nil, err
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	n := len(ciphertext)
+	split := ctsSplit(n)
+	out := make([]byte, n)
+	var block [16]byte
+	if split == 0 {
+		c.Decrypt(block[:], ciphertext)
+		xorBlocks(out, block[:], iv)
+		return out, nil
+	}
+	prev := iv
+	for i := 0; i+16 < split; i += 16 {
+		c.Decrypt(block[:], ciphertext[i:i+16])
+		xorBlocks(out[i:i+16], block[:], prev)
+		prev = ciphertext[i : i+16]
+	}
+	// Decrypting the swapped-in block gives the real second-to-last
+	// ciphertext block XOR the zero-padded final plaintext block, so its
+	// tail is the part of that ciphertext block that was cut off
+	d := n - split
+	tail := ciphertext[split:]
+	c.Decrypt(block[:], ciphertext[split-16:split])
+	var stolen [16]byte
+	copy(stolen[:], tail)
+	copy(stolen[d:], block[d:])
+	for i := 0; i < d; i++ {
+		out[split+i] = block[i] ^ tail[i]
+	}
+	c.Decrypt(block[:], stolen[:])
+	xorBlocks(out[split-16:split], block[:], prev)
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCBCCTSRFC3962(t *testing.T) {
+	// RFC 3962 appendix B: AES-128 CTS with a zero IV
+	key := []byte("chicken teriyaki")
+	iv := make([]byte, 16)
+	plaintext := []byte("I would like the General Gau's Chicken, please, and wonton soup.")
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{17, "c6353568f2bf8cb4d8a580362da7ff7f97"},
+		{31, "fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5"},
+		{32, "39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584"},
+		{47, "97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e39312523a78662d5be7fcbcc98ebf5"},
+		{48, "97687268d6ecccc0c07b25e25ecfe5849dad8bbb96c4cdc03bc103e1a194bbd839312523a78662d5be7fcbcc98ebf5a8"},
+		{64, "97687268d6ecccc0c07b25e25ecfe58439312523a78662d5be7fcbcc98ebf5a84807efe836ee89a526730dbc2f7bc8409dad8bbb96c4cdc03bc103e1a194bbd8"},
+	} {
+		got, err := CBCEncryptCTS(plaintext[:tc.n], key, iv)
+		if err != nil {
+			t.Fatalf("CBCEncryptCTS(%d bytes) failed: %v", tc.n, err)
+		}
+		if !bytes.Equal(got, mustHex(t, tc.want)) {
+			t.Errorf("CBCEncryptCTS(%d bytes) = %x, want %s", tc.n, got, tc.want)
+		}
+		pt, err := CBCDecryptCTS(got, key, iv)
+		if err != nil {
+			t.Fatalf("CBCDecryptCTS(%d bytes) failed: %v", tc.n, err)
+		}
+		if !bytes.Equal(pt, plaintext[:tc.n]) {
+			t.Errorf("CBCDecryptCTS(%d bytes) = %q", tc.n, pt)
+		}
+	}
+}
+
+func TestCBCCTSRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	for _, n := range []int{16, 17, 20, 31, 48, 100} {
+		plaintext := make([]byte, n)
+		rand.Read(plaintext)
+		ct, err := CBCEncryptCTS(plaintext, key, iv)
+		if err != nil {
+			t.Fatalf("CBCEncryptCTS(%d bytes) failed: %v", n, err)
+		}
+		if len(ct) != n {
+			t.Errorf("%d-byte plaintext gave %d bytes of ciphertext", n, len(ct))
+		}
+		pt, err := CBCDecryptCTS(ct, key, iv)
+		if err != nil {
+			t.Fatalf("CBCDecryptCTS(%d bytes) failed: %v", n, err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("%d bytes: decrypted text doesn't match", n)
+		}
+	}
+
+	// One block is plain CBC without padding
+	block := []byte("exactly16bytes!!")
+	ct, _ := CBCEncryptCTS(block, key, iv)
+	cbc, _ := CBCEncrypt(block, key, iv)
+	if !bytes.Equal(ct, cbc[:16]) {
+		t.Error("Single-block CTS differs from CBC")
+	}
+
+	if _, err := CBCEncryptCTS(make([]byte, 15), key, iv); err == nil {
+		t.Error("Expected error for input shorter than a block")
+	}
+	if _, err := CBCDecryptCTS(make([]byte, 15), key, iv); err == nil {
+		t.Error("Expected error for ciphertext shorter than a block")
+	}
+	if _, err := CBCEncryptCTS(make([]byte, 32), key, iv[:8]); err == nil {
+		t.Error("Expected error for short IV")
+	}
+}
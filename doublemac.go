@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// ErrOuterMAC is returned when the outer HMAC of a double-MAC blob fails
+var ErrOuterMAC = fmt.Errorf("%w: outer HMAC mismatch", ErrAuthFailed)
+
+// GCMEncryptDoubleMAC encrypts with GCM under encKey and adds an independent
+// HMAC-SHA256 over the whole GCM blob under macKey, so a forgery would have to
+// defeat both. The nonce must be 12 bytes, since the blob has no room to
+// record any other length. Returns: HMAC (32 bytes) || nonce (12 bytes) || ciphertext || tag (16 bytes)
+func GCMEncryptDoubleMAC(plaintext, encKey, macKey, nonce, aad []byte) ([]byte, error) {
+	if len(macKey) == 0 {
+		return nil, fmt.Errorf("double MAC requires a MAC key")
+	}
+	if len(nonce) != gcmNonceSize {
+		return nil, fmt.Errorf("nonce must be %d bytes", gcmNonceSize)
+	}
+	ct, err := GCMEncrypt(plaintext, encKey, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(nonce)
+	mac.Write(ct)
+	out := make([]byte, 0, sha256.Size+len(nonce)+len(ct))
+	out = mac.Sum(out)
+	out = append(out, nonce...)
+	return append(out, ct...), nil
+}
+
+// GCMDecryptDoubleMAC checks the outer HMAC before doing any GCM work, then
+// verifies and decrypts the inner GCM blob
+func GCMDecryptDoubleMAC(blob, encKey, macKey, aad []byte) ([]byte, error) {
+	if len(macKey) == 0 {
+		return nil, fmt.Errorf("double MAC requires a MAC key")
+	}
+	if len(blob) < sha256.Size+gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("ciphertext too short (must have HMAC + nonce + tag)")
+	}
+	inner := blob[sha256.Size:]
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(inner)
+	if !hmac.Equal(mac.Sum(nil), blob[:sha256.Size]) {
+		return nil, ErrOuterMAC
+	}
+	return GCMDecrypt(inner[gcmNonceSize:], encKey, inner[:gcmNonceSize], aad)
+}
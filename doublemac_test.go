@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGCMDoubleMAC(t *testing.T) {
+	encKey := []byte("1234567890123456")
+	macKey := []byte("independent HMAC key, 32 bytes!")
+	nonce := []byte("123456789012")
+	plaintext := []byte("two independent integrity layers")
+	aad := []byte("aad")
+
+	blob, err := GCMEncryptDoubleMAC(plaintext, encKey, macKey, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncryptDoubleMAC failed: %v", err)
+	}
+	decrypted, err := GCMDecryptDoubleMAC(blob, encKey, macKey, aad)
+	if err != nil {
+		t.Fatalf("GCMDecryptDoubleMAC failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	// Any change to the GCM blob is caught by the outer HMAC, before GCM
+	for _, i := range []int{32, 32 + 12, len(blob) - 1} {
+		tampered := append([]byte(nil), blob...)
+		tampered[i] ^= 0x01
+		if _, err := GCMDecryptDoubleMAC(tampered, encKey, macKey, aad); err != ErrOuterMAC {
+			t.Errorf("Tampering byte %d: expected ErrOuterMAC, got %v", i, err)
+		}
+	}
+	if _, err := GCMDecryptDoubleMAC(blob, encKey, []byte("wrong mac key"), aad); err != ErrOuterMAC {
+		t.Errorf("Wrong MAC key: expected ErrOuterMAC, got %v", err)
+	}
+
+	// With the outer layer intact, GCM still checks the AAD
+	_, err = GCMDecryptDoubleMAC(blob, encKey, macKey, []byte("other"))
+	if err == nil || err == ErrOuterMAC {
+		t.Errorf("Wrong AAD: expected GCM authentication failure, got %v", err)
+	}
+
+	// GCM takes other nonce lengths, but the blob could not be decrypted
+	for _, n := range []int{8, 16} {
+		if _, err := GCMEncryptDoubleMAC(plaintext, encKey, macKey, make([]byte, n), aad); err == nil {
+			t.Errorf("Expected error for %d-byte nonce", n)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+// ECB mode encrypts each 16-byte block independently, so identical
+// plaintext blocks give identical ciphertext blocks and the structure of
+// the data shows through. It is here only to read legacy data; the
+// Insecure suffix is deliberate.
+
+// ECBEncryptInsecure encrypts data in ECB mode with PKCS#7 padding
+func ECBEncryptInsecure(data, key []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := PKCS7Pad(data, 16)
+	for i := 0; i < len(out); i += 16 {
+		c.Encrypt(out[i:i+16], out[i:i+16])
+	}
+	return out, nil
+}
+
+// ECBDecryptInsecure decrypts ECB data and removes the PKCS#7 padding
+func ECBDecryptInsecure(data, key []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%16 != 0 {
+		return nil, ErrInvalidPadding
+	}
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += 16 {
+		c.Decrypt(out[i:i+16], data[i:i+16])
+	}
+	return PKCS7Unpad(out, 16)
+}
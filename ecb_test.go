@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestECBRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	for _, n := range []int{0, 5, 16, 47, 64} {
+		plaintext := bytes.Repeat([]byte("legacy record!"), 5)[:n]
+		ct, err := ECBEncryptInsecure(plaintext, key)
+		if err != nil {
+			t.Fatalf("ECBEncryptInsecure failed: %v", err)
+		}
+		if len(ct)%16 != 0 || len(ct) <= n {
+			t.Errorf("%d-byte plaintext: bad ciphertext length %d", n, len(ct))
+		}
+		pt, err := ECBDecryptInsecure(ct, key)
+		if err != nil {
+			t.Fatalf("ECBDecryptInsecure failed: %v", err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("%d-byte plaintext: decrypted text doesn't match", n)
+		}
+	}
+}
+
+func TestECBFirstBlockVector(t *testing.T) {
+	// SP 800-38A F.1.1 ECB-AES128.Encrypt, first block; the second output
+	// block is the padding
+	key := mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	pt := mustHex(t, "6bc1bee22e409f96e93d7e117393172a")
+	want := mustHex(t, "3ad77bb40d7a3660a89ecaf32466ef97")
+	ct, err := ECBEncryptInsecure(pt, key)
+	if err != nil {
+		t.Fatalf("ECBEncryptInsecure failed: %v", err)
+	}
+	if !bytes.Equal(ct[:16], want) {
+		t.Errorf("ECBEncryptInsecure = %x, want %x...", ct, want)
+	}
+}
+
+// TestECBLeaksRepeatedBlocks documents why ECB is insecure: equal
+// plaintext blocks encrypt to equal ciphertext blocks
+func TestECBLeaksRepeatedBlocks(t *testing.T) {
+	key := []byte("1234567890123456")
+	block := []byte("YELLOW SUBMARINE")
+	plaintext := bytes.Repeat(block, 3)
+	ct, err := ECBEncryptInsecure(plaintext, key)
+	if err != nil {
+		t.Fatalf("ECBEncryptInsecure failed: %v", err)
+	}
+	if !bytes.Equal(ct[0:16], ct[16:32]) || !bytes.Equal(ct[16:32], ct[32:48]) {
+		t.Error("Expected identical plaintext blocks to give identical ciphertext blocks")
+	}
+}
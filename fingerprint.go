@@ -0,0 +1,16 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// keyFingerprintSize is how many bytes of the SHA-256 digest KeyFingerprint keeps
+const keyFingerprintSize = 8
+
+// KeyFingerprint returns a short key ID: the first 8 bytes of SHA-256(key)
+// as hex. It identifies which key was used in logs without revealing it.
+func KeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:keyFingerprintSize])
+}
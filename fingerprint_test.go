@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestKeyFingerprint(t *testing.T) {
+	key1 := []byte("1234567890123456")
+	key2 := []byte("6543210987654321")
+
+	fp := KeyFingerprint(key1)
+	if fp != KeyFingerprint(key1) {
+		t.Error("Same key gave different fingerprints")
+	}
+	if fp == KeyFingerprint(key2) {
+		t.Error("Different keys gave the same fingerprint")
+	}
+	if len(fp) != 2*keyFingerprintSize {
+		t.Errorf("Fingerprint length %d, want %d", len(fp), 2*keyFingerprintSize)
+	}
+	if fp == string(key1) || strings.Contains(hex.EncodeToString(key1), fp) {
+		t.Error("Fingerprint reveals the key")
+	}
+}
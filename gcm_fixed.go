@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// fixedLenPrefix is the size of the big-endian length field stored in front
+// of the plaintext before it is padded out to maxLen
+const fixedLenPrefix = 4
+
+// GCMEncryptFixedSize encrypts plaintext so that every output produced with
+// the same maxLen has the same size, hiding the true message length.
+// The plaintext is prefixed with its length and zero-padded to maxLen before
+// encryption. Returns: ciphertext || tag (16 bytes)
+func GCMEncryptFixedSize(plaintext, key, nonce, aad []byte, maxLen int) ([]byte, error) {
+	if maxLen < 0 || uint64(maxLen) > 0xffffffff {
+		return nil, fmt.Errorf("invalid maximum length %d", maxLen)
+	}
+	if len(plaintext) > maxLen {
+		return nil, fmt.Errorf("plaintext length %d exceeds maximum %d", len(plaintext), maxLen)
+	}
+	padded := make([]byte, fixedLenPrefix+maxLen)
+	binary.BigEndian.PutUint32(padded, uint32(len(plaintext)))
+	copy(padded[fixedLenPrefix:], plaintext)
+	return GCMEncrypt(padded, key, nonce, aad)
+}
+
+// GCMDecryptFixedSize verifies and decrypts output of GCMEncryptFixedSize
+// and strips the padding
+func GCMDecryptFixedSize(ciphertextWithTag, key, nonce, aad []byte) ([]byte, error) {
+	padded, err := GCMDecrypt(ciphertextWithTag, key, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	if len(padded) < fixedLenPrefix {
+		return nil, fmt.Errorf("fixed-size payload too short")
+	}
+	n := binary.BigEndian.Uint32(padded)
+	if uint64(n) > uint64(len(padded)-fixedLenPrefix) {
+		return nil, fmt.Errorf("fixed-size length field out of range")
+	}
+	return padded[fixedLenPrefix : fixedLenPrefix+int(n)], nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGCMFixedSizeEqualLengths(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("fixed")
+	short := bytes.Repeat([]byte("a"), 10)
+	long := bytes.Repeat([]byte("b"), 500)
+
+	ct1, err := GCMEncryptFixedSize(short, key, nonce, aad, 1024)
+	if err != nil {
+		t.Fatalf("GCMEncryptFixedSize failed: %v", err)
+	}
+	ct2, err := GCMEncryptFixedSize(long, key, nonce, aad, 1024)
+	if err != nil {
+		t.Fatalf("GCMEncryptFixedSize failed: %v", err)
+	}
+	if len(ct1) != len(ct2) {
+		t.Errorf("Ciphertext lengths differ: %d vs %d", len(ct1), len(ct2))
+	}
+
+	for _, tc := range []struct {
+		ct, want []byte
+	}{{ct1, short}, {ct2, long}} {
+		pt, err := GCMDecryptFixedSize(tc.ct, key, nonce, aad)
+		if err != nil {
+			t.Fatalf("GCMDecryptFixedSize failed: %v", err)
+		}
+		if !bytes.Equal(pt, tc.want) {
+			t.Errorf("Decrypted text doesn't match for %d-byte plaintext", len(tc.want))
+		}
+	}
+}
+
+func TestGCMFixedSizeTooLong(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	_, err := GCMEncryptFixedSize(make([]byte, 65), key, nonce, nil, 64)
+	if err == nil {
+		t.Error("Expected error for plaintext over maxLen")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// UnsafeGCMEncryptCounterStart encrypts with an explicit 16-byte initial
+// counter block (J0) instead of deriving it from a 12-byte nonce.
+// This exists only for interop with non-standard implementations: the caller
+// is responsible for never repeating a counter range under the same key,
+// and any J0 that is not nonce || 0^31 || 1 is outside the GCM spec.
+// The tag is masked with E(K, J0) and data encryption starts at inc32(J0).
+// Returns: ciphertext || tag (16 bytes)
+func UnsafeGCMEncryptCounterStart(plaintext, key, initialCounter, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(initialCounter) != 16 {
+		return nil, fmt.Errorf("initial counter must be 16 bytes")
+	}
+	return c.gcmSeal(plaintext, initialCounter, aad)
+}
+
+// UnsafeGCMDecryptCounterStart is the inverse of UnsafeGCMEncryptCounterStart
+func UnsafeGCMDecryptCounterStart(ciphertextWithTag, key, initialCounter, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(initialCounter) != 16 {
+		return nil, fmt.Errorf("initial counter must be 16 bytes")
+	}
+	return c.gcmOpen(ciphertextWithTag, initialCounter, aad)
+}
+
+// gcmLengthBlockLE is gcmLengthBlock with each length little-endian, the
+// encoding some broken GHASH implementations use
+func gcmLengthBlockLE(aadLen, ctLen int) []byte {
+	lenBlock := make([]byte, 16)
+	binary.LittleEndian.PutUint64(lenBlock, uint64(aadLen)*8)
+	binary.LittleEndian.PutUint64(lenBlock[8:], uint64(ctLen)*8)
+	return lenBlock
+}
+
+// UnsafeGCMEncryptLittleEndianLengths is GCMEncrypt with the lengths in the
+// final GHASH block encoded little-endian. This is NOT GCM: the ciphertext
+// matches GCMEncrypt but the tag differs whenever aad or plaintext is
+// non-empty. It exists only to diagnose why a third-party implementation's
+// tags disagree with this package; never use it to protect data.
+// Returns: ciphertext || tag (16 bytes)
+func UnsafeGCMEncryptLittleEndianLengths(plaintext, key, nonce, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	j0, err := c.gcmJ0(nonce)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.gcmSeal(plaintext, j0, aad)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := out[:len(plaintext)]
+	copy(out[len(plaintext):], c.gcmTagWithLengths(j0, aad, ciphertext, gcmLengthBlockLE(len(aad), len(ciphertext))))
+	return out, nil
+}
+
+// UnsafeGCMDecryptLittleEndianLengths is the inverse of
+// UnsafeGCMEncryptLittleEndianLengths
+func UnsafeGCMDecryptLittleEndianLengths(ciphertextWithTag, key, nonce, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	j0, err := c.gcmJ0(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertextWithTag) < 16 {
+		return nil, fmt.Errorf("ciphertext too short (must include 16-byte tag)")
+	}
+	ciphertext := ciphertextWithTag[:len(ciphertextWithTag)-16]
+	want := c.gcmTagWithLengths(j0, aad, ciphertext, gcmLengthBlockLE(len(aad), len(ciphertext)))
+	if !tagsEqual(ciphertextWithTag[len(ciphertext):], want) {
+		return nil, fmt.Errorf("%w: tag mismatch", ErrAuthFailed)
+	}
+	counter := append([]byte{}, j0...)
+	inc32(counter)
+	out := make([]byte, len(ciphertext))
+	c.gcmCTR(out, ciphertext, counter)
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestUnsafeGCMCounterStartMatchesStandard(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	plaintext := []byte("standard counter start")
+	aad := []byte("aad")
+
+	j0 := append(append([]byte{}, nonce...), 0, 0, 0, 1)
+	got, err := UnsafeGCMEncryptCounterStart(plaintext, key, j0, aad)
+	if err != nil {
+		t.Fatalf("UnsafeGCMEncryptCounterStart failed: %v", err)
+	}
+	want, err := GCMEncrypt(plaintext, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Counter start nonce||1 should match GCMEncrypt")
+	}
+}
+
+func TestUnsafeGCMCounterStartReference(t *testing.T) {
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	// Low 32 bits of the counter wrap on the first increment
+	j0 := append([]byte("123456789012"), 0xff, 0xff, 0xff, 0xff)
+	plaintext := []byte("interop counter start reference vector!!")
+	aad := []byte("interop")
+	want, _ := hex.DecodeString("4ddbcb5bbfd8b25931f0ca168e30d5cc5e15ed6ec338a59f359f5a9f05472ec1" +
+		"9bdd2939a5a0671ffb2d5b5f83e2bcaf47e8202c2e43d2b8")
+
+	got, err := UnsafeGCMEncryptCounterStart(plaintext, key, j0, aad)
+	if err != nil {
+		t.Fatalf("UnsafeGCMEncryptCounterStart failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Reference mismatch: got %x, want %x", got, want)
+	}
+
+	decrypted, err := UnsafeGCMDecryptCounterStart(got, key, j0, aad)
+	if err != nil {
+		t.Fatalf("UnsafeGCMDecryptCounterStart failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestUnsafeGCMLittleEndianLengths(t *testing.T) {
+	// GCM spec (McGrew and Viega) test case 4
+	key := mustHex(t, "feffe9928665731c6d6a8f9467308308")
+	nonce := mustHex(t, "cafebabefacedbaddecaf888")
+	aad := mustHex(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	pt := mustHex(t, "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72"+
+		"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39")
+	wantCT := mustHex(t, "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e"+
+		"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091")
+	wantTag := mustHex(t, "5bc94fbc3221a5db94fae95ae7121a47")
+	// Tag for the same inputs with little-endian lengths, as produced by
+	// the buggy implementations this mode exists to diagnose
+	wantTagLE := mustHex(t, "e42f45aefbb8e6e3becee795c563c31d")
+
+	std, err := GCMEncrypt(pt, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(std, append(append([]byte{}, wantCT...), wantTag...)) {
+		t.Fatalf("GCMEncrypt doesn't match test case 4: %x", std)
+	}
+
+	flipped, err := UnsafeGCMEncryptLittleEndianLengths(pt, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("UnsafeGCMEncryptLittleEndianLengths failed: %v", err)
+	}
+	if !bytes.Equal(flipped[:len(pt)], wantCT) {
+		t.Error("Flipped lengths changed the ciphertext")
+	}
+	if tag := flipped[len(pt):]; !bytes.Equal(tag, wantTagLE) {
+		t.Errorf("Flipped tag = %x, want %x", tag, wantTagLE)
+	}
+
+	// GHASH is linear in its input blocks, so the two tags differ by
+	// exactly (standard length block ^ flipped length block) * H
+	h := EncryptBlock(make([]byte, 16), key)
+	delta := gcmLengthBlock(len(aad), len(pt))
+	for i, b := range gcmLengthBlockLE(len(aad), len(pt)) {
+		delta[i] ^= b
+	}
+	delta = gfMul(delta, h)
+	for i := range delta {
+		delta[i] ^= wantTag[i]
+	}
+	if !bytes.Equal(delta, flipped[len(pt):]) {
+		t.Error("Flipped tag isn't the standard tag with a different length block")
+	}
+
+	got, err := UnsafeGCMDecryptLittleEndianLengths(flipped, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("UnsafeGCMDecryptLittleEndianLengths failed: %v", err)
+	}
+	if !bytes.Equal(got, pt) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+	if _, err := UnsafeGCMDecryptLittleEndianLengths(std, key, nonce, aad); err == nil {
+		t.Error("Expected standard tag to fail with flipped lengths")
+	}
+}
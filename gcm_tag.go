@@ -0,0 +1,50 @@
+package main
+
+import "fmt"
+
+// checkGCMTagBits accepts the tag lengths NIST SP 800-38D section 5.2.1.2
+// allows. 64- and 32-bit tags are only meant for protocols that limit how
+// many forgeries an attacker can attempt (see appendix C).
+func checkGCMTagBits(tagBits int) error {
+	switch tagBits {
+	case 128, 120, 112, 104, 96, 64, 32:
+		return nil
+	}
+	return fmt.Errorf("unsupported GCM tag length %d bits (must be 128, 120, 112, 104, 96, 64 or 32)", tagBits)
+}
+
+// GCMEncryptTag is GCMEncrypt with the tag truncated to tagBits bits.
+// Shorter tags make forgeries proportionally easier.
+// Returns: ciphertext || tag (tagBits/8 bytes)
+func GCMEncryptTag(plaintext, key, nonce, aad []byte, tagBits int) ([]byte, error) {
+	if err := checkGCMTagBits(tagBits); err != nil {
+		return nil, err
+	}
+	out, err := GCMEncrypt(plaintext, key, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	n := len(plaintext) + tagBits/8
+	// Don't leave the rest of the full tag behind the returned slice
+	clear(out[n:])
+	return out[:n], nil
+}
+
+// GCMDecryptTag verifies and decrypts the output of GCMEncryptTag. The
+// truncated tag is compared in constant time and tagBits must match what
+// the sender used.
+func GCMDecryptTag(ciphertextWithTag, key, nonce, aad []byte, tagBits int) ([]byte, error) {
+	if err := checkGCMTagBits(tagBits); err != nil {
+		return nil, err
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	j0, err := c.gcmJ0(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return c.gcmOpenTag(ciphertextWithTag, j0, aad, tagBits/8)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGCMTruncatedTags(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("header")
+	plaintext := []byte("short tags for a small radio link")
+
+	full, err := GCMEncrypt(plaintext, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	for _, bits := range []int{128, 96, 64} {
+		ct, err := GCMEncryptTag(plaintext, key, nonce, aad, bits)
+		if err != nil {
+			t.Fatalf("GCMEncryptTag(%d) failed: %v", bits, err)
+		}
+		if len(ct) != len(plaintext)+bits/8 {
+			t.Fatalf("%d-bit tag: output is %d bytes, want %d", bits, len(ct), len(plaintext)+bits/8)
+		}
+		if !bytes.Equal(ct, full[:len(ct)]) {
+			t.Errorf("%d-bit tag isn't a prefix of the full GCM output", bits)
+		}
+		pt, err := GCMDecryptTag(ct, key, nonce, aad, bits)
+		if err != nil {
+			t.Fatalf("GCMDecryptTag(%d) failed: %v", bits, err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("%d-bit tag: decrypted text doesn't match", bits)
+		}
+
+		bad := append([]byte(nil), ct...)
+		bad[len(bad)-1] ^= 1
+		if _, err := GCMDecryptTag(bad, key, nonce, aad, bits); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("%d-bit tag: expected ErrAuthFailed for a tampered tag, got %v", bits, err)
+		}
+		bad = append([]byte(nil), ct...)
+		bad[0] ^= 1
+		if _, err := GCMDecryptTag(bad, key, nonce, aad, bits); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("%d-bit tag: expected ErrAuthFailed for tampered ciphertext, got %v", bits, err)
+		}
+	}
+
+	// A tag only verifies at the length it was made with
+	ct, _ := GCMEncryptTag(plaintext, key, nonce, aad, 96)
+	if _, err := GCMDecryptTag(ct, key, nonce, aad, 64); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for the wrong tag length, got %v", err)
+	}
+	for _, bits := range []int{0, 8, 48, 100, 136} {
+		if _, err := GCMEncryptTag(plaintext, key, nonce, aad, bits); err == nil {
+			t.Errorf("GCMEncryptTag accepted a %d-bit tag", bits)
+		}
+		if _, err := GCMDecryptTag(ct, key, nonce, aad, bits); err == nil {
+			t.Errorf("GCMDecryptTag accepted a %d-bit tag", bits)
+		}
+	}
+	if _, err := GCMDecryptTag(ct[:7], key, nonce, aad, 64); err == nil {
+		t.Error("Expected error for input shorter than the tag")
+	}
+}
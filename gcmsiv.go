@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// AES-GCM-SIV (RFC 8452) derives a fresh authentication and encryption key
+// from each nonce and uses the tag, computed with POLYVAL over the
+// plaintext, as the CTR counter. Reusing a nonce therefore only reveals
+// whether two messages (with the same AAD) were identical.
+//
+//	output: ciphertext || tag (16 bytes)
+
+const gcmSIVMaxLen = 1 << 36
+
+// byteReverse returns b with its 16 bytes in reverse order
+func byteReverse(b []byte) [16]byte {
+	var r [16]byte
+	for i := range r {
+		r[i] = b[15-i]
+	}
+	return r
+}
+
+// polyvalKey converts a POLYVAL key into the GHASH key that gives the same
+// products, mulX_GHASH(ByteReverse(H)) from RFC 8452 appendix A
+func polyvalKey(h []byte) [16]byte {
+	v := byteReverse(h)
+	lsb := v[15] & 1
+	for j := 15; j > 0; j-- {
+		v[j] = (v[j] >> 1) | ((v[j-1] & 1) << 7)
+	}
+	v[0] >>= 1
+	if lsb != 0 {
+		v[0] ^= 0xE1
+	}
+	return v
+}
+
+// polyval computes POLYVAL(H, aad || plaintext || length block), with aad
+// and plaintext each zero-padded to a whole block. It runs on gfMulInto by
+// working on byte-reversed blocks.
+func polyval(h, aad, plaintext []byte) [16]byte {
+	gh := polyvalKey(h)
+	var s [16]byte
+	absorb := func(data []byte) {
+		for i := 0; i < len(data); i += 16 {
+			var blk [16]byte
+			copy(blk[:], data[i:min(i+16, len(data))])
+			for j := range s {
+				s[j] ^= blk[15-j]
+			}
+			gfMulInto(&s, gh[:])
+		}
+	}
+	absorb(aad)
+	absorb(plaintext)
+	var lenBlock [16]byte
+	binary.LittleEndian.PutUint64(lenBlock[:], uint64(len(aad))*8)
+	binary.LittleEndian.PutUint64(lenBlock[8:], uint64(len(plaintext))*8)
+	absorb(lenBlock[:])
+	return byteReverse(s[:])
+}
+
+// gcmSIVKeys derives the per-nonce POLYVAL key and AES key from
+// key-generating key kgk (RFC 8452 section 4)
+func gcmSIVKeys(kgk *Cipher, keyLen int, nonce []byte) (authKey []byte, enc *Cipher, err error) {
+	derived := make([]byte, 0, 16+keyLen)
+	var in, out [16]byte
+	copy(in[4:], nonce)
+	for i := uint32(0); len(derived) < 16+keyLen; i++ {
+		binary.LittleEndian.PutUint32(in[:4], i)
+		kgk.Encrypt(out[:], in[:])
+		derived = append(derived, out[:8]...)
+	}
+	enc, err = NewCipher(derived[16:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return derived[:16], enc, nil
+}
+
+// gcmSIVTag computes the tag over aad and plaintext
+func gcmSIVTag(enc *Cipher, authKey, nonce, aad, plaintext []byte) [16]byte {
+	s := polyval(authKey, aad, plaintext)
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+	var tag [16]byte
+	enc.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// gcmSIVCTR XORs data with the keystream whose initial counter block is the
+// tag with its top bit set. Only the first 32 bits (little-endian) count.
+func gcmSIVCTR(enc *Cipher, tag, data []byte) []byte {
+	out := make([]byte, len(data))
+	var counter, ks [16]byte
+	copy(counter[:], tag)
+	counter[15] |= 0x80
+	for i := 0; i < len(data); i += 16 {
+		enc.Encrypt(ks[:], counter[:])
+		for j := i; j < min(i+16, len(data)); j++ {
+			out[j] = data[j] ^ ks[j-i]
+		}
+		binary.LittleEndian.PutUint32(counter[:4], binary.LittleEndian.Uint32(counter[:4])+1)
+	}
+	return out
+}
+
+func newGCMSIV(key, nonce []byte) (authKey []byte, enc *Cipher, err error) {
+	if len(key) != 16 && len(key) != 32 {
+		return nil, nil, fmt.Errorf("AES-GCM-SIV requires a 16- or 32-byte key")
+	}
+	if len(nonce) != gcmNonceSize {
+		return nil, nil, fmt.Errorf("nonce must be %d bytes", gcmNonceSize)
+	}
+	kgk, err := NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gcmSIVKeys(kgk, len(key), nonce)
+}
+
+// GCMSIVEncrypt encrypts plaintext with AES-GCM-SIV using a 16- or 32-byte
+// key and a 12-byte nonce.
+// Returns: ciphertext || tag (16 bytes)
+func GCMSIVEncrypt(plaintext, key, nonce, aad []byte) ([]byte, error) {
+	authKey, enc, err := newGCMSIV(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLen || uint64(len(aad)) > gcmSIVMaxLen {
+		return nil, fmt.Errorf("AES-GCM-SIV input exceeds 2^36 bytes")
+	}
+	tag := gcmSIVTag(enc, authKey, nonce, aad, plaintext)
+	out := gcmSIVCTR(enc, tag[:], plaintext)
+	return append(out, tag[:]...), nil
+}
+
+// GCMSIVDecrypt verifies and decrypts the output of GCMSIVEncrypt
+func GCMSIVDecrypt(ciphertextWithTag, key, nonce, aad []byte) ([]byte, error) {
+	authKey, enc, err := newGCMSIV(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertextWithTag) < gcmTagSize {
+		return nil, fmt.Errorf("ciphertext too short (must include %d-byte tag)", gcmTagSize)
+	}
+	if uint64(len(ciphertextWithTag)-gcmTagSize) > gcmSIVMaxLen || uint64(len(aad)) > gcmSIVMaxLen {
+		return nil, fmt.Errorf("AES-GCM-SIV input exceeds 2^36 bytes")
+	}
+	n := len(ciphertextWithTag) - gcmTagSize
+	tag := ciphertextWithTag[n:]
+	pt := gcmSIVCTR(enc, tag, ciphertextWithTag[:n])
+	want := gcmSIVTag(enc, authKey, nonce, aad, pt)
+	if !tagsEqual(tag, want[:]) {
+		clear(pt)
+		return nil, fmt.Errorf("%w: tag mismatch", ErrAuthFailed)
+	}
+	return pt, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGCMSIVVectors(t *testing.T) {
+	// RFC 8452 appendix C.1 and C.2
+	key128 := mustHex(t, "01000000000000000000000000000000")
+	key256 := mustHex(t, "0100000000000000000000000000000000000000000000000000000000000000")
+	nonce := mustHex(t, "030000000000000000000000")
+	tests := []struct {
+		key, pt, aad []byte
+		want         string
+	}{
+		{key128, nil, nil, "dc20e2d83f25705bb49e439eca56de25"},
+		{key128, mustHex(t, "0100000000000000"), nil, "b5d839330ac7b786578782fff6013b815b287c22493a364c"},
+		{key128, mustHex(t, "0200000000000000"), mustHex(t, "01"), "1e6daba35669f4273b0a1a2560969cdf790d99759abd1508"},
+		{key256, nil, nil, "07f5f4169bbf55a8400cd47ea6fd400f"},
+	}
+	for i, tc := range tests {
+		ct, err := GCMSIVEncrypt(tc.pt, tc.key, nonce, tc.aad)
+		if err != nil {
+			t.Fatalf("GCMSIVEncrypt failed: %v", err)
+		}
+		if want := mustHex(t, tc.want); !bytes.Equal(ct, want) {
+			t.Errorf("vector %d: got %x, want %x", i, ct, want)
+		}
+		pt, err := GCMSIVDecrypt(ct, tc.key, nonce, tc.aad)
+		if err != nil {
+			t.Fatalf("GCMSIVDecrypt failed: %v", err)
+		}
+		if !bytes.Equal(pt, tc.pt) {
+			t.Errorf("vector %d: decrypted text doesn't match", i)
+		}
+	}
+}
+
+func TestGCMSIVRoundTripAndTamper(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	aad := []byte("header")
+	plaintext := []byte("GCM-SIV survives a repeated nonce, up to equality")
+
+	ct, err := GCMSIVEncrypt(plaintext, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMSIVEncrypt failed: %v", err)
+	}
+	pt, err := GCMSIVDecrypt(ct, key, nonce, aad)
+	if err != nil {
+		t.Fatalf("GCMSIVDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	for i := range ct {
+		bad := append([]byte(nil), ct...)
+		bad[i] ^= 1
+		if _, err := GCMSIVDecrypt(bad, key, nonce, aad); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("Byte %d: expected ErrAuthFailed, got %v", i, err)
+		}
+	}
+	if _, err := GCMSIVDecrypt(ct, key, nonce, []byte("other")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for wrong AAD, got %v", err)
+	}
+}
+
+func TestGCMSIVNonceReuse(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	a, _ := GCMSIVEncrypt([]byte("message one"), key, nonce, nil)
+	b, _ := GCMSIVEncrypt([]byte("message two"), key, nonce, nil)
+	c, _ := GCMSIVEncrypt([]byte("message one"), key, nonce, nil)
+	if !bytes.Equal(a, c) {
+		t.Error("Same inputs should give the same output")
+	}
+	// Unlike GCM, the keystreams differ, so XORing ciphertexts doesn't
+	// give the XOR of the plaintexts
+	p1, p2 := []byte("message one"), []byte("message two")
+	same := true
+	for i := range p1 {
+		if a[i]^b[i] != p1[i]^p2[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Error("Repeated nonce reused the keystream")
+	}
+}
+
+func TestGCMSIVBadParams(t *testing.T) {
+	if _, err := GCMSIVEncrypt(nil, make([]byte, 24), make([]byte, 12), nil); err == nil {
+		t.Error("Expected error for 24-byte key")
+	}
+	if _, err := GCMSIVEncrypt(nil, make([]byte, 16), make([]byte, 16), nil); err == nil {
+		t.Error("Expected error for 16-byte nonce")
+	}
+	if _, err := GCMSIVDecrypt(make([]byte, 15), make([]byte, 16), make([]byte, 12), nil); err == nil {
+		t.Error("Expected error for short ciphertext")
+	}
+}
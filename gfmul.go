@@ -0,0 +1,133 @@
+package main
+
+import "encoding/binary"
+
+// A faster GF(2^128) multiplier for GHASH. gfMul in aes.go is the
+// reference bit-at-a-time version; the table below must always agree with
+// it. Both are constant-time.
+
+// gfElement is a GF(2^128) element split into two big-endian halves, in
+// GCM's bit order (the first byte of a block holds the lowest powers)
+type gfElement struct {
+	low, high uint64
+}
+
+func loadGFElement(b []byte) gfElement {
+	return gfElement{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+func (e gfElement) bytes() []byte {
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[:8], e.low)
+	binary.BigEndian.PutUint64(out[8:], e.high)
+	return out
+}
+
+// double multiplies by x, reducing by the GCM polynomial. The element is
+// derived from H, so the reduction is applied through a mask, not a branch.
+func (e gfElement) double() gfElement {
+	d := gfElement{low: e.low >> 1, high: e.high>>1 | e.low<<63}
+	d.low ^= 0xe100000000000000 & -(e.high & 1)
+	return d
+}
+
+// ghashTable multiplies by a fixed H four bits at a time (Shoup's method).
+// The 4-bit digits it looks up are secret, so mulInto never indexes with
+// them: it reads all 16 products and keeps one through a mask, and computes
+// the reduction from the digit's bits, so the memory accesses are the same
+// whatever the data.
+type ghashTable struct {
+	// products[reverse4(i)] = i * H for each 4-bit i
+	products [16]gfElement
+}
+
+// reverse4 reverses the order of the low four bits of i
+func reverse4(i int) int {
+	i = (i<<2)&0xc | (i>>2)&0x3
+	i = (i<<1)&0xa | (i>>1)&0x5
+	return i
+}
+
+// gfReduction4 returns the reduction applied for the 4-bit value m shifted
+// out of the element. It is linear in m, so it is built from m's bits
+// rather than looked up: 0x1c20, 0x3840, 0x7080 and 0xe100 for bits 0-3.
+func gfReduction4(m uint64) uint64 {
+	return -(m&1)&0x1c20 ^ -(m>>1&1)&0x3840 ^ -(m>>2&1)&0x7080 ^ -(m>>3&1)&0xe100
+}
+
+// product returns t.products[i] reading every entry, so the access
+// pattern doesn't depend on i
+func (t *ghashTable) product(i uint64) gfElement {
+	var p gfElement
+	for k := range t.products {
+		// All ones when k == i: k^i-1 only wraps for k^i == 0
+		mask := -((uint64(k) ^ i - 1) >> 63)
+		p.low ^= t.products[k].low & mask
+		p.high ^= t.products[k].high & mask
+	}
+	return p
+}
+
+func newGHASHTable(h []byte) *ghashTable {
+	t := &ghashTable{}
+	x := loadGFElement(h)
+	t.products[reverse4(1)] = x
+	for i := 2; i < 16; i += 2 {
+		t.products[reverse4(i)] = t.products[reverse4(i/2)].double()
+		p := t.products[reverse4(i)]
+		t.products[reverse4(i+1)] = gfElement{p.low ^ x.low, p.high ^ x.high}
+	}
+	return t
+}
+
+// mul returns y * H
+func (t *ghashTable) mul(y []byte) []byte {
+	var x [16]byte
+	copy(x[:], y)
+	t.mulInto(&x)
+	return x[:]
+}
+
+// mulInto sets x = x * H without allocating
+func (t *ghashTable) mulInto(x *[16]byte) {
+	e := loadGFElement(x[:])
+	var z gfElement
+	for _, word := range [2]uint64{e.high, e.low} {
+		for j := 0; j < 64; j += 4 {
+			msw := z.high & 0xf
+			z.high = z.high>>4 | z.low<<60
+			z.low >>= 4
+			z.low ^= gfReduction4(msw) << 48
+			p := t.product(word & 0xf)
+			z.low ^= p.low
+			z.high ^= p.high
+			word >>= 4
+		}
+	}
+	binary.BigEndian.PutUint64(x[:8], z.low)
+	binary.BigEndian.PutUint64(x[8:], z.high)
+}
+
+// ghashInto is the package-level ghashInto with every multiplication by H
+// done through the table. GCM uses this one; the bitwise version stays as
+// the reference it is tested against.
+func (t *ghashTable) ghashInto(tag *[16]byte, aad, ciphertext, lenBlock []byte) {
+	*tag = [16]byte{}
+	t.update(tag, aad)
+	t.update(tag, ciphertext)
+	for j := 0; j < 16; j++ {
+		tag[j] ^= lenBlock[j]
+	}
+	t.mulInto(tag)
+}
+
+// update absorbs data into tag, zero-padding the last block
+func (t *ghashTable) update(tag *[16]byte, data []byte) {
+	for i := 0; i < len(data); i += 16 {
+		n := min(16, len(data)-i)
+		for j := 0; j < n; j++ {
+			tag[j] ^= data[i+j]
+		}
+		t.mulInto(tag)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestGFMulVariantsAgree(t *testing.T) {
+	x := make([]byte, 16)
+	h := make([]byte, 16)
+	for i := 0; i < 500; i++ {
+		rand.Read(x)
+		rand.Read(h)
+		want := gfMul(x, h)
+		if got := newGHASHTable(h).mul(x); !bytes.Equal(got, want) {
+			t.Fatalf("ghashTable(%x).mul(%x) = %x, want %x", h, x, got, want)
+		}
+	}
+}
+
+func BenchmarkGFMulVariants(b *testing.B) {
+	x := make([]byte, 16)
+	h := make([]byte, 16)
+	rand.Read(x)
+	rand.Read(h)
+	table := newGHASHTable(h)
+
+	b.Run("bitwise", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x = gfMul(x, h)
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x = table.mul(x)
+		}
+	})
+}
+
+func TestGHASHTableMatchesBitwise(t *testing.T) {
+	c, err := NewCipher([]byte("1234567890123456"))
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	for _, n := range []int{0, 1, 15, 16, 17, 100, 4096 + 3} {
+		aad := make([]byte, n/3)
+		ct := make([]byte, n)
+		rand.Read(aad)
+		rand.Read(ct)
+		lenBlock := gcmLengthBlock(len(aad), len(ct))
+		want := ghashWithLengths(c.ghashKey(), aad, ct, lenBlock)
+		var got [16]byte
+		c.ghashTable().ghashInto(&got, aad, ct, lenBlock)
+		if !bytes.Equal(got[:], want) {
+			t.Errorf("%d bytes: table GHASH = %x, bitwise = %x", n, got, want)
+		}
+	}
+}
+
+func BenchmarkGHASH1MB(b *testing.B) {
+	c, err := NewCipher([]byte("1234567890123456"))
+	if err != nil {
+		b.Fatalf("NewCipher failed: %v", err)
+	}
+	data := make([]byte, 1<<20)
+	lenBlock := gcmLengthBlock(0, len(data))
+	var tag [16]byte
+
+	b.Run("bitwise", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			ghashInto(&tag, c.ghashKey(), nil, data, lenBlock)
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			c.ghashTable().ghashInto(&tag, nil, data, lenBlock)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// gmacState accumulates GHASH over data fed to it in arbitrary pieces. The
+// data is treated as AAD with an empty ciphertext, so the resulting tag is
+// the same as GCMEncrypt(nil, key, nonce, data).
+type gmacState struct {
+	key  []byte
+	j0   []byte
+	h    []byte
+	y    []byte
+	buf  [16]byte
+	nbuf int
+	n    uint64
+}
+
+func newGMACState(key, nonce []byte) (*gmacState, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcmNonceSize {
+		return nil, fmt.Errorf("GMAC requires a %d-byte nonce", gcmNonceSize)
+	}
+	j0 := make([]byte, 16)
+	copy(j0, nonce)
+	j0[15] = 1
+	return &gmacState{
+		key: append([]byte(nil), key...),
+		j0:  j0,
+		h:   EncryptBlock(make([]byte, 16), key),
+		y:   make([]byte, 16),
+	}, nil
+}
+
+func (g *gmacState) write(p []byte) {
+	g.n += uint64(len(p))
+	for len(p) > 0 {
+		k := copy(g.buf[g.nbuf:], p)
+		g.nbuf += k
+		p = p[k:]
+		if g.nbuf == 16 {
+			g.absorb()
+		}
+	}
+}
+
+func (g *gmacState) absorb() {
+	for i := 0; i < 16; i++ {
+		g.y[i] ^= g.buf[i]
+	}
+	g.y = gfMul(g.y, g.h)
+	g.nbuf = 0
+}
+
+// sum finishes GHASH and returns the tag. The state must not be used again.
+func (g *gmacState) sum() ([]byte, error) {
+	if err := checkAADLength(g.n); err != nil {
+		return nil, err
+	}
+	if g.nbuf > 0 {
+		clear(g.buf[g.nbuf:])
+		g.absorb()
+	}
+	// Length block: AAD bits || 0 ciphertext bits
+	clear(g.buf[:])
+	binary.BigEndian.PutUint64(g.buf[:8], g.n*8)
+	g.absorb()
+	encJ0 := EncryptBlock(g.j0, g.key)
+	for i := 0; i < 16; i++ {
+		g.y[i] ^= encJ0[i]
+	}
+	return g.y, nil
+}
+
+// GMACWriter passes data through to the underlying writer unchanged and
+// authenticates it with GMAC. Close appends the 16-byte tag.
+type GMACWriter struct {
+	w      io.Writer
+	state  *gmacState
+	closed bool
+}
+
+// NewGMACWriter returns a GMACWriter for key and a 12-byte nonce. The data is
+// not encrypted; use it only for data that is not secret. A nonce must never
+// be reused with the same key.
+func NewGMACWriter(w io.Writer, key, nonce []byte) (*GMACWriter, error) {
+	state, err := newGMACState(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &GMACWriter{w: w, state: state}, nil
+}
+
+func (g *GMACWriter) Write(p []byte) (int, error) {
+	if g.closed {
+		return 0, fmt.Errorf("write to closed GMAC writer")
+	}
+	n, err := g.w.Write(p)
+	g.state.write(p[:n])
+	return n, err
+}
+
+// Close writes the tag. It does not close the underlying writer.
+func (g *GMACWriter) Close() error {
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	tag, err := g.state.sum()
+	if err != nil {
+		return err
+	}
+	_, err = g.w.Write(tag)
+	return err
+}
+
+// GMACVerifyReader reads data || tag written by a GMACWriter. It returns
+// the data and checks the trailing tag when the input ends: Read returns
+// io.EOF only if the tag matches. Data is handed out before the tag is
+// checked, so don't act on it until Read has returned io.EOF.
+type GMACVerifyReader struct {
+	r     io.Reader
+	state *gmacState
+	held  []byte
+	buf   []byte
+	eof   bool
+	err   error
+}
+
+// NewGMACVerifyReader returns a GMACVerifyReader for key and nonce
+func NewGMACVerifyReader(r io.Reader, key, nonce []byte) (*GMACVerifyReader, error) {
+	state, err := newGMACState(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &GMACVerifyReader{r: r, state: state, buf: make([]byte, 32<<10)}, nil
+}
+
+func (g *GMACVerifyReader) Read(p []byte) (int, error) {
+	// The last 16 bytes seen so far may be the tag, so they are held back
+	for !g.eof && len(g.held) <= gcmTagSize {
+		n, err := g.r.Read(g.buf)
+		g.held = append(g.held, g.buf[:n]...)
+		if err == io.EOF {
+			g.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if len(g.held) > gcmTagSize {
+		n := copy(p, g.held[:len(g.held)-gcmTagSize])
+		g.state.write(p[:n])
+		g.held = g.held[n:]
+		return n, nil
+	}
+	if g.err == nil {
+		g.err = g.finish()
+	}
+	return 0, g.err
+}
+
+// finish checks the tag once only the tag is left
+func (g *GMACVerifyReader) finish() error {
+	if len(g.held) < gcmTagSize {
+		return fmt.Errorf("GMAC stream too short (must end with a %d-byte tag)", gcmTagSize)
+	}
+	expected, err := g.state.sum()
+	if err != nil {
+		return err
+	}
+	if !tagsEqual(g.held, expected) {
+		return fmt.Errorf("%w: tag mismatch", ErrAuthFailed)
+	}
+	return io.EOF
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestGMACWriterMatchesGCM(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	data := bytes.Repeat([]byte("public log line\n"), 100)
+
+	var buf bytes.Buffer
+	w, err := NewGMACWriter(&buf, key, nonce)
+	if err != nil {
+		t.Fatalf("NewGMACWriter failed: %v", err)
+	}
+	// Uneven writes exercise the partial-block buffering
+	for i := 0; i < len(data); i += 7 {
+		if _, err := w.Write(data[i:min(i+7, len(data))]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	out := buf.Bytes()
+	if !bytes.Equal(out[:len(data)], data) {
+		t.Errorf("GMACWriter changed the data")
+	}
+	want, err := GCMEncrypt(nil, key, nonce, data)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(out[len(data):], want) {
+		t.Errorf("Tag = %x, want %x", out[len(data):], want)
+	}
+
+	r, err := NewGMACVerifyReader(bytes.NewReader(out), key, nonce)
+	if err != nil {
+		t.Fatalf("NewGMACVerifyReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Verified data doesn't match")
+	}
+}
+
+func TestGMACVerifyReaderDetectsTampering(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	data := []byte("data that is public but must not change")
+
+	var buf bytes.Buffer
+	w, err := NewGMACWriter(&buf, key, nonce)
+	if err != nil {
+		t.Fatalf("NewGMACWriter failed: %v", err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	tampered := append([]byte(nil), buf.Bytes()...)
+	tampered[3] ^= 1
+	r, err := NewGMACVerifyReader(bytes.NewReader(tampered), key, nonce)
+	if err != nil {
+		t.Fatalf("NewGMACVerifyReader failed: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("Expected verification failure for flipped data byte")
+	}
+
+	r, _ = NewGMACVerifyReader(bytes.NewReader(buf.Bytes()[:10]), key, nonce)
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("Expected error for stream shorter than a tag")
+	}
+}
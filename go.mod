@@ -1,3 +1,9 @@
 module github.com/SaadSaid158/aes
 
 go 1.24.7
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.31.0
+	golang.org/x/sys v0.28.0
+)
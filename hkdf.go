@@ -0,0 +1,19 @@
+package main
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+)
+
+// HKDF derives length bytes from a high-entropy secret with HKDF-SHA256
+// (RFC 5869 extract-and-expand). Different info strings give independent
+// subkeys, e.g. HKDF(master, nil, []byte("enc"), 16) and
+// HKDF(master, nil, []byte("mac"), 32). It panics if length is not
+// between 1 and 255*32.
+func HKDF(secret, salt, info []byte, length int) []byte {
+	key, err := hkdf.Key(sha256.New, secret, salt, string(info), length)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHKDFVector(t *testing.T) {
+	// RFC 5869 test case 1
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+	salt := mustHex(t, "000102030405060708090a0b0c")
+	info := mustHex(t, "f0f1f2f3f4f5f6f7f8f9")
+	want := mustHex(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+	if got := HKDF(ikm, salt, info, 42); !bytes.Equal(got, want) {
+		t.Errorf("HKDF: got %x, want %x", got, want)
+	}
+}
+
+func TestHKDFSubkeys(t *testing.T) {
+	master := []byte("0123456789abcdef0123456789abcdef")
+	enc := HKDF(master, nil, []byte("enc"), 16)
+	mac := HKDF(master, nil, []byte("mac"), 32)
+	if len(enc) != 16 || len(mac) != 32 {
+		t.Fatalf("Subkey lengths %d and %d, want 16 and 32", len(enc), len(mac))
+	}
+	if bytes.Equal(enc, mac[:16]) {
+		t.Error("Different info strings gave related subkeys")
+	}
+	if !bytes.Equal(enc, HKDF(master, nil, []byte("enc"), 16)) {
+		t.Error("HKDF is not deterministic")
+	}
+}
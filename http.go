@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+type decryptedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *decryptedBody) Close() error {
+	return b.body.Close()
+}
+
+// DecryptRequestBody returns the plaintext of a request whose body is a
+// chunked GCM stream (see NewGCMStreamWriter). The body is decrypted as the
+// handler reads it, so large uploads are never held in memory; each frame
+// is verified before any of its plaintext is returned, and Read fails if
+// the body was tampered with or cut short. Closing the result closes the
+// request body.
+func DecryptRequestBody(r *http.Request, key []byte) (io.ReadCloser, error) {
+	sr, err := NewGCMStreamReader(r.Body, key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptedBody{Reader: sr, body: r.Body}, nil
+}
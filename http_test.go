@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecryptRequestBody(t *testing.T) {
+	key := []byte("1234567890123456")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := DecryptRequestBody(r, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+		pt, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write(pt)
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	plaintext := bytes.Repeat([]byte("streamed upload "), 5000)
+	var buf bytes.Buffer
+	sw, err := NewGCMStreamWriter(&buf, key, streamMinChunk)
+	if err != nil {
+		t.Fatalf("NewGCMStreamWriter failed: %v", err)
+	}
+	sw.Write(plaintext)
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	encrypted := buf.Bytes()
+
+	post := func(body []byte) (int, []byte) {
+		t.Helper()
+		resp, err := http.Post(srv.URL, "application/octet-stream", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading response failed: %v", err)
+		}
+		return resp.StatusCode, data
+	}
+
+	status, got := post(encrypted)
+	if status != http.StatusOK {
+		t.Fatalf("Handler returned %d: %s", status, got)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Handler read back different plaintext")
+	}
+
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)-1] ^= 1
+	if status, _ := post(tampered); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for tampered body, got %d", status)
+	}
+	if status, _ := post(encrypted[:len(encrypted)/2]); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for truncated body, got %d", status)
+	}
+}
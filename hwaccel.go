@@ -0,0 +1,12 @@
+package main
+
+import "golang.org/x/sys/cpu"
+
+// HardwareAccelerated reports whether the CPU has AES instructions (AES-NI
+// on x86, the ARMv8 Cryptography Extensions on arm64). This package is
+// pure Go and never uses them, so it is always slower than crypto/aes,
+// which does. Callers that need throughput more than an auditable
+// implementation can use this to decide to switch to crypto/aes.
+func HardwareAccelerated() bool {
+	return cpu.X86.HasAES || cpu.ARM64.HasAES
+}
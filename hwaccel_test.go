@@ -0,0 +1,8 @@
+package main
+
+import "testing"
+
+func TestHardwareAccelerated(t *testing.T) {
+	// The answer depends on the machine; only check that it can be asked
+	t.Logf("HardwareAccelerated() = %v", HardwareAccelerated())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkInPlace points -out at -in under -inplace. The two flags are
+// exclusive, and stdin can't be replaced.
+func checkInPlace(inplace bool, in string, out *string) error {
+	if !inplace {
+		return nil
+	}
+	if *out != "" {
+		return &usageError{fmt.Errorf("-inplace can't be combined with -out")}
+	}
+	if in == "" || in == "-" {
+		return &usageError{fmt.Errorf("-inplace needs -in to name a file")}
+	}
+	*out = in
+	return nil
+}
+
+// replaceFile atomically replaces the regular file at path with data. The
+// data goes to a temporary file in the same directory, which takes on the
+// original's permissions and is then renamed over it, so a crash leaves
+// either the old file or the new one but never a partial write.
+func replaceFile(path string, data []byte) error {
+	st, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if !st.Mode().IsRegular() {
+		return fmt.Errorf("write %s: -inplace needs a regular file", path)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, st.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInPlaceRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	plaintext := []byte("encrypt me where I stand")
+	if err := os.WriteFile(path, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Set the mode explicitly so the umask doesn't matter
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+	pairs := []struct {
+		name             string
+		encrypt, decrypt func([]string) error
+	}{
+		{"cbc", cmdEncrypt, cmdDecrypt},
+		{"ctr", cmdEncryptCTR, cmdDecryptCTR},
+		{"gcm", cmdEncryptGCM, cmdDecryptGCM},
+		{"auto", cmdEncryptGCM, cmdDecryptAuto},
+	}
+	for _, p := range pairs {
+		encArgs := []string{"-in", path, "-inplace"}
+		if p.name == "auto" {
+			encArgs = append(encArgs, "-framed")
+		}
+		if err := p.encrypt(append(encArgs, key...)); err != nil {
+			t.Fatalf("%s: encrypt -inplace failed: %v", p.name, err)
+		}
+		enc, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(enc, plaintext) {
+			t.Errorf("%s: file still holds the plaintext", p.name)
+		}
+		if err := p.decrypt(append([]string{"-in", path, "-inplace"}, key...)); err != nil {
+			t.Fatalf("%s: decrypt -inplace failed: %v", p.name, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: decrypted in place to %q", p.name, got)
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != 0o640 {
+			t.Errorf("%s: mode %v after -inplace, want 0640", p.name, st.Mode().Perm())
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Temporary files left behind: %v", entries)
+	}
+}
+
+func TestInPlaceRejectsBadUse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+
+	var usage *usageError
+	if err := cmdEncryptGCM(append([]string{"-in", path, "-out", path + ".gcm", "-inplace"}, key...)); !errors.As(err, &usage) {
+		t.Errorf("Expected usage error for -inplace with -out, got %v", err)
+	}
+	if err := cmdEncryptGCM(append([]string{"-in", "-", "-inplace"}, key...)); !errors.As(err, &usage) {
+		t.Errorf("Expected usage error for -inplace on stdin, got %v", err)
+	}
+	if err := cmdEncryptGCM(append([]string{"-in", link, "-inplace"}, key...)); err == nil {
+		t.Error("Expected error replacing a symlink")
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Symlink was replaced: %v", err)
+	}
+
+	// A failed decrypt leaves the file alone
+	if err := cmdDecryptGCM(append([]string{"-in", path, "-inplace"}, key...)); err == nil {
+		t.Error("Expected error decrypting a plaintext file")
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "data" {
+		t.Errorf("File after failed decrypt = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// ErrJWETag is returned when a JWE authentication tag doesn't verify
+var ErrJWETag = fmt.Errorf("%w: JWE tag mismatch", ErrAuthFailed)
+
+// jweAlg describes one AES_CBC_HMAC_SHA2 algorithm from RFC 7518 section
+// 5.2. The key is MAC_KEY || ENC_KEY, each half the key length, and the
+// tag is the first tagLen bytes of the HMAC.
+type jweAlg struct {
+	keyLen int
+	hash   func() hash.Hash
+	tagLen int
+}
+
+var jweAlgs = map[string]jweAlg{
+	"A128CBC-HS256": {32, sha256.New, 16},
+	"A192CBC-HS384": {48, sha512.New384, 24},
+	"A256CBC-HS512": {64, sha512.New, 32},
+}
+
+func lookupJWEAlg(alg string, key, iv []byte) (jweAlg, error) {
+	a, ok := jweAlgs[alg]
+	if !ok {
+		return jweAlg{}, fmt.Errorf("unsupported JWE algorithm %q", alg)
+	}
+	if len(key) != a.keyLen {
+		return jweAlg{}, fmt.Errorf("%s requires a %d-byte key", alg, a.keyLen)
+	}
+	if len(iv) != 16 {
+		return jweAlg{}, fmt.Errorf("%s requires a 16-byte IV", alg)
+	}
+	return a, nil
+}
+
+// tag computes HMAC(MAC_KEY, A || IV || E || AL), truncated, where AL is
+// the bit length of A as a big-endian 64-bit integer
+func (a jweAlg) tag(macKey, aad, iv, ciphertext []byte) []byte {
+	mac := hmac.New(a.hash, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(aad))*8))
+	return mac.Sum(nil)[:a.tagLen]
+}
+
+// JWEEncrypt encrypts plaintext with one of the RFC 7518 composite
+// algorithms A128CBC-HS256, A192CBC-HS384 or A256CBC-HS512: AES-CBC with
+// PKCS#7 padding, then an HMAC over the AAD, IV and ciphertext
+func JWEEncrypt(plaintext, key, iv, aad []byte, alg string) (ciphertext, tag []byte, err error) {
+	a, err := lookupJWEAlg(alg, key, iv)
+	if err != nil {
+		return nil, nil, err
+	}
+	macKey, encKey := key[:a.keyLen/2], key[a.keyLen/2:]
+	ciphertext, err = CBCEncrypt(plaintext, encKey, iv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ciphertext, a.tag(macKey, aad, iv, ciphertext), nil
+}
+
+// JWEDecrypt checks the tag and decrypts the output of JWEEncrypt. The
+// ciphertext isn't decrypted unless the tag verifies.
+func JWEDecrypt(ciphertext, tag, key, iv, aad []byte, alg string) ([]byte, error) {
+	a, err := lookupJWEAlg(alg, key, iv)
+	if err != nil {
+		return nil, err
+	}
+	macKey, encKey := key[:a.keyLen/2], key[a.keyLen/2:]
+	if !hmac.Equal(tag, a.tag(macKey, aad, iv, ciphertext)) {
+		return nil, ErrJWETag
+	}
+	return CBCDecrypt(ciphertext, encKey, iv)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// RFC 7518 appendix B test cases
+func TestJWEVectors(t *testing.T) {
+	plaintext := []byte("A cipher system must not be required to be secret, and it must be able to fall into the hands of the enemy without inconvenience")
+	iv := mustHex(t, "1af38c2dc2b96ffdd86694092341bc04")
+	aad := []byte("The second principle of Auguste Kerckhoffs")
+
+	for _, tc := range []struct {
+		alg    string
+		keyLen int
+		ct     string
+		tag    string
+	}{
+		{"A128CBC-HS256", 32,
+			"c80edfa32ddf39d5ef00c0b468834279a2e46a1b8049f792f76bfe54b903a9c9a94ac9b47ad2655c5f10f9aef71427e2" +
+				"fc6f9b3f399a221489f16362c703233609d45ac69864e3321cf82935ac4096c86e133314c54019e8ca7980dfa4b9cf1b" +
+				"384c486f3a54c51078158ee5d79de59fbd34d848b3d69550a67646344427ade54b8851ffb598f7f80074b9473c82e2db",
+			"652c3fa36b0a7c5b3219fab3a30bc1c4"},
+		{"A192CBC-HS384", 48,
+			"ea65da6b59e61edb419be62d19712ae5d303eeb50052d0dfd6697f77224c8edb000d279bdc14c1072654bd30944230c6" +
+				"57bed4ca0c9f4a8466f22b226d1746214bf8cfc2400add9f5126e479663fc90b3bed787a2f0ffcbf3904be2a641d5c21" +
+				"05bfe591bae23b1d7449e532eef60a9ac8bb6c6b01d35d49787bcd57ef484927f280adc91ac0c4e79c7b11efc60054e3",
+			"8490ac0e58949bfe51875d733f93ac2075168039ccc733d7"},
+		{"A256CBC-HS512", 64,
+			"4affaaadb78c31c5da4b1b590d10ffbd3dd8d5d302423526912da037ecbcc7bd822c301dd67c373bccb584ad3e9279c2" +
+				"e6d12a1374b77f077553df829410446b36ebd97066296ae6427ea75c2e0846a11a09ccf5370dc80bfecbad28c73f09b3" +
+				"a3b75e662a2594410ae496b2e2e6609e31e6e02cc837f053d21f37ff4f51950bbe2638d09dd7a4930930806d0703b1f6",
+			"4dd3b4c088a7f45c216839645b2012bf2e6269a8c56a816dbc1b267761955bc5"},
+	} {
+		key := make([]byte, tc.keyLen)
+		for i := range key {
+			key[i] = byte(i)
+		}
+		ct, tag, err := JWEEncrypt(plaintext, key, iv, aad, tc.alg)
+		if err != nil {
+			t.Fatalf("%s: JWEEncrypt failed: %v", tc.alg, err)
+		}
+		if want := mustHex(t, tc.ct); !bytes.Equal(ct, want) {
+			t.Errorf("%s: ciphertext = %x, want %x", tc.alg, ct, want)
+		}
+		if want := mustHex(t, tc.tag); !bytes.Equal(tag, want) {
+			t.Errorf("%s: tag = %x, want %x", tc.alg, tag, want)
+		}
+
+		pt, err := JWEDecrypt(ct, tag, key, iv, aad, tc.alg)
+		if err != nil {
+			t.Fatalf("%s: JWEDecrypt failed: %v", tc.alg, err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Errorf("%s: decrypted text doesn't match", tc.alg)
+		}
+		if _, err := JWEDecrypt(ct, tag, key, iv, []byte("other aad"), tc.alg); !errors.Is(err, ErrJWETag) {
+			t.Errorf("%s: expected ErrJWETag for wrong AAD, got %v", tc.alg, err)
+		}
+	}
+}
+
+func TestJWERejectsBadParameters(t *testing.T) {
+	iv := make([]byte, 16)
+	if _, _, err := JWEEncrypt(nil, make([]byte, 16), iv, nil, "A128CBC-HS256"); err == nil {
+		t.Error("Expected error for 16-byte key with A128CBC-HS256")
+	}
+	if _, _, err := JWEEncrypt(nil, make([]byte, 32), iv, nil, "A128GCM"); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// decodeHexKey decodes a 16-, 24- or 32-byte hex key. Surrounding whitespace,
+// such as the newline left by echo or a text editor, is ignored.
+func decodeHexKey(h string) ([]byte, error) {
+	h = strings.TrimSpace(h)
+	b, err := hex.DecodeString(h)
+	var invalid hex.InvalidByteError
+	switch {
+	case errors.As(err, &invalid):
+		return nil, fmt.Errorf("bad hex key: non-hex character %q", byte(invalid))
+	case errors.Is(err, hex.ErrLength):
+		return nil, fmt.Errorf("bad hex key: odd number of hex digits (%d) after trimming whitespace", len(h))
+	case err != nil:
+		return nil, fmt.Errorf("bad hex key: %v", err)
+	}
+	if checkKeySize(b) != nil {
+		return nil, fmt.Errorf("hex key must decode to 16, 24 or 32 bytes")
+	}
+	return b, nil
+}
+
+// decodeKeyMaterial reads a key given as hex or as raw bytes, for
+// -keyfile and AES_KEY. A "hex:" or "raw:" prefix chooses explicitly.
+// Without one, data that is both a valid hex key and a valid raw key (32
+// hex digits, which are also 32 raw bytes) is rejected rather than guessed
+// at, since the two readings give different AES variants. A raw key may
+// end in one newline, which is dropped unless the key is already a valid
+// length.
+func decodeKeyMaterial(data []byte) ([]byte, error) {
+	if h, ok := bytes.CutPrefix(data, []byte("hex:")); ok {
+		return decodeHexKey(string(h))
+	}
+	if r, ok := bytes.CutPrefix(data, []byte("raw:")); ok {
+		return decodeRawKey(r)
+	}
+	hexKey, hexErr := decodeHexKey(string(data))
+	rawKey, rawErr := decodeRawKey(data)
+	switch {
+	case hexErr == nil && rawErr == nil:
+		Zeroize(hexKey)
+		Zeroize(rawKey)
+		return nil, fmt.Errorf("key is ambiguous: valid as %d hex digits or %d raw bytes; prefix it with hex: or raw:", 2*len(hexKey), len(rawKey))
+	case hexErr == nil:
+		return hexKey, nil
+	case rawErr == nil:
+		return rawKey, nil
+	}
+	return nil, fmt.Errorf("key must be 16, 24 or 32 raw bytes or 32, 48 or 64 hex digits")
+}
+
+// decodeRawKey returns a copy of a 16-, 24- or 32-byte raw key, less one
+// trailing newline if that makes it a valid length
+func decodeRawKey(data []byte) ([]byte, error) {
+	raw := data
+	if checkKeySize(raw) != nil {
+		raw = bytes.TrimSuffix(raw, []byte("\n"))
+		raw = bytes.TrimSuffix(raw, []byte("\r"))
+	}
+	if err := checkKeySize(raw); err != nil {
+		return nil, fmt.Errorf("raw key must be 16, 24 or 32 bytes, got %d", len(raw))
+	}
+	return bytes.Clone(raw), nil
+}
+
+// LoadKeyFile reads an AES key from path, in hex or raw as described for
+// decodeKeyMaterial. It backs -keyfile.
+func LoadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer Zeroize(data)
+	key, err := decodeKeyMaterial(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
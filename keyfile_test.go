@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHexKeyWhitespace(t *testing.T) {
+	want := []byte("1234567890123456")
+	padded := "  31323334353637383930313233343536 \n"
+
+	key, err := decodeKey("", padded)
+	if err != nil {
+		t.Fatalf("decodeKey failed: %v", err)
+	}
+	if !bytes.Equal(key, want) {
+		t.Errorf("Key = %x, want %x", key, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.hex")
+	if err := os.WriteFile(path, []byte(padded), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err = LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadKeyFile failed: %v", err)
+	}
+	if !bytes.Equal(key, want) {
+		t.Errorf("Key = %x, want %x", key, want)
+	}
+}
+
+func TestHexKeyErrors(t *testing.T) {
+	tests := []struct {
+		hex, want string
+	}{
+		{"3132333435363738393031323334353\n", "odd number"},
+		{"3132333435363738393031323334353g", "non-hex character 'g'"},
+		{"31 32333435363738393031323334353", "non-hex character ' '"},
+	}
+	for _, tc := range tests {
+		_, err := decodeKey("", tc.hex)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("decodeKey(%q) error = %v, want it to mention %q", tc.hex, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KeyRing holds keys by epoch ID for streams whose key rotates. Writers
+// encrypt each frame with the current epoch; readers look up the epoch
+// recorded in each frame.
+type KeyRing struct {
+	mu         sync.RWMutex
+	keys       map[uint32][]byte
+	current    uint32
+	hasCurrent bool
+}
+
+// NewKeyRing returns an empty key ring
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[uint32][]byte)}
+}
+
+// Add stores key under epoch. The first key added becomes current.
+func (k *KeyRing) Add(epoch uint32, key []byte) error {
+	if err := checkKeySize(key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[epoch]; ok {
+		return fmt.Errorf("key epoch %d already in ring", epoch)
+	}
+	k.keys[epoch] = append([]byte(nil), key...)
+	if !k.hasCurrent {
+		k.current, k.hasCurrent = epoch, true
+	}
+	return nil
+}
+
+// SetCurrent selects the epoch used for new frames
+func (k *KeyRing) SetCurrent(epoch uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[epoch]; !ok {
+		return fmt.Errorf("unknown key epoch %d", epoch)
+	}
+	k.current, k.hasCurrent = epoch, true
+	return nil
+}
+
+// Current returns the current epoch and its key
+func (k *KeyRing) Current() (uint32, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if !k.hasCurrent {
+		return 0, nil, fmt.Errorf("key ring is empty")
+	}
+	return k.current, k.keys[k.current], nil
+}
+
+// Key returns the key for epoch
+func (k *KeyRing) Key(epoch uint32) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[epoch]
+	if !ok {
+		return nil, fmt.Errorf("unknown key epoch %d", epoch)
+	}
+	return key, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func newTestKeyRing(t *testing.T) *KeyRing {
+	t.Helper()
+	ring := NewKeyRing()
+	if err := ring.Add(1, []byte("1234567890123456")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := ring.Add(2, []byte("6543210987654321")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	return ring
+}
+
+func TestGCMStreamKeyRotation(t *testing.T) {
+	ring := newTestKeyRing(t)
+	var buf bytes.Buffer
+	sw, err := NewGCMStreamWriterKeyRing(&buf, ring, 16)
+	if err != nil {
+		t.Fatalf("NewGCMStreamWriterKeyRing failed: %v", err)
+	}
+	first := bytes.Repeat([]byte("a"), 32)
+	second := bytes.Repeat([]byte("b"), 20)
+	sw.Write(first)
+	// The rotation applies from the next frame written; the writer holds
+	// one full frame back, so rotate after it has been flushed
+	sw.(*gcmStreamWriter).flush()
+	if err := ring.SetCurrent(2); err != nil {
+		t.Fatalf("SetCurrent failed: %v", err)
+	}
+	sw.Write(second)
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// Frames 0 and 1 name epoch 1, frames 2 and 3 epoch 2
+	frameSize := streamFrameHdrSize + streamEpochSize + 16 + 16
+	for i, want := range []uint32{1, 1, 2} {
+		off := streamHeaderSize + i*frameSize + 1
+		if got := binary.BigEndian.Uint32(encoded[off:]); got != want {
+			t.Errorf("Frame %d epoch = %d, want %d", i, got, want)
+		}
+	}
+
+	readRing := newTestKeyRing(t)
+	sr, err := NewGCMStreamReaderKeyRing(bytes.NewReader(encoded), readRing)
+	if err != nil {
+		t.Fatalf("NewGCMStreamReaderKeyRing failed: %v", err)
+	}
+	got, err := io.ReadAll(sr)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, append(first, second...)) {
+		t.Errorf("Decrypted stream doesn't match")
+	}
+
+	// A ring missing epoch 2 can read the first frames but not the rest
+	partial := NewKeyRing()
+	partial.Add(1, []byte("1234567890123456"))
+	sr, _ = NewGCMStreamReaderKeyRing(bytes.NewReader(encoded), partial)
+	if _, err := io.ReadAll(sr); err == nil {
+		t.Error("Expected error for unknown key epoch")
+	}
+	if _, err := NewGCMStreamReader(bytes.NewReader(encoded), []byte("1234567890123456")); err == nil {
+		t.Error("Expected single-key reader to refuse a key ring stream")
+	}
+}
+
+func TestSecureConnKeyRing(t *testing.T) {
+	c1, c2 := net.Pipe()
+	sendRing := newTestKeyRing(t)
+	a, err := NewSecureConnKeyRing(c1, sendRing, true)
+	if err != nil {
+		t.Fatalf("NewSecureConnKeyRing failed: %v", err)
+	}
+	b, err := NewSecureConnKeyRing(c2, newTestKeyRing(t), false)
+	if err != nil {
+		t.Fatalf("NewSecureConnKeyRing failed: %v", err)
+	}
+	go func() {
+		a.Write([]byte("before rotation, "))
+		sendRing.SetCurrent(2)
+		a.Write([]byte("after rotation"))
+		a.Close()
+	}()
+	got, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(got) != "before rotation, after rotation" {
+		t.Errorf("Received %q", got)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// AES Key Wrap (RFC 3394) encrypts key material under a key-encryption key
+// (KEK) without a nonce. The output is 8 bytes longer than the input and
+// starts with the integrity check value.
+//
+// Key Wrap with Padding (RFC 5649) accepts any length from 1 byte by
+// zero-padding to a multiple of 8 and recording the true length in the
+// alternative initial value A65959A6 || uint32 length.
+
+// ErrKeyUnwrap is returned when a wrapped key fails its integrity check
+var ErrKeyUnwrap = fmt.Errorf("%w: key unwrap integrity check failed", ErrAuthFailed)
+
+// keyWrapIV is the RFC 3394 default initial value
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// keyWrapPadAIV is the constant half of the RFC 5649 alternative initial value
+var keyWrapPadAIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// keyWrapPadMax is the largest input the 32-bit length field can describe
+const keyWrapPadMax = 0xffffffff
+
+// keyWrapBlocks runs the RFC 3394 wrapping process (section 2.2.1) in
+// place over r, a whole number of 64-bit blocks, and returns A
+func keyWrapBlocks(c *Cipher, a [8]byte, r []byte) [8]byte {
+	n := len(r) / 8
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(b[:8], a[:])
+			copy(b[8:], r[i*8:])
+			c.Encrypt(b[:], b[:])
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a[:], binary.BigEndian.Uint64(b[:8])^t)
+			copy(r[i*8:], b[8:])
+		}
+	}
+	return a
+}
+
+// keyUnwrapBlocks is the inverse of keyWrapBlocks (section 2.2.2)
+func keyUnwrapBlocks(c *Cipher, a [8]byte, r []byte) [8]byte {
+	n := len(r) / 8
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a[:])^t)
+			copy(b[8:], r[i*8:])
+			c.Decrypt(b[:], b[:])
+			copy(a[:], b[:8])
+			copy(r[i*8:], b[8:])
+		}
+	}
+	return a
+}
+
+// KeyWrap wraps keyData, a multiple of 8 bytes and at least 16, under kek
+// (16, 24 or 32 bytes). Returns: integrity check value (8 bytes) || wrapped key
+func KeyWrap(kek, keyData []byte) ([]byte, error) {
+	if len(keyData) < 16 || len(keyData)%8 != 0 {
+		return nil, fmt.Errorf("key data must be a multiple of 8 bytes and at least 16 bytes, got %d", len(keyData))
+	}
+	c, err := NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 8+len(keyData))
+	copy(out[8:], keyData)
+	a := keyWrapBlocks(c, [8]byte(keyWrapIV), out[8:])
+	copy(out, a[:])
+	return out, nil
+}
+
+// KeyUnwrap unwraps the output of KeyWrap and checks its integrity check
+// value in constant time
+func KeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("wrapped key must be a multiple of 8 bytes and at least 24 bytes, got %d", len(wrapped))
+	}
+	c, err := NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), wrapped[8:]...)
+	a := keyUnwrapBlocks(c, [8]byte(wrapped[:8]), out)
+	if subtle.ConstantTimeCompare(a[:], keyWrapIV) != 1 {
+		clear(out)
+		return nil, ErrKeyUnwrap
+	}
+	return out, nil
+}
+
+// KeyWrapPad wraps keyData of any length from 1 byte under kek using
+// RFC 5649. Returns: 8 bytes longer than keyData rounded up to a multiple of 8
+func KeyWrapPad(kek, keyData []byte) ([]byte, error) {
+	if len(keyData) == 0 || uint64(len(keyData)) > keyWrapPadMax {
+		return nil, fmt.Errorf("key data must be between 1 and %d bytes, got %d", uint64(keyWrapPadMax), len(keyData))
+	}
+	c, err := NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	var a [8]byte
+	copy(a[:], keyWrapPadAIV)
+	binary.BigEndian.PutUint32(a[4:], uint32(len(keyData)))
+	padded := (len(keyData) + 7) / 8 * 8
+	out := make([]byte, 8+padded)
+	copy(out[8:], keyData)
+	if padded == 8 {
+		// A single block is encrypted directly (section 4.1)
+		copy(out, a[:])
+		c.Encrypt(out, out)
+		return out, nil
+	}
+	a = keyWrapBlocks(c, a, out[8:])
+	copy(out, a[:])
+	return out, nil
+}
+
+// KeyUnwrapPad unwraps the output of KeyWrapPad. The constant, the length
+// and the zero padding are checked together so a failure doesn't reveal
+// which one was wrong.
+func KeyUnwrapPad(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("wrapped key must be a multiple of 8 bytes and at least 16 bytes, got %d", len(wrapped))
+	}
+	c, err := NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	var a [8]byte
+	var out []byte
+	if len(wrapped) == 16 {
+		var b [16]byte
+		c.Decrypt(b[:], wrapped)
+		copy(a[:], b[:8])
+		out = append([]byte(nil), b[8:]...)
+		clear(b[:])
+	} else {
+		out = append([]byte(nil), wrapped[8:]...)
+		a = keyUnwrapBlocks(c, [8]byte(wrapped[:8]), out)
+	}
+
+	ok := subtle.ConstantTimeCompare(a[:4], keyWrapPadAIV)
+	// len(out)-8 < mli <= len(out). ConstantTimeLessOrEq needs 31-bit
+	// inputs, so the top bit is checked separately.
+	ok &= subtle.ConstantTimeByteEq(a[4]&0x80, 0)
+	mli := int(binary.BigEndian.Uint32(a[4:]) & 0x7fffffff)
+	ok &= subtle.ConstantTimeLessOrEq(len(out)-7, mli) & subtle.ConstantTimeLessOrEq(mli, len(out))
+	var pad byte
+	for i := len(out) - 7; i < len(out); i++ {
+		// Only bytes at or past mli must be zero
+		inPad := subtle.ConstantTimeLessOrEq(mli, i)
+		pad |= out[i] & byte(-inPad)
+	}
+	ok &= subtle.ConstantTimeByteEq(pad, 0)
+	if ok != 1 {
+		clear(out)
+		return nil, ErrKeyUnwrap
+	}
+	return out[:mli], nil
+}
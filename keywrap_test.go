@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestKeyWrapVectors(t *testing.T) {
+	// RFC 3394 section 4
+	kek128 := mustHex(t, "000102030405060708090a0b0c0d0e0f")
+	kek192 := mustHex(t, "000102030405060708090a0b0c0d0e0f1011121314151617")
+	kek256 := mustHex(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	data128 := mustHex(t, "00112233445566778899aabbccddeeff")
+	data192 := mustHex(t, "00112233445566778899aabbccddeeff0001020304050607")
+	data256 := mustHex(t, "00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		name      string
+		kek, data []byte
+		want      string
+	}{
+		{"4.1", kek128, data128, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5"},
+		{"4.2", kek192, data128, "96778b25ae6ca435f92b5b97c050aed2468ab8a17ad84e5d"},
+		{"4.3", kek256, data128, "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7"},
+		{"4.4", kek192, data192, "031d33264e15d33268f24ec260743edce1c6c7ddee725a936ba814915c6762d2"},
+		{"4.5", kek256, data192, "a8f9bc1612c68b3ff6e6f4fbe30e71e4769c8b80a32cb8958cd5d17d6b254da1"},
+		{"4.6", kek256, data256, "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21"},
+	}
+	for _, tc := range tests {
+		wrapped, err := KeyWrap(tc.kek, tc.data)
+		if err != nil {
+			t.Fatalf("%s: KeyWrap failed: %v", tc.name, err)
+		}
+		if want := mustHex(t, tc.want); !bytes.Equal(wrapped, want) {
+			t.Errorf("%s: got %x, want %x", tc.name, wrapped, want)
+		}
+		got, err := KeyUnwrap(tc.kek, wrapped)
+		if err != nil {
+			t.Fatalf("%s: KeyUnwrap failed: %v", tc.name, err)
+		}
+		if !bytes.Equal(got, tc.data) {
+			t.Errorf("%s: unwrapped key doesn't match", tc.name)
+		}
+	}
+}
+
+func TestKeyUnwrapTampered(t *testing.T) {
+	kek := []byte("1234567890123456")
+	wrapped, err := KeyWrap(kek, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("KeyWrap failed: %v", err)
+	}
+	for i := range wrapped {
+		bad := append([]byte(nil), wrapped...)
+		bad[i] ^= 1
+		if _, err := KeyUnwrap(kek, bad); !errors.Is(err, ErrKeyUnwrap) {
+			t.Fatalf("Byte %d: expected ErrKeyUnwrap, got %v", i, err)
+		}
+	}
+	if _, err := KeyUnwrap([]byte("6543210987654321"), wrapped); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for wrong KEK, got %v", err)
+	}
+}
+
+func TestKeyWrapBadLengths(t *testing.T) {
+	kek := []byte("1234567890123456")
+	for _, n := range []int{0, 8, 15, 20} {
+		if _, err := KeyWrap(kek, make([]byte, n)); err == nil {
+			t.Errorf("Expected error for %d-byte key data", n)
+		}
+	}
+	for _, n := range []int{16, 23, 25} {
+		if _, err := KeyUnwrap(kek, make([]byte, n)); err == nil {
+			t.Errorf("Expected error for %d-byte wrapped key", n)
+		}
+	}
+}
+
+func TestKeyWrapPadVectors(t *testing.T) {
+	// RFC 5649 section 6
+	kek := mustHex(t, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	tests := []struct {
+		data, want string
+	}{
+		{"c37b7e6492584340bed12207808941155068f738", "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{"466f7250617369", "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	}
+	for _, tc := range tests {
+		data := mustHex(t, tc.data)
+		wrapped, err := KeyWrapPad(kek, data)
+		if err != nil {
+			t.Fatalf("KeyWrapPad failed: %v", err)
+		}
+		if want := mustHex(t, tc.want); !bytes.Equal(wrapped, want) {
+			t.Errorf("%d-byte key: got %x, want %x", len(data), wrapped, want)
+		}
+		got, err := KeyUnwrapPad(kek, wrapped)
+		if err != nil {
+			t.Fatalf("KeyUnwrapPad failed: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d-byte key: unwrapped key doesn't match", len(data))
+		}
+	}
+}
+
+func TestKeyWrapPadRoundTrip(t *testing.T) {
+	kek := []byte("1234567890123456")
+	hmacKey := []byte("20-byte HMAC key....")
+	wrapped, err := KeyWrapPad(kek, hmacKey)
+	if err != nil {
+		t.Fatalf("KeyWrapPad failed: %v", err)
+	}
+	if len(wrapped) != 32 {
+		t.Errorf("Wrapped length %d, want 32", len(wrapped))
+	}
+	got, err := KeyUnwrapPad(kek, wrapped)
+	if err != nil {
+		t.Fatalf("KeyUnwrapPad failed: %v", err)
+	}
+	if !bytes.Equal(got, hmacKey) {
+		t.Errorf("Unwrapped key doesn't match")
+	}
+
+	for n := 1; n <= 33; n++ {
+		data := bytes.Repeat([]byte{byte(n)}, n)
+		wrapped, err := KeyWrapPad(kek, data)
+		if err != nil {
+			t.Fatalf("KeyWrapPad(%d bytes) failed: %v", n, err)
+		}
+		got, err := KeyUnwrapPad(kek, wrapped)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("KeyUnwrapPad(%d bytes) failed: %v", n, err)
+		}
+		bad := append([]byte(nil), wrapped...)
+		bad[len(bad)-1] ^= 1
+		if _, err := KeyUnwrapPad(kek, bad); !errors.Is(err, ErrKeyUnwrap) {
+			t.Fatalf("%d bytes: expected ErrKeyUnwrap, got %v", n, err)
+		}
+	}
+
+	if _, err := KeyWrapPad(kek, nil); err == nil {
+		t.Error("Expected error for empty key data")
+	}
+	// An RFC 3394 wrap doesn't carry the padded IV
+	plain, _ := KeyWrap(kek, []byte("0123456789abcdef"))
+	if _, err := KeyUnwrapPad(kek, plain); !errors.Is(err, ErrKeyUnwrap) {
+		t.Errorf("Expected ErrKeyUnwrap for an RFC 3394 wrap, got %v", err)
+	}
+}
+
+func TestKeyUnwrapPadNonZeroPadding(t *testing.T) {
+	// A wrap whose length field claims 19 bytes but whose 20th byte is set
+	c, err := NewCipher([]byte("1234567890123456"))
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	a := [8]byte{0xa6, 0x59, 0x59, 0xa6, 0, 0, 0, 19}
+	r := bytes.Repeat([]byte{1}, 24)
+	a = keyWrapBlocks(c, a, r)
+	wrapped := append(a[:], r...)
+	if _, err := KeyUnwrapPad([]byte("1234567890123456"), wrapped); !errors.Is(err, ErrKeyUnwrap) {
+		t.Errorf("Expected ErrKeyUnwrap for non-zero padding, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// KeyManager is the interface SealWithKMS uses to obtain and unwrap data
+// keys. Implementations typically call out to a cloud KMS or HSM; the master
+// key never leaves it.
+type KeyManager interface {
+	// GenerateDataKey returns a fresh AES key and the same key wrapped
+	// (encrypted) under the master key
+	GenerateDataKey() (plaintext, wrapped []byte, err error)
+	// Decrypt unwraps a key returned by GenerateDataKey
+	Decrypt(wrapped []byte) (plaintext []byte, err error)
+}
+
+// kmsMaxWrappedKey bounds the wrapped key stored in the header
+const kmsMaxWrappedKey = 0xffff
+
+// SealWithKMS encrypts data with AES-GCM under a data key from km.
+// The wrapped key is authenticated as part of the AAD.
+// Returns: wrapped key length (2 bytes) || wrapped key || nonce (12 bytes) ||
+// ciphertext || tag (16 bytes)
+func SealWithKMS(data []byte, km KeyManager) ([]byte, error) {
+	key, wrapped, err := km.GenerateDataKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	defer clear(key)
+	if len(wrapped) == 0 || len(wrapped) > kmsMaxWrappedKey {
+		return nil, fmt.Errorf("wrapped key length %d out of range", len(wrapped))
+	}
+	nonce, err := RandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 2, 2+len(wrapped))
+	binary.BigEndian.PutUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+	ct, err := GCMEncrypt(data, key, nonce, header)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, 0, len(header)+len(nonce)+len(ct))
+	blob = append(blob, header...)
+	blob = append(blob, nonce...)
+	return append(blob, ct...), nil
+}
+
+// OpenWithKMS unwraps the data key stored in blob with km and decrypts it
+func OpenWithKMS(blob []byte, km KeyManager) ([]byte, error) {
+	if len(blob) < 2 {
+		return nil, fmt.Errorf("blob too short")
+	}
+	n := int(binary.BigEndian.Uint16(blob))
+	if n == 0 || len(blob) < 2+n+gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("blob too short (must have wrapped key + nonce + tag)")
+	}
+	header := blob[:2+n]
+	nonce := blob[len(header) : len(header)+gcmNonceSize]
+	key, err := km.Decrypt(header[2:])
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	defer clear(key)
+	return GCMDecrypt(blob[len(header)+gcmNonceSize:], key, nonce, header)
+}
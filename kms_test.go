@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// fakeKMS wraps data keys with AES-GCM under an in-memory master key
+type fakeKMS struct {
+	master []byte
+	calls  int
+}
+
+func (k *fakeKMS) GenerateDataKey() ([]byte, []byte, error) {
+	key, err := randomBytes(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := RandomNonce()
+	if err != nil {
+		return nil, nil, err
+	}
+	ct, err := GCMEncrypt(key, k.master, nonce, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, append(nonce, ct...), nil
+}
+
+func (k *fakeKMS) Decrypt(wrapped []byte) ([]byte, error) {
+	k.calls++
+	if len(wrapped) < 12 {
+		return nil, fmt.Errorf("bad wrapped key")
+	}
+	return GCMDecrypt(wrapped[12:], k.master, wrapped[:12], nil)
+}
+
+func TestSealWithKMS(t *testing.T) {
+	km := &fakeKMS{master: []byte("1234567890123456")}
+	plaintext := []byte("envelope encrypted with a KMS data key")
+
+	blob, err := SealWithKMS(plaintext, km)
+	if err != nil {
+		t.Fatalf("SealWithKMS failed: %v", err)
+	}
+	decrypted, err := OpenWithKMS(blob, km)
+	if err != nil {
+		t.Fatalf("OpenWithKMS failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+	if km.calls != 1 {
+		t.Errorf("KMS Decrypt called %d times, want 1", km.calls)
+	}
+
+	other := &fakeKMS{master: []byte("6543210987654321")}
+	if _, err := OpenWithKMS(blob, other); err == nil {
+		t.Error("Expected error opening with a different master key")
+	}
+
+	tampered := append([]byte(nil), blob...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := OpenWithKMS(tampered, km); err == nil {
+		t.Error("Expected error for tampered blob")
+	}
+	if _, err := OpenWithKMS(blob[:10], km); err == nil {
+		t.Error("Expected error for truncated blob")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Usage limits before a key must be rotated
+const (
+	// NIST SP 800-38D section 8.3: at most 2^32 invocations with random
+	// 96-bit nonces per key
+	gcmRandomNonceLimit = 1 << 32
+
+	// For CBC and CTR stay well under the 2^64-block birthday bound of a
+	// 128-bit block cipher: 2^48 blocks keeps the collision probability
+	// below 2^-32
+	blockModeBlockLimit = 1 << 48
+)
+
+// MaxMessagesForMode returns how many messages of msgSize bytes may be
+// encrypted under one key in the given mode ("gcm", "cbc" or "ctr") before it
+// should be rotated. It returns 0 for unknown modes or sizes the mode can't
+// encrypt at all.
+func MaxMessagesForMode(mode string, msgSize int) uint64 {
+	if msgSize < 0 {
+		return 0
+	}
+	switch strings.ToLower(mode) {
+	case "gcm":
+		if uint64(msgSize) > gcmMaxPlaintextLen {
+			return 0
+		}
+		return gcmRandomNonceLimit
+	case "cbc":
+		// PKCS#7 always adds at least one byte of padding
+		return blockModeBlockLimit / uint64(msgSize/16+1)
+	case "ctr":
+		blocks := uint64((msgSize + 15) / 16)
+		if blocks == 0 {
+			blocks = 1
+		}
+		return blockModeBlockLimit / blocks
+	}
+	return 0
+}
+
+// ErrOutputTooLarge is returned by GCMDecryptLimited when the plaintext
+// would exceed the caller's limit
+var ErrOutputTooLarge = errors.New("decrypted output exceeds limit")
+
+// GCMDecryptLimited decrypts a nonce (12 bytes) || ciphertext || tag blob,
+// refusing with ErrOutputTooLarge if the plaintext would be longer than
+// maxOut bytes. GCM plaintext is exactly as long as the ciphertext, so the
+// check happens before any work or allocation.
+func GCMDecryptLimited(blob, key, aad []byte, maxOut int) ([]byte, error) {
+	if len(blob) < gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("blob too short (must have nonce + tag)")
+	}
+	if n := len(blob) - gcmNonceSize - gcmTagSize; n > maxOut {
+		return nil, ErrOutputTooLarge
+	}
+	return GCMDecrypt(blob[gcmNonceSize:], key, blob[:gcmNonceSize], aad)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestMaxMessagesForMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		msgSize int
+		want    uint64
+	}{
+		{"gcm", 0, 1 << 32},
+		{"gcm", 1024, 1 << 32},
+		{"GCM", 1 << 20, 1 << 32},
+		{"gcm", 1 << 40, 0},
+		{"ctr", 16, 1 << 48},
+		{"ctr", 17, 1 << 47},
+		{"ctr", 0, 1 << 48},
+		{"cbc", 15, 1 << 48},
+		{"cbc", 16, 1 << 47},
+		{"ecb", 16, 0},
+		{"gcm", -1, 0},
+	}
+	for _, tc := range tests {
+		if got := MaxMessagesForMode(tc.mode, tc.msgSize); got != tc.want {
+			t.Errorf("MaxMessagesForMode(%q, %d) = %d, want %d", tc.mode, tc.msgSize, got, tc.want)
+		}
+	}
+}
+
+func TestGCMDecryptLimited(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	ct, err := GCMEncrypt(make([]byte, 100), key, nonce, nil)
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	blob := append(append([]byte(nil), nonce...), ct...)
+
+	if pt, err := GCMDecryptLimited(blob, key, nil, 100); err != nil || len(pt) != 100 {
+		t.Fatalf("GCMDecryptLimited at the limit: %d bytes, %v", len(pt), err)
+	}
+	if _, err := GCMDecryptLimited(blob, key, nil, 99); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("Expected ErrOutputTooLarge, got %v", err)
+	}
+}
+
+func TestGCMDecryptLimitedDoesNotAllocate(t *testing.T) {
+	// A large untrusted blob; it doesn't need to be a valid ciphertext
+	// since the length check comes first
+	blob := make([]byte, 8<<20)
+	key := []byte("1234567890123456")
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := GCMDecryptLimited(blob, key, nil, 1024)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("Expected ErrOutputTooLarge, got %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<10 {
+		t.Errorf("Allocated %d bytes rejecting an oversized blob", allocated)
+	}
+}
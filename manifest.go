@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// SealManifest authenticates metadata without encrypting it (GMAC).
+// The metadata is authenticated as GCM AAD over an empty plaintext.
+// Returns: nonce (12 bytes) || metadata || tag (16 bytes)
+func SealManifest(metadata []byte, key []byte) ([]byte, error) {
+	nonce, err := RandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	tag, err := GCMEncrypt(nil, key, nonce, metadata)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, 0, len(nonce)+len(metadata)+len(tag))
+	blob = append(blob, nonce...)
+	blob = append(blob, metadata...)
+	return append(blob, tag...), nil
+}
+
+// OpenManifest verifies a blob produced by SealManifest and returns the
+// metadata it carries
+func OpenManifest(blob []byte, key []byte) ([]byte, error) {
+	if len(blob) < gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("manifest too short (must have nonce + tag)")
+	}
+	nonce := blob[:gcmNonceSize]
+	metadata := blob[gcmNonceSize : len(blob)-gcmTagSize]
+	tag := blob[len(blob)-gcmTagSize:]
+	if _, err := GCMDecrypt(tag, key, nonce, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	metadata := []byte(`{"files":["a.gcm","b.gcm"],"version":3}`)
+
+	blob, err := SealManifest(metadata, key)
+	if err != nil {
+		t.Fatalf("SealManifest failed: %v", err)
+	}
+	if !bytes.Contains(blob, metadata) {
+		t.Error("Manifest metadata should be stored in clear")
+	}
+
+	got, err := OpenManifest(blob, key)
+	if err != nil {
+		t.Fatalf("OpenManifest failed: %v", err)
+	}
+	if !bytes.Equal(got, metadata) {
+		t.Errorf("Manifest metadata doesn't match")
+	}
+}
+
+func TestManifestTampered(t *testing.T) {
+	key := []byte("1234567890123456")
+	metadata := []byte("version=1")
+
+	blob, err := SealManifest(metadata, key)
+	if err != nil {
+		t.Fatalf("SealManifest failed: %v", err)
+	}
+
+	tampered := append([]byte(nil), blob...)
+	tampered[gcmNonceSize+len(metadata)-1] = '2'
+	if _, err := OpenManifest(tampered, key); err == nil {
+		t.Error("Expected verification failure with modified metadata")
+	}
+
+	if _, err := OpenManifest(blob, []byte("6543210987654321")); err == nil {
+		t.Error("Expected verification failure with wrong key")
+	}
+}
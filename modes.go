@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// xorStream is a keystream-based mode whose state carries across calls, so
+// data can be processed in chunks of any size
+type xorStream interface {
+	XORKeyStream(dst, src []byte)
+}
+
+// cfbStream implements full-block (128-bit segment) CFB mode
+type cfbStream struct {
+	key     []byte
+	next    []byte // feedback register: the previous ciphertext block
+	out     []byte // keystream for the current block
+	outUsed int
+	decrypt bool
+}
+
+func newCFBStream(key, iv []byte, decrypt bool) (*cfbStream, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	if len(iv) != 16 {
+		return nil, fmt.Errorf("CFB mode requires 16-byte IV")
+	}
+	next := make([]byte, 16)
+	copy(next, iv)
+	return &cfbStream{key: append([]byte(nil), key...), next: next, outUsed: 16, decrypt: decrypt}, nil
+}
+
+func (s *cfbStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.outUsed == 16 {
+			s.out = EncryptBlock(s.next, s.key)
+			s.outUsed = 0
+		}
+		c := src[i]
+		dst[i] = src[i] ^ s.out[s.outUsed]
+		if !s.decrypt {
+			c = dst[i]
+		}
+		s.next[s.outUsed] = c
+		s.outUsed++
+	}
+}
+
+// ofbStream implements OFB mode: the keystream is E(K, IV), E(K, E(K, IV)), ...
+type ofbStream struct {
+	key     []byte
+	out     []byte
+	outUsed int
+}
+
+func newOFBStream(key, iv []byte) (*ofbStream, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	if len(iv) != 16 {
+		return nil, fmt.Errorf("OFB mode requires 16-byte IV")
+	}
+	out := make([]byte, 16)
+	copy(out, iv)
+	return &ofbStream{key: append([]byte(nil), key...), out: out, outUsed: 16}, nil
+}
+
+func (s *ofbStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.outUsed == 16 {
+			s.out = EncryptBlock(s.out, s.key)
+			s.outUsed = 0
+		}
+		dst[i] = src[i] ^ s.out[s.outUsed]
+		s.outUsed++
+	}
+}
+
+// CFBEncrypt encrypts data in CFB mode (128-bit segments, no padding)
+func CFBEncrypt(plaintext, key, iv []byte) ([]byte, error) {
+	s, err := newCFBStream(key, iv, false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(plaintext))
+	s.XORKeyStream(out, plaintext)
+	return out, nil
+}
+
+// CFBDecrypt decrypts data produced by CFBEncrypt
+func CFBDecrypt(ciphertext, key, iv []byte) ([]byte, error) {
+	s, err := newCFBStream(key, iv, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(ciphertext))
+	s.XORKeyStream(out, ciphertext)
+	return out, nil
+}
+
+// OFBEncrypt performs OFB mode encryption/decryption (it's symmetric)
+func OFBEncrypt(data, key, iv []byte) ([]byte, error) {
+	s, err := newOFBStream(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	s.XORKeyStream(out, data)
+	return out, nil
+}
+
+// OFBDecrypt is the same operation as OFBEncrypt
+func OFBDecrypt(data, key, iv []byte) ([]byte, error) {
+	return OFBEncrypt(data, key, iv)
+}
+
+// streamWriter XORs everything written through it with a keystream
+type streamWriter struct {
+	w      io.Writer
+	s      xorStream
+	buf    []byte
+	closed bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, fmt.Errorf("write to closed stream")
+	}
+	if cap(sw.buf) < len(p) {
+		sw.buf = make([]byte, len(p))
+	}
+	buf := sw.buf[:len(p)]
+	sw.s.XORKeyStream(buf, p)
+	return sw.w.Write(buf)
+}
+
+// Close ends the stream; the underlying writer is left open
+func (sw *streamWriter) Close() error {
+	sw.closed = true
+	return nil
+}
+
+// streamReader XORs everything read through it with a keystream
+type streamReader struct {
+	r io.Reader
+	s xorStream
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.s.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// NewCFBWriter returns a writer that CFB-encrypts data written to it and
+// writes the ciphertext to w. Close does not close w.
+func NewCFBWriter(w io.Writer, key, iv []byte) (io.WriteCloser, error) {
+	s, err := newCFBStream(key, iv, false)
+	if err != nil {
+		return nil, err
+	}
+	return &streamWriter{w: w, s: s}, nil
+}
+
+// NewCFBReader returns a reader that CFB-decrypts data read from r
+func NewCFBReader(r io.Reader, key, iv []byte) (io.Reader, error) {
+	s, err := newCFBStream(key, iv, true)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{r: r, s: s}, nil
+}
+
+// NewOFBWriter returns a writer that OFB-encrypts data written to it and
+// writes the ciphertext to w. Close does not close w.
+func NewOFBWriter(w io.Writer, key, iv []byte) (io.WriteCloser, error) {
+	s, err := newOFBStream(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &streamWriter{w: w, s: s}, nil
+}
+
+// NewOFBReader returns a reader that OFB-decrypts data read from r
+func NewOFBReader(r io.Reader, key, iv []byte) (io.Reader, error) {
+	s, err := newOFBStream(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{r: r, s: s}, nil
+}
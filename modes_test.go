@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// writeChunked writes data to w in random-sized pieces
+func writeChunked(t *testing.T, w io.Writer, data []byte, rng *rand.Rand) {
+	t.Helper()
+	for len(data) > 0 {
+		n := min(1+rng.Intn(40), len(data))
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		data = data[n:]
+	}
+}
+
+// readChunked reads r to EOF using random-sized buffers
+func readChunked(t *testing.T, r io.Reader, rng *rand.Rand) []byte {
+	t.Helper()
+	var out []byte
+	for {
+		buf := make([]byte, 1+rng.Intn(40))
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+}
+
+func TestCFBOFBRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := []byte("CFB and OFB handle a final partial block without padding")
+
+	ct, err := CFBEncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("CFBEncrypt failed: %v", err)
+	}
+	pt, err := CFBDecrypt(ct, key, iv)
+	if err != nil {
+		t.Fatalf("CFBDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("CFB mode decryption failed")
+	}
+
+	ct, err = OFBEncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("OFBEncrypt failed: %v", err)
+	}
+	pt, err = OFBDecrypt(ct, key, iv)
+	if err != nil {
+		t.Fatalf("OFBDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("OFB mode decryption failed")
+	}
+}
+
+func TestCFBOFBStreamingMatchesOneShot(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := make([]byte, 1000)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 13)
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	modes := []struct {
+		name      string
+		oneShot   func(data, key, iv []byte) ([]byte, error)
+		newWriter func(w io.Writer, key, iv []byte) (io.WriteCloser, error)
+		newReader func(r io.Reader, key, iv []byte) (io.Reader, error)
+	}{
+		{"CFB", CFBEncrypt, NewCFBWriter, NewCFBReader},
+		{"OFB", OFBEncrypt, NewOFBWriter, NewOFBReader},
+	}
+	for _, m := range modes {
+		want, err := m.oneShot(plaintext, key, iv)
+		if err != nil {
+			t.Fatalf("%s one-shot failed: %v", m.name, err)
+		}
+
+		var buf bytes.Buffer
+		w, err := m.newWriter(&buf, key, iv)
+		if err != nil {
+			t.Fatalf("%s writer failed: %v", m.name, err)
+		}
+		writeChunked(t, w, plaintext, rng)
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s streamed ciphertext doesn't match one-shot", m.name)
+		}
+
+		r, err := m.newReader(bytes.NewReader(want), key, iv)
+		if err != nil {
+			t.Fatalf("%s reader failed: %v", m.name, err)
+		}
+		if got := readChunked(t, r, rng); !bytes.Equal(got, plaintext) {
+			t.Errorf("%s streamed decryption doesn't match", m.name)
+		}
+	}
+}
+
+// closeRecorder is a buffer that notes whether Close was called
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCFBOFBWriterCloseLeavesWriterOpen(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	for name, newWriter := range map[string]func(io.Writer, []byte, []byte) (io.WriteCloser, error){
+		"CFB": NewCFBWriter,
+		"OFB": NewOFBWriter,
+	} {
+		var dst closeRecorder
+		w, err := newWriter(&dst, key, iv)
+		if err != nil {
+			t.Fatalf("%s writer failed: %v", name, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s Close failed: %v", name, err)
+		}
+		if dst.closed {
+			t.Errorf("%s Close closed the underlying writer", name)
+		}
+		if _, err := w.Write([]byte("late")); err == nil {
+			t.Errorf("%s: expected error writing after Close", name)
+		}
+	}
+}
+
+func TestCFBErrorPropagationAcrossChunks(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), 6)
+	ct, err := CFBEncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("CFBEncrypt failed: %v", err)
+	}
+
+	// Flip the last bit of block 2 and read it back split across a chunk
+	// boundary right after the flipped byte
+	ct[47] ^= 0x01
+	r, err := NewCFBReader(bytes.NewReader(ct), key, iv)
+	if err != nil {
+		t.Fatalf("NewCFBReader failed: %v", err)
+	}
+	got := make([]byte, len(ct))
+	if _, err := io.ReadFull(r, got[:48]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, got[48:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got[:47], plaintext[:47]) {
+		t.Error("Blocks before the error should be intact")
+	}
+	if got[47] != plaintext[47]^0x01 {
+		t.Error("Flipped ciphertext bit should flip the same plaintext bit")
+	}
+	if bytes.Equal(got[48:64], plaintext[48:64]) {
+		t.Error("Block after the error should be garbled")
+	}
+	if !bytes.Equal(got[64:], plaintext[64:]) {
+		t.Error("CFB should resynchronise one block after the error")
+	}
+}
+
+func TestOFBVector(t *testing.T) {
+	// SP 800-38A F.4.1 OFB-AES128.Encrypt
+	key := mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	iv := mustHex(t, "000102030405060708090a0b0c0d0e0f")
+	pt := mustHex(t, sp80038aPlaintext)
+	want := mustHex(t, "3b3fd92eb72dad20333449f8e83cfb4a7789508d16918f03f53c52dac54ed825"+
+		"9740051e9c5fecf64344f7a82260edcc304c6528f659c77866a510d9c1d6ae5e")
+	ct, err := OFBEncrypt(pt, key, iv)
+	if err != nil {
+		t.Fatalf("OFBEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(ct, want) {
+		t.Errorf("OFBEncrypt = %x, want %x", ct, want)
+	}
+}
+
+func TestOFBEncryptTwiceRecoversPlaintext(t *testing.T) {
+	key := []byte("1234567890123456")
+	iv := []byte("abcdefghijklmnop")
+	plaintext := []byte("sensor reading 42.7, partial final block")
+	ct, err := OFBEncrypt(plaintext, key, iv)
+	if err != nil {
+		t.Fatalf("OFBEncrypt failed: %v", err)
+	}
+	pt, err := OFBEncrypt(ct, key, iv)
+	if err != nil {
+		t.Fatalf("OFBEncrypt failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("Encrypting twice didn't recover the plaintext")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nowFunc is the clock used by TimestampNonce; tests replace it
+var nowFunc = time.Now
+
+// TimestampNonce returns a 12-byte GCM nonce made of the current time in
+// nanoseconds (8 bytes, big-endian) followed by 4 random bytes.
+// This needs no stored state, but the guarantee is weak: two nonces taken in
+// the same nanosecond collide with probability 2^-32, and a clock that jumps
+// backwards can reproduce earlier timestamps. Prefer RandomNonce or a
+// counter when that matters. It panics if the random source fails; use
+// NewTimestampNonce to get the error instead.
+func TimestampNonce() []byte {
+	nonce, err := NewTimestampNonce()
+	if err != nil {
+		panic(err)
+	}
+	return nonce
+}
+
+// NewTimestampNonce is TimestampNonce but returns an error if the random
+// source fails
+func NewTimestampNonce() ([]byte, error) {
+	suffix, err := randomBytes(4)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[:8], uint64(nowFunc().UnixNano()))
+	copy(nonce[8:], suffix)
+	return nonce, nil
+}
+
+// GCMEncryptTimestamped encrypts plaintext with a fresh timestamp nonce
+// Returns: nonce (12 bytes) || ciphertext || tag (16 bytes)
+func GCMEncryptTimestamped(plaintext, key, aad []byte) ([]byte, error) {
+	nonce, err := NewTimestampNonce()
+	if err != nil {
+		return nil, err
+	}
+	ct, err := GCMEncrypt(plaintext, key, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ct...), nil
+}
+
+// GCMDecryptExtractNonce decrypts a nonce (12 bytes) || ciphertext || tag blob,
+// such as one from GCMEncryptTimestamped, and also returns the nonce it
+// parsed so callers can log it
+func GCMDecryptExtractNonce(blob, key, aad []byte) (plaintext, nonce []byte, err error) {
+	if len(blob) < 12+16 {
+		return nil, nil, fmt.Errorf("blob too short (must have nonce + tag)")
+	}
+	nonce = append([]byte(nil), blob[:12]...)
+	plaintext, err = GCMDecrypt(blob[12:], key, nonce, aad)
+	if err != nil {
+		return nil, nonce, err
+	}
+	return plaintext, nonce, nil
+}
+
+// ErrNonceExhausted is returned once a NonceSequence has used every counter
+var ErrNonceExhausted = errors.New("nonce sequence exhausted")
+
+// NonceSequence hands out unique 12-byte GCM nonces of the form
+// prefix (4 bytes) || counter (8 bytes, big-endian). A sequence must only
+// ever be used with a single key.
+type NonceSequence struct {
+	mu      sync.Mutex
+	prefix  [4]byte
+	counter uint64
+	path    string
+}
+
+// NewNonceSequence returns an in-memory sequence with the given 4-byte prefix
+func NewNonceSequence(prefix []byte) (*NonceSequence, error) {
+	if len(prefix) != 4 {
+		return nil, fmt.Errorf("nonce prefix must be 4 bytes")
+	}
+	s := &NonceSequence{}
+	copy(s.prefix[:], prefix)
+	return s, nil
+}
+
+// PersistentNonceSequence returns a sequence whose last used counter is kept
+// in path, so nonces are never repeated across process restarts. The file is
+// created if it doesn't exist, so an unwritable path fails here rather
+// than on the first Next.
+func PersistentNonceSequence(path string) (*NonceSequence, error) {
+	s := &NonceSequence{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if err := writeFileAtomic(path, []byte("0\n")); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.counter, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt nonce counter file %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Next returns the next nonce. For a persistent sequence the new counter is
+// written to disk before the nonce is returned.
+func (s *NonceSequence) Next() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counter == ^uint64(0) {
+		return nil, ErrNonceExhausted
+	}
+	next := s.counter + 1
+	if s.path != "" {
+		if err := writeFileAtomic(s.path, []byte(strconv.FormatUint(next, 10)+"\n")); err != nil {
+			return nil, err
+		}
+	}
+	s.counter = next
+	nonce := make([]byte, 12)
+	copy(nonce, s.prefix[:])
+	binary.BigEndian.PutUint64(nonce[4:], next)
+	return nonce, nil
+}
+
+// ErrNonceReused is returned by NonceTracker.Check for a nonce it has
+// already seen
+var ErrNonceReused = errors.New("nonce reused")
+
+// NonceTracker remembers the most recently used nonces for one key and
+// rejects repeats, as an opt-in guard for callers that choose their own GCM
+// nonces. It only holds the last capacity nonces, so it catches mistakes
+// like a stuck counter or a hard-coded nonce, not a repeat of one used long
+// ago. A NonceTracker is safe for concurrent use.
+type NonceTracker struct {
+	mu       sync.Mutex
+	capacity int
+	seen     map[string]*list.Element
+	order    *list.List // most recently seen at the front
+}
+
+// NewNonceTracker returns a tracker that remembers up to capacity nonces
+func NewNonceTracker(capacity int) (*NonceTracker, error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("nonce tracker capacity must be at least 1")
+	}
+	return &NonceTracker{
+		capacity: capacity,
+		seen:     make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}, nil
+}
+
+// Check records nonce and returns ErrNonceReused if it was already recorded.
+// When the tracker is full the least recently seen nonce is forgotten.
+func (t *NonceTracker) Check(nonce []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := string(nonce)
+	if e, ok := t.seen[k]; ok {
+		t.order.MoveToFront(e)
+		return fmt.Errorf("%w: %x", ErrNonceReused, nonce)
+	}
+	t.seen[k] = t.order.PushFront(k)
+	if t.order.Len() > t.capacity {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.seen, oldest.Value.(string))
+	}
+	return nil
+}
+
+// GCMEncryptTracked is GCMEncrypt that first checks nonce against t, which
+// must only ever be used with this key. A nonce is recorded even if the
+// encryption then fails.
+// Returns: ciphertext || tag (16 bytes)
+func GCMEncryptTracked(t *NonceTracker, plaintext, key, nonce, aad []byte) ([]byte, error) {
+	if err := t.Check(nonce); err != nil {
+		return nil, err
+	}
+	return GCMEncrypt(plaintext, key, nonce, aad)
+}
+
+// writeFileAtomic replaces path with data via a synced temp file and rename,
+// so a crash leaves either the old or the new contents
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
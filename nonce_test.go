@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimestampNonceOrdering(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+
+	base := time.Unix(1700000000, 0)
+	var prev []byte
+	for i := 0; i < 100; i++ {
+		now := base.Add(time.Duration(i))
+		nowFunc = func() time.Time { return now }
+		nonce := TimestampNonce()
+		if len(nonce) != 12 {
+			t.Fatalf("Nonce length %d, want 12", len(nonce))
+		}
+		if prev != nil && bytes.Compare(prev, nonce) >= 0 {
+			t.Fatalf("Nonce %x not greater than previous %x", nonce, prev)
+		}
+		prev = nonce
+	}
+
+	// Within the same nanosecond only the random suffix tells them apart
+	nowFunc = func() time.Time { return base }
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		nonce := TimestampNonce()
+		if !bytes.Equal(nonce[:8], TimestampNonce()[:8]) {
+			t.Fatal("Timestamp prefix should match for a frozen clock")
+		}
+		if seen[string(nonce)] {
+			t.Fatalf("Repeated nonce %x within the same nanosecond", nonce)
+		}
+		seen[string(nonce)] = true
+	}
+}
+
+func TestGCMEncryptTimestamped(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := []byte("timestamped")
+	blob, err := GCMEncryptTimestamped(plaintext, key, nil)
+	if err != nil {
+		t.Fatalf("GCMEncryptTimestamped failed: %v", err)
+	}
+	decrypted, err := GCMDecrypt(blob[12:], key, blob[:12], nil)
+	if err != nil {
+		t.Fatalf("GCMDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestGCMDecryptExtractNonce(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	plaintext := []byte("correlate me")
+	ct, err := GCMEncrypt(plaintext, key, nonce, []byte("aad"))
+	if err != nil {
+		t.Fatalf("GCMEncrypt failed: %v", err)
+	}
+	blob := append(append([]byte(nil), nonce...), ct...)
+
+	decrypted, got, err := GCMDecryptExtractNonce(blob, key, []byte("aad"))
+	if err != nil {
+		t.Fatalf("GCMDecryptExtractNonce failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+	if !bytes.Equal(got, nonce) {
+		t.Errorf("Nonce = %x, want %x", got, nonce)
+	}
+
+	blob[len(blob)-1] ^= 1
+	if _, got, err := GCMDecryptExtractNonce(blob, key, []byte("aad")); err == nil || !bytes.Equal(got, nonce) {
+		t.Errorf("Expected auth failure with nonce still reported, got err=%v nonce=%x", err, got)
+	}
+	if _, _, err := GCMDecryptExtractNonce(blob[:20], key, nil); err == nil {
+		t.Error("Expected error for short blob")
+	}
+}
+
+func TestNonceSequence(t *testing.T) {
+	seq, err := NewNonceSequence([]byte{0xde, 0xad, 0xbe, 0xef})
+	if err != nil {
+		t.Fatalf("NewNonceSequence failed: %v", err)
+	}
+	first, err := seq.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	second, _ := seq.Next()
+	if len(first) != 12 || !bytes.Equal(first[:4], []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("Unexpected nonce layout %x", first)
+	}
+	if bytes.Compare(first, second) >= 0 {
+		t.Errorf("Nonces should increase: %x then %x", first, second)
+	}
+	if _, err := NewNonceSequence([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected error for short prefix")
+	}
+}
+
+func TestPersistentNonceSequenceAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonce.counter")
+	seen := make(map[string]bool)
+
+	// Each iteration stands in for a separate process run sharing the file
+	for run := 0; run < 5; run++ {
+		seq, err := PersistentNonceSequence(path)
+		if err != nil {
+			t.Fatalf("run %d: PersistentNonceSequence failed: %v", run, err)
+		}
+		for i := 0; i < 10; i++ {
+			nonce, err := seq.Next()
+			if err != nil {
+				t.Fatalf("run %d: Next failed: %v", run, err)
+			}
+			if seen[string(nonce)] {
+				t.Fatalf("run %d: nonce %x repeated", run, nonce)
+			}
+			seen[string(nonce)] = true
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "50\n" {
+		t.Errorf("Counter file contains %q, want \"50\\n\"", data)
+	}
+
+	if err := os.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PersistentNonceSequence(path); err == nil {
+		t.Error("Expected error for corrupt counter file")
+	}
+}
+
+func TestPersistentNonceSequenceCreatesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nonce.counter")
+	if _, err := PersistentNonceSequence(path); err != nil {
+		t.Fatalf("PersistentNonceSequence failed: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "0\n" {
+		t.Errorf("New counter file holds %q, %v; want \"0\\n\"", data, err)
+	}
+
+	// A path that can't be written fails up front, not on the first Next
+	if _, err := PersistentNonceSequence(filepath.Join(dir, "missing", "nonce.counter")); err == nil {
+		t.Error("Expected error for a counter file in a missing directory")
+	}
+}
+
+func TestGCMEncryptTrackedRejectsReuse(t *testing.T) {
+	key := []byte("1234567890123456")
+	nonce := []byte("123456789012")
+	tracker, err := NewNonceTracker(16)
+	if err != nil {
+		t.Fatalf("NewNonceTracker failed: %v", err)
+	}
+	if _, err := GCMEncryptTracked(tracker, []byte("first"), key, nonce, nil); err != nil {
+		t.Fatalf("GCMEncryptTracked failed: %v", err)
+	}
+	if _, err := GCMEncryptTracked(tracker, []byte("second"), key, nonce, nil); !errors.Is(err, ErrNonceReused) {
+		t.Errorf("Expected ErrNonceReused, got %v", err)
+	}
+	if _, err := GCMEncryptTracked(tracker, []byte("third"), key, []byte("210987654321"), nil); err != nil {
+		t.Errorf("GCMEncryptTracked with a fresh nonce failed: %v", err)
+	}
+}
+
+func TestNonceTrackerEvictsOldest(t *testing.T) {
+	tracker, err := NewNonceTracker(2)
+	if err != nil {
+		t.Fatalf("NewNonceTracker failed: %v", err)
+	}
+	for _, n := range []string{"a", "b", "a", "c"} {
+		// "a" is rejected but becomes the most recent, so "c" evicts "b"
+		tracker.Check([]byte(n))
+	}
+	if err := tracker.Check([]byte("a")); !errors.Is(err, ErrNonceReused) {
+		t.Errorf("Recently seen nonce accepted: %v", err)
+	}
+	if err := tracker.Check([]byte("b")); err != nil {
+		t.Errorf("Evicted nonce rejected: %v", err)
+	}
+	if _, err := NewNonceTracker(0); err == nil {
+		t.Error("Expected error for zero capacity")
+	}
+}
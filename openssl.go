@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+)
+
+// OpenSSL "enc" salted format: "Salted__" || salt (8 bytes) || CBC ciphertext
+const (
+	opensslMagic    = "Salted__"
+	opensslSaltSize = 8
+)
+
+// evpBytesToKey is OpenSSL's legacy EVP_BytesToKey KDF with MD5 and a single
+// iteration: D_i = MD5(D_{i-1} || password || salt), concatenated until
+// keyLen+ivLen bytes are available
+func evpBytesToKey(password, salt []byte, keyLen, ivLen int) (key, iv []byte) {
+	var out, prev []byte
+	for len(out) < keyLen+ivLen {
+		h := md5.New()
+		h.Write(prev)
+		h.Write(password)
+		h.Write(salt)
+		prev = h.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:keyLen], out[keyLen : keyLen+ivLen]
+}
+
+// DecryptOpenSSLSalted decrypts the output of
+// `openssl enc -aes-128-cbc -salt -md md5 -pass pass:<password>`
+func DecryptOpenSSLSalted(blob, password []byte) ([]byte, error) {
+	if len(blob) < len(opensslMagic)+opensslSaltSize || !bytes.Equal(blob[:len(opensslMagic)], []byte(opensslMagic)) {
+		return nil, fmt.Errorf("missing OpenSSL Salted__ header")
+	}
+	salt := blob[len(opensslMagic) : len(opensslMagic)+opensslSaltSize]
+	key, iv := evpBytesToKey(password, salt, 16, 16)
+	return CBCDecrypt(blob[len(opensslMagic)+opensslSaltSize:], key, iv)
+}
+
+// EncryptOpenSSLSalted produces output that
+// `openssl enc -d -aes-128-cbc -md md5 -pass pass:<password>` can decrypt.
+// EVP_BytesToKey is a weak password KDF; use it for interop only.
+func EncryptOpenSSLSalted(plaintext, password []byte) ([]byte, error) {
+	salt, err := randomBytes(opensslSaltSize)
+	if err != nil {
+		return nil, err
+	}
+	key, iv := evpBytesToKey(password, salt, 16, 16)
+	ct, err := CBCEncrypt(plaintext, key, iv)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(opensslMagic)+len(salt)+len(ct))
+	out = append(out, opensslMagic...)
+	out = append(out, salt...)
+	return append(out, ct...), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/openssl_salted.bin was produced with:
+//
+//	openssl enc -aes-128-cbc -salt -md md5 -pass pass:correct-horse \
+//	    -in testdata/openssl_plain.txt -out testdata/openssl_salted.bin
+func TestDecryptOpenSSLSaltedGolden(t *testing.T) {
+	blob, err := os.ReadFile("testdata/openssl_salted.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/openssl_plain.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecryptOpenSSLSalted(blob, []byte("correct-horse"))
+	if err != nil {
+		t.Fatalf("DecryptOpenSSLSalted failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Decrypted text doesn't match: got %q", got)
+	}
+
+	if _, err := DecryptOpenSSLSalted(blob, []byte("wrong password")); err == nil {
+		t.Error("Expected error with wrong password")
+	}
+	if _, err := DecryptOpenSSLSalted(blob[8:], []byte("correct-horse")); err == nil {
+		t.Error("Expected error without Salted__ header")
+	}
+}
+
+func TestEncryptOpenSSLSalted(t *testing.T) {
+	plaintext := []byte("round trip through the OpenSSL salted format")
+	password := []byte("hunter2")
+
+	blob, err := EncryptOpenSSLSalted(plaintext, password)
+	if err != nil {
+		t.Fatalf("EncryptOpenSSLSalted failed: %v", err)
+	}
+	got, err := DecryptOpenSSLSalted(blob, password)
+	if err != nil {
+		t.Fatalf("DecryptOpenSSLSalted failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	// Cross-check with the real tool when it's installed
+	path, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not installed")
+	}
+	in := filepath.Join(t.TempDir(), "blob.bin")
+	if err := os.WriteFile(in, blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(path, "enc", "-d", "-aes-128-cbc", "-md", "md5", "-pass", "pass:hunter2", "-in", in).Output()
+	if err != nil {
+		t.Fatalf("openssl failed: %v", err)
+	}
+	if !bytes.Equal(out, plaintext) {
+		t.Errorf("openssl output doesn't match: got %q", out)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"runtime"
+	"testing"
+)
+
+func TestOptimalWorkers(t *testing.T) {
+	for _, n := range []int{0, 1, 1024, minBytesPerWorker - 1} {
+		if got := OptimalWorkers(n); got != 1 {
+			t.Errorf("OptimalWorkers(%d) = %d, want 1", n, got)
+		}
+	}
+	if got := OptimalWorkers(1 << 30); got != runtime.NumCPU() {
+		t.Errorf("OptimalWorkers(1 GiB) = %d, want %d", got, runtime.NumCPU())
+	}
+	prev := 1
+	for n := minBytesPerWorker; n <= 64<<20; n *= 2 {
+		got := OptimalWorkers(n)
+		if got < prev || got > runtime.NumCPU() {
+			t.Errorf("OptimalWorkers(%d) = %d, want between %d and %d", n, got, prev, runtime.NumCPU())
+		}
+		prev = got
+	}
+}
+
+// ctrParallelIV has its low half close to wrapping, so some ranges start
+// past a carry into the high half
+const ctrParallelIV = "0000000000000000fffffffffffffff0"
+
+func checkCTRParallel(t *testing.T, data []byte, workers int) {
+	t.Helper()
+	key := []byte("1234567890123456")
+	iv := mustHex(t, ctrParallelIV)
+	want, err := CTREncrypt(data, key, iv)
+	if err != nil {
+		t.Fatalf("CTREncrypt failed: %v", err)
+	}
+	got, err := CTREncryptParallel(data, key, iv, workers)
+	if err != nil {
+		t.Fatalf("CTREncryptParallel(%d bytes, %d workers) failed: %v", len(data), workers, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("CTREncryptParallel(%d bytes, %d workers) differs from CTREncrypt", len(data), workers)
+	}
+}
+
+func TestCTREncryptParallelMatchesSerial(t *testing.T) {
+	data := make([]byte, 256<<10+5)
+	rand.Read(data)
+	for _, workers := range []int{0, 1, 3, 8} {
+		checkCTRParallel(t, data, workers)
+	}
+	for _, n := range []int{0, 1, 33} {
+		checkCTRParallel(t, data[:n], 4)
+	}
+	if _, err := CTREncryptParallel(data[:16], []byte("1234567890123456"), make([]byte, 8), 2); err == nil {
+		t.Error("Expected error for short IV")
+	}
+}
+
+func TestCTREncryptParallel16MB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("16 MB comparison skipped in -short mode")
+	}
+	data := make([]byte, 16<<20)
+	rand.Read(data)
+	checkCTRParallel(t, data, 4)
+}
+
+func BenchmarkCTREncryptParallel(b *testing.B) {
+	key := []byte("1234567890123456")
+	iv := make([]byte, 16)
+	data := make([]byte, 16<<20)
+
+	b.Run("serial", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			CTREncrypt(data, key, iv)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			CTREncryptParallel(data, key, iv, 0)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Each part is a self-contained GCM message:
+//
+//	upload ID (8 bytes) || part index (4) || part count (4) || ciphertext || tag (16)
+//
+// The nonce is upload ID || part index and the 16-byte part header is the
+// AAD, so parts can't be moved between uploads, renumbered or dropped.
+const (
+	partIDSize     = 8
+	partHeaderSize = partIDSize + 4 + 4
+)
+
+// GCMEncryptParts splits plaintext into parts of at most partSize bytes that
+// can be uploaded in any order and decrypted independently
+func GCMEncryptParts(plaintext, key []byte, partSize int) ([][]byte, error) {
+	if partSize <= 0 {
+		return nil, fmt.Errorf("part size must be positive")
+	}
+	count := (len(plaintext) + partSize - 1) / partSize
+	if count == 0 {
+		count = 1
+	}
+	if uint64(count) > 0xffffffff {
+		return nil, fmt.Errorf("too many parts")
+	}
+	id, err := randomBytes(partIDSize)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([][]byte, count)
+	for i := range parts {
+		header := make([]byte, partHeaderSize)
+		copy(header, id)
+		binary.BigEndian.PutUint32(header[partIDSize:], uint32(i))
+		binary.BigEndian.PutUint32(header[partIDSize+4:], uint32(count))
+		chunk := plaintext[min(i*partSize, len(plaintext)):min((i+1)*partSize, len(plaintext))]
+		ct, err := GCMEncrypt(chunk, key, header[:12], header)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = append(header, ct...)
+	}
+	return parts, nil
+}
+
+// GCMDecryptParts verifies every part and reassembles the plaintext in index
+// order. Parts may be supplied in any order but all must be present.
+func GCMDecryptParts(parts [][]byte, key []byte) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no parts")
+	}
+	ordered := make([][]byte, len(parts))
+	var id []byte
+	for _, p := range parts {
+		if len(p) < partHeaderSize+gcmTagSize {
+			return nil, fmt.Errorf("part too short")
+		}
+		header := p[:partHeaderSize]
+		if id == nil {
+			id = header[:partIDSize]
+		} else if !bytes.Equal(id, header[:partIDSize]) {
+			return nil, fmt.Errorf("parts belong to different uploads")
+		}
+		index := binary.BigEndian.Uint32(header[partIDSize:])
+		count := binary.BigEndian.Uint32(header[partIDSize+4:])
+		if uint64(count) != uint64(len(parts)) {
+			return nil, fmt.Errorf("expected %d parts, got %d", count, len(parts))
+		}
+		if index >= count || ordered[index] != nil {
+			return nil, fmt.Errorf("invalid or duplicate part index %d", index)
+		}
+		pt, err := GCMDecrypt(p[partHeaderSize:], key, header[:12], header)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", index, err)
+		}
+		ordered[index] = pt
+	}
+	return bytes.Join(ordered, nil), nil
+}
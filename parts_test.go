@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestGCMPartsShuffled(t *testing.T) {
+	key := []byte("1234567890123456")
+	plaintext := make([]byte, 1000)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+
+	parts, err := GCMEncryptParts(plaintext, key, 64)
+	if err != nil {
+		t.Fatalf("GCMEncryptParts failed: %v", err)
+	}
+	if len(parts) != 16 {
+		t.Fatalf("Got %d parts, want 16", len(parts))
+	}
+
+	// Upload out of order
+	rng := rand.New(rand.NewSource(1))
+	rng.Shuffle(len(parts), func(i, j int) { parts[i], parts[j] = parts[j], parts[i] })
+
+	decrypted, err := GCMDecryptParts(parts, key)
+	if err != nil {
+		t.Fatalf("GCMDecryptParts failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Reassembled plaintext doesn't match")
+	}
+
+	if _, err := GCMDecryptParts(parts[1:], key); err == nil {
+		t.Error("Expected error with a missing part")
+	}
+	tampered := append([][]byte(nil), parts...)
+	tampered[3] = append([]byte(nil), parts[3]...)
+	tampered[3][partHeaderSize] ^= 0x01
+	if _, err := GCMDecryptParts(tampered, key); err == nil {
+		t.Error("Expected authentication failure with a tampered part")
+	}
+
+	other, err := GCMEncryptParts(plaintext, key, 64)
+	if err != nil {
+		t.Fatalf("GCMEncryptParts failed: %v", err)
+	}
+	mixed := append([][]byte{other[0]}, parts[1:]...)
+	if _, err := GCMDecryptParts(mixed, key); err == nil {
+		t.Error("Expected error mixing parts from different uploads")
+	}
+}
+
+func TestGCMPartsEmpty(t *testing.T) {
+	key := []byte("1234567890123456")
+	parts, err := GCMEncryptParts(nil, key, 64)
+	if err != nil {
+		t.Fatalf("GCMEncryptParts failed: %v", err)
+	}
+	decrypted, err := GCMDecryptParts(parts, key)
+	if err != nil {
+		t.Fatalf("GCMDecryptParts failed: %v", err)
+	}
+	if len(decrypted) != 0 {
+		t.Errorf("Expected empty plaintext, got %d bytes", len(decrypted))
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// Password-encrypted format:
+//
+//	"AESP" || version (1) || iterations (4, big-endian) || salt (16) || nonce (12) || ciphertext || tag (16)
+//
+// The key is PBKDF2-HMAC-SHA256(password, salt, iterations) and everything
+// before the nonce is authenticated as GCM AAD.
+//
+// Version 2 derives the key with scrypt instead and records its parameters:
+//
+//	"AESP" || version (1) || N (4) || r (4) || p (4) || salt (16) || nonce (12) || ciphertext || tag (16)
+//
+// Version 3 uses Argon2id, with memory in KiB:
+//
+//	"AESP" || version (1) || time (4) || memory (4) || threads (1) || salt (16) || nonce (12) || ciphertext || tag (16)
+const (
+	passwordMagic         = "AESP"
+	passwordVersion       = 1
+	passwordVersionScrypt = 2
+	passwordVersionArgon2 = 3
+	passwordSaltSize      = 16
+	passwordHeaderSize    = len(passwordMagic) + 1 + 4 + passwordSaltSize
+	passwordScryptHdrSize = len(passwordMagic) + 1 + 12 + passwordSaltSize
+	passwordArgon2HdrSize = len(passwordMagic) + 1 + 9 + passwordSaltSize
+	passwordIterations    = 600000
+
+	// passwordMaxIterations bounds the PBKDF2 count either side accepts,
+	// so a crafted header can't pin a CPU for hours. It is about 16 times
+	// the default.
+	passwordMaxIterations = 10000000
+
+	// Decryption refuses KDF parameters beyond these, so a crafted header
+	// can't make it use more than 1 GiB of memory (128*N*r bytes for
+	// scrypt) or 16 times the single-pass CPU cost. Encryption refuses them
+	// too, so it never writes a blob that can't be opened.
+	passwordScryptMaxNR     = 1 << 23
+	passwordScryptMaxP      = 16
+	passwordArgon2MaxTime   = 16
+	passwordArgon2MaxMemory = 1 << 20 // KiB, so 1 GiB
+)
+
+// ScryptParams selects scrypt for EncryptWithPassword
+type ScryptParams struct {
+	N, R, P int
+}
+
+// DefaultScryptParams are the RFC 7914 interactive-use parameters, which
+// need 32 MB of memory
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// Argon2idParams selects Argon2id for EncryptWithPassword. Memory is in KiB.
+type Argon2idParams struct {
+	Time, Memory uint32
+	Threads      uint8
+}
+
+// DefaultArgon2idParams are the RFC 9106 second recommended option
+// (t=3, 64 MiB, 4 lanes)
+var DefaultArgon2idParams = Argon2idParams{Time: 3, Memory: 64 << 10, Threads: 4}
+
+// Options tunes EncryptWithPassword. The zero value is the safe default.
+type Options struct {
+	// Iterations is the PBKDF2 iteration count; zero means 600000
+	Iterations int
+
+	// Scrypt, if set, derives the key with scrypt instead of PBKDF2 and
+	// Iterations is ignored
+	Scrypt *ScryptParams
+
+	// Argon2id, if set, derives the key with Argon2id instead of PBKDF2.
+	// It can't be combined with Scrypt.
+	Argon2id *Argon2idParams
+
+	// DeterministicSalt replaces the random salt. Together with a fixed
+	// randReader it makes the output reproducible, which is only useful for
+	// test fixtures: reusing a salt across real passwords defeats its
+	// purpose. A warning is printed when it is used outside a test binary.
+	DeterministicSalt []byte
+}
+
+// DeriveKeyPBKDF2 derives a keyLen-byte key from password and salt with
+// PBKDF2-HMAC-SHA256. It panics if keyLen is out of range or, in FIPS
+// 140-only mode, the password or salt is too short.
+func DeriveKeyPBKDF2(password, salt []byte, iterations, keyLen int) []byte {
+	key, err := pbkdf2.Key(sha256.New, string(password), salt, iterations, keyLen)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// warnOutput receives warnings about insecure options
+var warnOutput io.Writer = os.Stderr
+
+// EncryptWithPassword derives a key from password with PBKDF2, or the KDF
+// opts selects, and encrypts plaintext with GCM. opts may be nil.
+func EncryptWithPassword(plaintext, password []byte, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	iterations := opts.Iterations
+	if iterations == 0 {
+		iterations = passwordIterations
+	}
+	if opts.Scrypt != nil && opts.Argon2id != nil {
+		return nil, fmt.Errorf("choose only one of scrypt and Argon2id")
+	}
+	if opts.Scrypt == nil && opts.Argon2id == nil && (iterations < 0 || iterations > passwordMaxIterations) {
+		return nil, fmt.Errorf("iteration count must be between 1 and %d, got %d", passwordMaxIterations, iterations)
+	}
+	if ap := opts.Argon2id; ap != nil {
+		if err := checkArgon2idParams(ap.Time, ap.Memory, ap.Threads); err != nil {
+			return nil, err
+		}
+		if !argon2idWithinLimits(ap.Time, ap.Memory) {
+			return nil, fmt.Errorf("Argon2id parameters t=%d m=%d exceed decryption limits (t at most %d, m at most %d KiB)", ap.Time, ap.Memory, passwordArgon2MaxTime, passwordArgon2MaxMemory)
+		}
+	}
+	if sp := opts.Scrypt; sp != nil {
+		if err := checkScryptParams(sp.N, sp.R, sp.P); err != nil {
+			return nil, err
+		}
+		if uint64(sp.N) > 0xffffffff || uint64(sp.R) > 0xffffffff || uint64(sp.P) > 0xffffffff {
+			return nil, fmt.Errorf("scrypt parameters must fit in 32 bits")
+		}
+		if !scryptWithinLimits(uint64(sp.N), uint64(sp.R), uint64(sp.P)) {
+			return nil, fmt.Errorf("scrypt parameters N=%d r=%d p=%d exceed decryption limits (N*r at most %d, p at most %d)", sp.N, sp.R, sp.P, passwordScryptMaxNR, passwordScryptMaxP)
+		}
+	}
+	var salt []byte
+	if opts.DeterministicSalt != nil {
+		if len(opts.DeterministicSalt) != passwordSaltSize {
+			return nil, fmt.Errorf("deterministic salt must be %d bytes", passwordSaltSize)
+		}
+		if !testing.Testing() {
+			fmt.Fprintln(warnOutput, "warning: DeterministicSalt is for test fixtures only")
+		}
+		salt = opts.DeterministicSalt
+	} else {
+		var err error
+		if salt, err = randomBytes(passwordSaltSize); err != nil {
+			return nil, err
+		}
+	}
+	nonce, err := RandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	var key, header []byte
+	if ap := opts.Argon2id; ap != nil {
+		key = DeriveKeyArgon2id(password, salt, ap.Time, ap.Memory, ap.Threads, 16)
+		header = make([]byte, 0, passwordArgon2HdrSize)
+		header = append(header, passwordMagic...)
+		header = append(header, passwordVersionArgon2)
+		header = binary.BigEndian.AppendUint32(header, ap.Time)
+		header = binary.BigEndian.AppendUint32(header, ap.Memory)
+		header = append(header, ap.Threads)
+	} else if sp := opts.Scrypt; sp != nil {
+		if key, err = DeriveKeyScrypt(password, salt, sp.N, sp.R, sp.P, 16); err != nil {
+			return nil, err
+		}
+		header = make([]byte, 0, passwordScryptHdrSize)
+		header = append(header, passwordMagic...)
+		header = append(header, passwordVersionScrypt)
+		header = binary.BigEndian.AppendUint32(header, uint32(sp.N))
+		header = binary.BigEndian.AppendUint32(header, uint32(sp.R))
+		header = binary.BigEndian.AppendUint32(header, uint32(sp.P))
+	} else {
+		if key, err = pbkdf2.Key(sha256.New, string(password), salt, iterations, 16); err != nil {
+			return nil, err
+		}
+		header = make([]byte, 0, passwordHeaderSize)
+		header = append(header, passwordMagic...)
+		header = append(header, passwordVersion)
+		header = binary.BigEndian.AppendUint32(header, uint32(iterations))
+	}
+	header = append(header, salt...)
+	ct, err := GCMEncrypt(plaintext, key, nonce, header)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(header)+len(nonce)+len(ct))
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return append(out, ct...), nil
+}
+
+// scryptWithinLimits reports whether DecryptWithPassword accepts scrypt
+// parameters n, r and p, each of which fits in 32 bits
+func scryptWithinLimits(n, r, p uint64) bool {
+	return r != 0 && n*r <= passwordScryptMaxNR && p <= passwordScryptMaxP
+}
+
+// argon2idWithinLimits reports whether DecryptWithPassword accepts Argon2id
+// time t and memory m (in KiB)
+func argon2idWithinLimits(t, m uint32) bool {
+	return t <= passwordArgon2MaxTime && m <= passwordArgon2MaxMemory
+}
+
+// DecryptWithPassword verifies and decrypts the output of
+// EncryptWithPassword, using whichever KDF its header names
+func DecryptWithPassword(blob, password []byte) ([]byte, error) {
+	if len(blob) < len(passwordMagic)+1 || !bytes.Equal(blob[:len(passwordMagic)], []byte(passwordMagic)) {
+		return nil, fmt.Errorf("not a password-encrypted blob")
+	}
+	var key, header []byte
+	var err error
+	switch v := blob[len(passwordMagic)]; v {
+	case passwordVersion:
+		if len(blob) < passwordHeaderSize+12+16 {
+			return nil, fmt.Errorf("not a password-encrypted blob")
+		}
+		iterations := binary.BigEndian.Uint32(blob[len(passwordMagic)+1:])
+		if iterations == 0 {
+			return nil, fmt.Errorf("invalid iteration count 0")
+		}
+		if iterations > passwordMaxIterations {
+			return nil, fmt.Errorf("PBKDF2 iteration count %d exceeds decryption limits", iterations)
+		}
+		header = blob[:passwordHeaderSize]
+		salt := header[passwordHeaderSize-passwordSaltSize:]
+		if key, err = pbkdf2.Key(sha256.New, string(password), salt, int(iterations), 16); err != nil {
+			return nil, err
+		}
+	case passwordVersionScrypt:
+		if len(blob) < passwordScryptHdrSize+12+16 {
+			return nil, fmt.Errorf("not a password-encrypted blob")
+		}
+		header = blob[:passwordScryptHdrSize]
+		params := header[len(passwordMagic)+1:]
+		n := binary.BigEndian.Uint32(params)
+		r := binary.BigEndian.Uint32(params[4:])
+		p := binary.BigEndian.Uint32(params[8:])
+		if !scryptWithinLimits(uint64(n), uint64(r), uint64(p)) {
+			return nil, fmt.Errorf("scrypt parameters N=%d r=%d p=%d exceed decryption limits", n, r, p)
+		}
+		salt := header[passwordScryptHdrSize-passwordSaltSize:]
+		if key, err = DeriveKeyScrypt(password, salt, int(n), int(r), int(p), 16); err != nil {
+			return nil, err
+		}
+	case passwordVersionArgon2:
+		if len(blob) < passwordArgon2HdrSize+12+16 {
+			return nil, fmt.Errorf("not a password-encrypted blob")
+		}
+		header = blob[:passwordArgon2HdrSize]
+		params := header[len(passwordMagic)+1:]
+		t := binary.BigEndian.Uint32(params)
+		m := binary.BigEndian.Uint32(params[4:])
+		threads := params[8]
+		if err := checkArgon2idParams(t, m, threads); err != nil {
+			return nil, err
+		}
+		if !argon2idWithinLimits(t, m) {
+			return nil, fmt.Errorf("Argon2id parameters t=%d m=%d exceed decryption limits", t, m)
+		}
+		salt := header[passwordArgon2HdrSize-passwordSaltSize:]
+		key = DeriveKeyArgon2id(password, salt, t, m, threads, 16)
+	default:
+		return nil, fmt.Errorf("%w: password format version %d", ErrUnsupportedVersion, v)
+	}
+	nonce := blob[len(header) : len(header)+12]
+	return GCMDecrypt(blob[len(header)+12:], key, nonce, header)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestEncryptWithPasswordDeterministicSalt(t *testing.T) {
+	saved := randReader
+	defer func() { randReader = saved }()
+
+	password := []byte("correct-horse")
+	plaintext := []byte("fixture plaintext")
+	opts := &Options{Iterations: 1000, DeterministicSalt: []byte("0123456789abcdef")}
+
+	var outputs [2][]byte
+	for i := range outputs {
+		randReader = rand.NewChaCha8([32]byte{1})
+		out, err := EncryptWithPassword(plaintext, password, opts)
+		if err != nil {
+			t.Fatalf("EncryptWithPassword failed: %v", err)
+		}
+		outputs[i] = out
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("Same plaintext, password and salt gave different output")
+	}
+
+	pt, err := DecryptWithPassword(outputs[0], password)
+	if err != nil {
+		t.Fatalf("DecryptWithPassword failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
+
+func TestEncryptWithPasswordRandomSalt(t *testing.T) {
+	password := []byte("correct-horse")
+	opts := &Options{Iterations: 1000}
+	a, err := EncryptWithPassword([]byte("hello"), password, opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	b, err := EncryptWithPassword([]byte("hello"), password, opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	if bytes.Equal(a[:passwordHeaderSize], b[:passwordHeaderSize]) {
+		t.Error("Salt was reused without DeterministicSalt")
+	}
+	if _, err := DecryptWithPassword(a, []byte("wrong password")); err == nil {
+		t.Error("Expected error for wrong password")
+	}
+	if _, err := EncryptWithPassword(nil, password, &Options{DeterministicSalt: []byte("short")}); err == nil {
+		t.Error("Expected error for short deterministic salt")
+	}
+}
+
+func TestDeriveKeyPBKDF2Vectors(t *testing.T) {
+	// The RFC 6070 inputs with SHA-256, plus the 64-byte RFC 7914 section 11
+	// vector
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tc := range tests {
+		got := DeriveKeyPBKDF2([]byte(tc.password), []byte(tc.salt), tc.iterations, len(tc.want)/2)
+		if want := mustHex(t, tc.want); !bytes.Equal(got, want) {
+			t.Errorf("%d iterations: got %x, want %x", tc.iterations, got, want)
+		}
+	}
+}
+
+func TestDecryptWithPasswordIterationLimit(t *testing.T) {
+	blob, err := EncryptWithPassword([]byte("x"), []byte("pw"), &Options{Iterations: 1000})
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	for _, n := range []uint32{passwordMaxIterations + 1, 0xffffffff} {
+		bad := append([]byte(nil), blob...)
+		binary.BigEndian.PutUint32(bad[len(passwordMagic)+1:], n)
+		_, err := DecryptWithPassword(bad, []byte("pw"))
+		if err == nil || !strings.Contains(err.Error(), "limits") {
+			t.Errorf("%d iterations: expected limit error, got %v", n, err)
+		}
+	}
+
+	// Encryption refuses counts it couldn't decrypt
+	if _, err := EncryptWithPassword([]byte("x"), []byte("pw"), &Options{Iterations: passwordMaxIterations + 1}); err == nil {
+		t.Error("Expected error for an iteration count over the limit")
+	}
+}
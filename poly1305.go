@@ -0,0 +1,95 @@
+package main
+
+import "encoding/binary"
+
+// poly1305Sum computes the Poly1305 (RFC 8439 section 2.5) tag of msg
+// under a one-time 32-byte key r || s. It uses 26-bit limbs so every
+// product fits in a uint64 and no step depends on secret data.
+func poly1305Sum(tag *[16]byte, msg []byte, key *[32]byte) {
+	const mask = 0x3ffffff
+	r0 := binary.LittleEndian.Uint32(key[0:]) & 0x3ffffff
+	r1 := (binary.LittleEndian.Uint32(key[3:]) >> 2) & 0x3ffff03
+	r2 := (binary.LittleEndian.Uint32(key[6:]) >> 4) & 0x3ffc0ff
+	r3 := (binary.LittleEndian.Uint32(key[9:]) >> 6) & 0x3f03fff
+	r4 := (binary.LittleEndian.Uint32(key[12:]) >> 8) & 0x00fffff
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+
+	var h0, h1, h2, h3, h4 uint32
+	var block [16]byte
+	for len(msg) > 0 {
+		hibit := uint32(1 << 24)
+		m := msg
+		if len(msg) < 16 {
+			// Final partial block: append a 1 byte instead of the high bit
+			block = [16]byte{}
+			copy(block[:], msg)
+			block[len(msg)] = 1
+			m, hibit = block[:], 0
+		}
+		h0 += binary.LittleEndian.Uint32(m[0:]) & mask
+		h1 += (binary.LittleEndian.Uint32(m[3:]) >> 2) & mask
+		h2 += (binary.LittleEndian.Uint32(m[6:]) >> 4) & mask
+		h3 += (binary.LittleEndian.Uint32(m[9:]) >> 6) & mask
+		h4 += (binary.LittleEndian.Uint32(m[12:]) >> 8) | hibit
+
+		d0 := uint64(h0)*uint64(r0) + uint64(h1)*uint64(s4) + uint64(h2)*uint64(s3) + uint64(h3)*uint64(s2) + uint64(h4)*uint64(s1)
+		d1 := uint64(h0)*uint64(r1) + uint64(h1)*uint64(r0) + uint64(h2)*uint64(s4) + uint64(h3)*uint64(s3) + uint64(h4)*uint64(s2)
+		d2 := uint64(h0)*uint64(r2) + uint64(h1)*uint64(r1) + uint64(h2)*uint64(r0) + uint64(h3)*uint64(s4) + uint64(h4)*uint64(s3)
+		d3 := uint64(h0)*uint64(r3) + uint64(h1)*uint64(r2) + uint64(h2)*uint64(r1) + uint64(h3)*uint64(r0) + uint64(h4)*uint64(s4)
+		d4 := uint64(h0)*uint64(r4) + uint64(h1)*uint64(r3) + uint64(h2)*uint64(r2) + uint64(h3)*uint64(r1) + uint64(h4)*uint64(r0)
+
+		d1 += d0 >> 26
+		h0 = uint32(d0) & mask
+		d2 += d1 >> 26
+		h1 = uint32(d1) & mask
+		d3 += d2 >> 26
+		h2 = uint32(d2) & mask
+		d4 += d3 >> 26
+		h3 = uint32(d3) & mask
+		h0 += uint32(d4>>26) * 5
+		h4 = uint32(d4) & mask
+		h1 += h0 >> 26
+		h0 &= mask
+
+		msg = msg[min(16, len(msg)):]
+	}
+
+	// Fully carry h
+	h2 += h1 >> 26
+	h1 &= mask
+	h3 += h2 >> 26
+	h2 &= mask
+	h4 += h3 >> 26
+	h3 &= mask
+	h0 += (h4 >> 26) * 5
+	h4 &= mask
+	h1 += h0 >> 26
+	h0 &= mask
+
+	// Compute h - p and keep it if it didn't borrow
+	g0 := h0 + 5
+	g1 := h1 + g0>>26
+	g0 &= mask
+	g2 := h2 + g1>>26
+	g1 &= mask
+	g3 := h3 + g2>>26
+	g2 &= mask
+	g4 := h4 + g3>>26 - 1<<26
+	g3 &= mask
+	sel := (g4 >> 31) - 1
+	h0 = h0&^sel | g0&sel
+	h1 = h1&^sel | g1&sel
+	h2 = h2&^sel | g2&sel
+	h3 = h3&^sel | g3&sel
+	h4 = h4&^sel | g4&sel
+
+	// tag = (h + s) mod 2^128
+	f := uint64(h0|h1<<26) + uint64(binary.LittleEndian.Uint32(key[16:]))
+	binary.LittleEndian.PutUint32(tag[0:], uint32(f))
+	f = uint64(h1>>6|h2<<20) + uint64(binary.LittleEndian.Uint32(key[20:])) + f>>32
+	binary.LittleEndian.PutUint32(tag[4:], uint32(f))
+	f = uint64(h2>>12|h3<<14) + uint64(binary.LittleEndian.Uint32(key[24:])) + f>>32
+	binary.LittleEndian.PutUint32(tag[8:], uint32(f))
+	f = uint64(h3>>18|h4<<8) + uint64(binary.LittleEndian.Uint32(key[28:])) + f>>32
+	binary.LittleEndian.PutUint32(tag[12:], uint32(f))
+}
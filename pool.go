@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BufferPool recycles byte slices so that servers encrypting many messages
+// don't allocate a fresh output buffer for each one
+type BufferPool struct {
+	pool sync.Pool
+	// holders recycles the *[]byte wrappers stored in pool, so Put doesn't
+	// allocate one per call
+	holders sync.Pool
+}
+
+// NewBufferPool returns an empty pool
+func NewBufferPool() *BufferPool {
+	return &BufferPool{}
+}
+
+// Get returns a slice of length n, reusing a pooled buffer when one is large
+// enough. The contents are not zeroed.
+func (p *BufferPool) Get(n int) []byte {
+	if v, ok := p.pool.Get().(*[]byte); ok {
+		b := *v
+		*v = nil
+		p.holders.Put(v)
+		if cap(b) >= n {
+			return b[:n]
+		}
+	}
+	return make([]byte, n)
+}
+
+// Put hands b back to the pool. b must not be used afterwards.
+func (p *BufferPool) Put(b []byte) {
+	v, ok := p.holders.Get().(*[]byte)
+	if !ok {
+		v = new([]byte)
+	}
+	*v = b[:0]
+	p.pool.Put(v)
+}
+
+// activePool is the pool set by SetBufferPool, or nil
+var activePool atomic.Pointer[BufferPool]
+
+// SetBufferPool makes the CBC, CTR and GCM functions take their output and
+// scratch buffers from p. Outputs can then be returned with p.Put once the
+// caller is done with them. A nil p turns pooling off.
+func SetBufferPool(p *BufferPool) {
+	activePool.Store(p)
+}
+
+// getBuffer returns an n-byte buffer from the active pool, if any
+func getBuffer(n int) []byte {
+	if p := activePool.Load(); p != nil {
+		return p.Get(n)
+	}
+	return make([]byte, n)
+}
+
+// putBuffer returns a scratch buffer to the active pool, if any. Scratch
+// buffers hold padded plaintext or output that failed to decrypt, so b is
+// cleared first; the pool is shared and Get doesn't zero.
+func putBuffer(b []byte) {
+	if p := activePool.Load(); p != nil {
+		clear(b)
+		p.Put(b)
+	}
+}
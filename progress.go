@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressInterval is how often a progress line is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// progress counts the bytes written to it and redraws a one-line counter
+// on out, with a percentage when the total is known. It discards the data
+// itself, so it goes on the side of a copy through io.TeeReader.
+type progress struct {
+	out   io.Writer
+	total int64 // 0 when the size is unknown, e.g. for a pipe
+	n     int64
+	every time.Duration
+	last  time.Time
+}
+
+func newProgress(out io.Writer, total int64) *progress {
+	return &progress{out: out, total: total, every: progressInterval}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.n += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= p.every {
+		p.last = now
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *progress) draw() {
+	if p.total > 0 {
+		pct := p.n * 100 / p.total
+		if pct > 100 {
+			// The file grew after it was measured
+			pct = 100
+		}
+		fmt.Fprintf(p.out, "\r%d / %d bytes (%d%%)", p.n, p.total, pct)
+		return
+	}
+	fmt.Fprintf(p.out, "\r%d bytes", p.n)
+}
+
+// done draws the final count and ends the line. A nil progress does nothing.
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(p.out)
+}
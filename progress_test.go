@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProgressCountsBytes(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out, 1000)
+	p.every = 0
+	data := bytes.Repeat([]byte("x"), 1000)
+	var copied bytes.Buffer
+	if _, err := io.Copy(&copied, io.TeeReader(&chunkReader{data, 100}, p)); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if !bytes.Equal(copied.Bytes(), data) {
+		t.Error("Data changed passing through the progress tee")
+	}
+	if p.n != 1000 {
+		t.Errorf("Counted %d bytes, want 1000", p.n)
+	}
+	lines := strings.Split(strings.TrimPrefix(out.String(), "\r"), "\r")
+	if len(lines) != 10 {
+		t.Fatalf("Drew %d progress lines, want 10: %q", len(lines), out.String())
+	}
+	if lines[0] != "100 / 1000 bytes (10%)" || lines[9] != "1000 / 1000 bytes (100%)" {
+		t.Errorf("Progress went %q ... %q", lines[0], lines[9])
+	}
+
+	out.Reset()
+	p.done()
+	if out.String() != "\r1000 / 1000 bytes (100%)\n" {
+		t.Errorf("done drew %q", out.String())
+	}
+}
+
+func TestProgressUnknownSize(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out, 0)
+	p.every = 0
+	p.Write(make([]byte, 300))
+	p.Write(make([]byte, 200))
+	if out.String() != "\r300 bytes\r500 bytes" {
+		t.Errorf("Drew %q", out.String())
+	}
+
+	// Redraws are throttled, but the count keeps going
+	out.Reset()
+	p.every = progressInterval
+	p.Write(make([]byte, 10))
+	p.Write(make([]byte, 10))
+	if strings.Count(out.String(), "\r") > 1 || p.n != 520 {
+		t.Errorf("Throttled writes drew %q with count %d", out.String(), p.n)
+	}
+
+	var nilProgress *progress
+	nilProgress.done()
+}
+
+func TestEncryptStreamProgress(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.bin")
+	out := filepath.Join(dir, "out.aess")
+	back := filepath.Join(dir, "back.bin")
+	plaintext := bytes.Repeat([]byte("progress "), 5000)
+	if err := os.WriteFile(in, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+	for _, extra := range [][]string{nil, {"-split", "20000"}} {
+		args := append([]string{"-in", in, "-out", out, "-chunk", "4096", "-progress"}, key...)
+		if err := cmdEncryptStream(append(args, extra...)); err != nil {
+			t.Fatalf("encrypt-stream -progress %v failed: %v", extra, err)
+		}
+		src := out
+		if extra != nil {
+			src = splitPartName(out, 1)
+		}
+		if err := cmdDecryptStream(append([]string{"-in", src, "-out", back}, key...)); err != nil {
+			t.Fatalf("decrypt-stream failed: %v", err)
+		}
+		got, err := os.ReadFile(back)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("-progress %v changed the output", extra)
+		}
+	}
+}
+
+// chunkReader returns data at most n bytes per Read
+type chunkReader struct {
+	data []byte
+	n    int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	k := copy(p[:min(len(p), r.n)], r.data)
+	r.data = r.data[k:]
+	return k, nil
+}
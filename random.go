@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// randReader is the source used for IVs and nonces; tests replace it
+var randReader io.Reader = rand.Reader
+
+// ErrBadRandom is returned when the random source produces output that is
+// obviously broken
+var ErrBadRandom = errors.New("random source failed health check")
+
+// randomBytes reads n bytes from randReader and rejects output where every
+// byte is the same, which in practice only a broken source produces
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		return nil, fmt.Errorf("read random source: %w", err)
+	}
+	if n >= 8 && isRepeating(b) {
+		return nil, ErrBadRandom
+	}
+	return b, nil
+}
+
+func isRepeating(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckRandom draws two samples from the random source and checks that
+// neither is a single repeated byte (such as all zeros) and that they
+// differ. Call it at startup to fail early on a misconfigured system.
+func CheckRandom() error {
+	a, err := randomBytes(32)
+	if err != nil {
+		return err
+	}
+	b, err := randomBytes(32)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(a, b) {
+		return fmt.Errorf("%w: two reads returned the same bytes", ErrBadRandom)
+	}
+	return nil
+}
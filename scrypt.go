@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt (RFC 7914) is a memory-hard KDF: deriving a key needs about
+// 128*N*r bytes of memory, which makes guessing passwords on GPUs and ASICs
+// far more expensive than with PBKDF2.
+
+// checkScryptParams applies the RFC 7914 limits on N, r and p
+func checkScryptParams(n, r, p int) error {
+	if n <= 1 || n&(n-1) != 0 {
+		return fmt.Errorf("scrypt N must be a power of two greater than 1, got %d", n)
+	}
+	if r <= 0 || p <= 0 {
+		return fmt.Errorf("scrypt r and p must be positive")
+	}
+	if uint64(r)*uint64(p) >= 1<<30 {
+		return fmt.Errorf("scrypt r*p must be less than 2^30")
+	}
+	return nil
+}
+
+// DeriveKeyScrypt derives a keyLen-byte key from password and salt with
+// scrypt. N (the CPU/memory cost) must be a power of two; RFC 7914
+// suggests N=32768, r=8, p=1 for interactive use.
+func DeriveKeyScrypt(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+	if err := checkScryptParams(N, r, p); err != nil {
+		return nil, err
+	}
+	if keyLen <= 0 {
+		return nil, fmt.Errorf("scrypt key length must be positive")
+	}
+	key, err := scrypt.Key(password, salt, N, r, p, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeriveKeyScryptVectors(t *testing.T) {
+	// RFC 7914 section 12
+	tests := []struct {
+		password, salt string
+		n, r, p        int
+		want           string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+	for _, tc := range tests {
+		got, err := DeriveKeyScrypt([]byte(tc.password), []byte(tc.salt), tc.n, tc.r, tc.p, 64)
+		if err != nil {
+			t.Fatalf("DeriveKeyScrypt failed: %v", err)
+		}
+		if want := mustHex(t, tc.want); !bytes.Equal(got, want) {
+			t.Errorf("N=%d r=%d p=%d: got %x, want %x", tc.n, tc.r, tc.p, got, want)
+		}
+	}
+}
+
+func TestDeriveKeyScryptInvalidN(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 1000, -16} {
+		if _, err := DeriveKeyScrypt([]byte("pw"), []byte("salt"), n, 8, 1, 16); err == nil {
+			t.Errorf("Expected error for N=%d", n)
+		}
+	}
+	if _, err := DeriveKeyScrypt([]byte("pw"), []byte("salt"), 16, 0, 1, 16); err == nil {
+		t.Error("Expected error for r=0")
+	}
+}
+
+func TestEncryptWithPasswordScrypt(t *testing.T) {
+	plaintext := []byte("memory-hard")
+	opts := &Options{Scrypt: &ScryptParams{N: 1024, R: 8, P: 1}}
+	blob, err := EncryptWithPassword(plaintext, []byte("hunter2"), opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	if blob[len(passwordMagic)] != passwordVersionScrypt {
+		t.Errorf("Version byte %d, want %d", blob[len(passwordMagic)], passwordVersionScrypt)
+	}
+	pt, err := DecryptWithPassword(blob, []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("DecryptWithPassword failed: %v", err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+	if _, err := DecryptWithPassword(blob, []byte("hunter3")); err == nil {
+		t.Error("Expected error for wrong password")
+	}
+
+	// The parameters are authenticated along with the salt
+	bad := append([]byte(nil), blob...)
+	bad[len(passwordMagic)+4] ^= 0x03 // N 1024 -> 1027
+	if _, err := DecryptWithPassword(bad, []byte("hunter2")); err == nil {
+		t.Error("Expected error for tampered N")
+	}
+
+	if _, err := EncryptWithPassword(plaintext, []byte("pw"), &Options{Scrypt: &ScryptParams{N: 1000, R: 8, P: 1}}); err == nil {
+		t.Error("Expected error for non-power-of-two N")
+	}
+}
+
+func TestDecryptWithPasswordScryptLimits(t *testing.T) {
+	blob, err := EncryptWithPassword([]byte("x"), []byte("pw"), &Options{Scrypt: &ScryptParams{N: 16, R: 1, P: 1}})
+	if err != nil {
+		t.Fatalf("EncryptWithPassword failed: %v", err)
+	}
+	for _, tc := range []struct{ n, r, p uint32 }{
+		{1 << 20, 64, 1}, // 8 GiB
+		{1 << 21, 8, 1},  // 2 GiB
+		{1 << 20, 16, 1}, // 2 GiB
+		{16, 1, 64},
+		{16, 1, 17},
+	} {
+		bad := append([]byte(nil), blob...)
+		params := bad[len(passwordMagic)+1:]
+		binary.BigEndian.PutUint32(params, tc.n)
+		binary.BigEndian.PutUint32(params[4:], tc.r)
+		binary.BigEndian.PutUint32(params[8:], tc.p)
+		_, err := DecryptWithPassword(bad, []byte("pw"))
+		if err == nil || !strings.Contains(err.Error(), "limits") {
+			t.Errorf("N=%d r=%d p=%d: expected limit error, got %v", tc.n, tc.r, tc.p, err)
+		}
+	}
+}
+
+func TestEncryptWithPasswordScryptLimits(t *testing.T) {
+	// p at the cap round-trips. N*r at the cap would need 1 GiB, so only
+	// the rejection just past it is exercised.
+	opts := &Options{Scrypt: &ScryptParams{N: 16, R: 1, P: passwordScryptMaxP}}
+	blob, err := EncryptWithPassword([]byte("x"), []byte("pw"), opts)
+	if err != nil {
+		t.Fatalf("EncryptWithPassword at p=%d failed: %v", passwordScryptMaxP, err)
+	}
+	if pt, err := DecryptWithPassword(blob, []byte("pw")); err != nil || string(pt) != "x" {
+		t.Errorf("DecryptWithPassword at p=%d = %q, %v", passwordScryptMaxP, pt, err)
+	}
+
+	for _, sp := range []ScryptParams{
+		{N: 16, R: 1, P: passwordScryptMaxP + 1},
+		{N: passwordScryptMaxNR, R: 2, P: 1},
+		{N: passwordScryptMaxNR / 4, R: 8, P: 1},
+	} {
+		_, err := EncryptWithPassword([]byte("x"), []byte("pw"), &Options{Scrypt: &sp})
+		if err == nil || !strings.Contains(err.Error(), "limits") {
+			t.Errorf("N=%d r=%d p=%d: expected limit error, got %v", sp.N, sp.R, sp.P, err)
+		}
+	}
+}
+
+func TestPasswordFlagScrypt(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	ct := filepath.Join(dir, "ct.bin")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("scrypt from the CLI"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-password", "pw", "-kdf", "scrypt"}); err != nil {
+		t.Fatalf("encrypt-gcm -kdf scrypt failed: %v", err)
+	}
+	if err := cmdDecryptGCM([]string{"-in", ct, "-out", out, "-password", "pw"}); err != nil {
+		t.Fatalf("decrypt-gcm failed: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	if string(got) != "scrypt from the CLI" {
+		t.Errorf("Decrypted text doesn't match")
+	}
+	err := cmdEncryptGCM([]string{"-in", in, "-out", ct, "-password", "pw", "-kdf", "bcrypt"})
+	if _, ok := err.(*usageError); !ok {
+		t.Errorf("Expected usage error for unknown -kdf, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrSidecarMismatch is returned when a ciphertext file no longer matches
+// its HMAC sidecar
+var ErrSidecarMismatch = errors.New("ciphertext does not match sidecar MAC")
+
+// fileHMAC streams path through HMAC-SHA256 under macKey
+func fileHMAC(path string, macKey []byte) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mac := hmac.New(sha256.New, macKey)
+	if _, err := io.Copy(mac, f); err != nil {
+		return nil, err
+	}
+	return mac.Sum(nil), nil
+}
+
+// WriteSidecar stores the hex HMAC-SHA256 of the ciphertext file in macPath
+func WriteSidecar(ciphertextPath, macPath string, macKey []byte) error {
+	sum, err := fileHMAC(ciphertextPath, macKey)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(macPath, []byte(hex.EncodeToString(sum)+"\n"), 0600)
+}
+
+// VerifySidecar recomputes the HMAC of the ciphertext file and compares it
+// in constant time against the one stored in macPath, without decrypting
+func VerifySidecar(ciphertextPath, macPath string, macKey []byte) error {
+	stored, err := os.ReadFile(macPath)
+	if err != nil {
+		return err
+	}
+	want, err := hex.DecodeString(strings.TrimSpace(string(stored)))
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("malformed sidecar %s", macPath)
+	}
+	got, err := fileHMAC(ciphertextPath, macKey)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(got, want) {
+		return ErrSidecarMismatch
+	}
+	return nil
+}
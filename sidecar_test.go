@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifySidecar(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("1234567890123456")
+	macKey := []byte("a separate mac key for sidecars!")
+
+	ct, err := CBCEncrypt([]byte("file stored with a sidecar"), key, []byte("1234567890123456"))
+	if err != nil {
+		t.Fatalf("CBCEncrypt failed: %v", err)
+	}
+	ctPath := filepath.Join(dir, "file.enc")
+	macPath := ctPath + ".mac"
+	if err := os.WriteFile(ctPath, ct, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSidecar(ctPath, macPath, macKey); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+
+	if err := VerifySidecar(ctPath, macPath, macKey); err != nil {
+		t.Errorf("VerifySidecar failed on matching file: %v", err)
+	}
+	if err := VerifySidecar(ctPath, macPath, []byte("wrong mac key")); err != ErrSidecarMismatch {
+		t.Errorf("Expected ErrSidecarMismatch with wrong key, got %v", err)
+	}
+
+	ct[3] ^= 0x80
+	if err := os.WriteFile(ctPath, ct, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySidecar(ctPath, macPath, macKey); err != ErrSidecarMismatch {
+		t.Errorf("Expected ErrSidecarMismatch on corrupted file, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// ErrBadSignature is returned by OpenSigned when the signature over the
+// ciphertext doesn't verify
+var ErrBadSignature = fmt.Errorf("%w: signature verification failed", ErrAuthFailed)
+
+// signedMaxSignature bounds the signature stored in the header
+const signedMaxSignature = 0xffff
+
+// signedDigest returns what signer signs over body and the options to sign
+// it with: Ed25519 signs the message itself, everything else a SHA-256
+// digest
+func signedDigest(pub crypto.PublicKey, body []byte) ([]byte, crypto.SignerOpts) {
+	if _, ok := pub.(ed25519.PublicKey); ok {
+		return body, crypto.Hash(0)
+	}
+	sum := sha256.Sum256(body)
+	return sum[:], crypto.SHA256
+}
+
+// SealSigned encrypts plaintext with AES-GCM under encKey and signs the
+// result with signer, so the recipient can tell who produced it as well as
+// that it wasn't modified.
+// Returns: signature length (2 bytes) || signature || nonce (12 bytes) ||
+// ciphertext || tag (16 bytes), with the signature covering everything
+// after it
+func SealSigned(plaintext, encKey []byte, signer crypto.Signer) ([]byte, error) {
+	nonce, err := RandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	ct, err := GCMEncrypt(plaintext, encKey, nonce, nil)
+	if err != nil {
+		return nil, err
+	}
+	body := prependIV(nonce, ct)
+	digest, opts := signedDigest(signer.Public(), body)
+	sig, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	if len(sig) == 0 || len(sig) > signedMaxSignature {
+		return nil, fmt.Errorf("signature length %d out of range", len(sig))
+	}
+	blob := make([]byte, 2, 2+len(sig)+len(body))
+	binary.BigEndian.PutUint16(blob, uint16(len(sig)))
+	blob = append(blob, sig...)
+	return append(blob, body...), nil
+}
+
+// OpenSigned checks the signature on a SealSigned blob against pub before
+// decrypting it with encKey. pub may be an ed25519.PublicKey,
+// *ecdsa.PublicKey or *rsa.PublicKey (PKCS #1 v1.5).
+func OpenSigned(blob, encKey []byte, pub crypto.PublicKey) ([]byte, error) {
+	if len(blob) < 2 {
+		return nil, fmt.Errorf("blob too short")
+	}
+	n := int(binary.BigEndian.Uint16(blob))
+	if n == 0 || len(blob) < 2+n+gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("blob too short (must have signature + nonce + tag)")
+	}
+	sig := blob[2 : 2+n]
+	body := blob[2+n:]
+	digest, _ := signedDigest(pub, body)
+	var ok bool
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, digest, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(pub, digest, sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) == nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	if !ok {
+		return nil, ErrBadSignature
+	}
+	return GCMDecrypt(body[gcmNonceSize:], encKey, body[:gcmNonceSize], nil)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestSealSignedEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("1234567890123456")
+	plaintext := []byte("signed and sealed")
+
+	blob, err := SealSigned(plaintext, key, priv)
+	if err != nil {
+		t.Fatalf("SealSigned failed: %v", err)
+	}
+	got, err := OpenSigned(blob, key, pub)
+	if err != nil {
+		t.Fatalf("OpenSigned failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+
+	tampered := append([]byte(nil), blob...)
+	tampered[len(tampered)-20] ^= 1
+	if _, err := OpenSigned(tampered, key, pub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for tampered ciphertext, got %v", err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := OpenSigned(blob, key, otherPub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for wrong public key, got %v", err)
+	}
+}
+
+func TestSealSignedECDSA(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("12345678901234567890123456789012")
+	blob, err := SealSigned([]byte("p-256"), key, priv)
+	if err != nil {
+		t.Fatalf("SealSigned failed: %v", err)
+	}
+	got, err := OpenSigned(blob, key, &priv.PublicKey)
+	if err != nil {
+		t.Fatalf("OpenSigned failed: %v", err)
+	}
+	if !bytes.Equal(got, []byte("p-256")) {
+		t.Errorf("Decrypted text doesn't match")
+	}
+}
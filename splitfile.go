@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// splitPartName is the name of the i-th (1-based) file of a split output
+func splitPartName(base string, i int) string {
+	return fmt.Sprintf("%s.%03d", base, i)
+}
+
+// splitWriter spreads a stream across base.001, base.002, ... of at most
+// limit bytes each. It never splits a single Write, and gcmStreamWriter
+// writes the header and each frame with one Write, so every file ends on
+// a frame boundary.
+type splitWriter struct {
+	base  string
+	limit int64
+	f     *os.File
+	n     int64
+	parts []string
+}
+
+func newSplitWriter(base string, limit int64) *splitWriter {
+	return &splitWriter{base: base, limit: limit}
+}
+
+func (s *splitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > s.limit {
+		return 0, fmt.Errorf("split size %d is smaller than a %d-byte frame", s.limit, len(p))
+	}
+	if s.f == nil || s.n+int64(len(p)) > s.limit {
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.f.Write(p)
+	s.n += int64(n)
+	return n, err
+}
+
+func (s *splitWriter) next() error {
+	if s.f != nil {
+		if err := s.f.Close(); err != nil {
+			return err
+		}
+	}
+	name := splitPartName(s.base, len(s.parts)+1)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	s.f, s.n = f, 0
+	s.parts = append(s.parts, name)
+	return nil
+}
+
+// Close closes the current file
+func (s *splitWriter) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// remove deletes every file written so far, for cleaning up after an error
+func (s *splitWriter) remove() {
+	s.Close()
+	for _, name := range s.parts {
+		os.Remove(name)
+	}
+}
+
+// openSplitParts opens name for reading. If name ends in .001 the numbered
+// files that follow it (.002, .003, ...) are read after it as one stream,
+// so a split output is decrypted by naming its first file.
+func openSplitParts(name string) (io.ReadCloser, error) {
+	first, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	base, ok := strings.CutSuffix(name, ".001")
+	if !ok {
+		return first, nil
+	}
+	files := []*os.File{first}
+	for i := 2; ; i++ {
+		f, err := os.Open(splitPartName(base, i))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	readers := make([]io.Reader, len(files))
+	for i, f := range files {
+		readers[i] = f
+	}
+	return &multiFileReader{io.MultiReader(readers...), files}, nil
+}
+
+type multiFileReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (m *multiFileReader) Close() error {
+	return closeFiles(m.files)
+}
+
+func closeFiles(files []*os.File) error {
+	var err error
+	for _, f := range files {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptStreamSplit(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.bin")
+	out := filepath.Join(dir, "out.aess")
+	back := filepath.Join(dir, "back.bin")
+	plaintext := make([]byte, 10000)
+	rand.Read(plaintext)
+	if err := os.WriteFile(in, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := []string{"-key", "1234567890123456"}
+
+	const limit = 2200
+	args := append([]string{"-in", in, "-out", out, "-chunk", "1024", "-split", "2200"}, key...)
+	if err := cmdEncryptStream(args); err != nil {
+		t.Fatalf("encrypt-stream -split failed: %v", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("Unsplit output file was written")
+	}
+
+	// Every file after the first is a whole number of frames
+	var parts int
+	for i := 1; ; i++ {
+		data, err := os.ReadFile(splitPartName(out, i))
+		if err != nil {
+			break
+		}
+		parts++
+		if len(data) > limit {
+			t.Errorf("Part %d is %d bytes, over the %d-byte limit", i, len(data), limit)
+		}
+		if i == 1 {
+			data = data[streamHeaderSize:]
+		}
+		for len(data) > 0 {
+			if len(data) < streamFrameHdrSize {
+				t.Fatalf("Part %d ends inside a frame header", i)
+			}
+			n := streamFrameHdrSize + int(binary.BigEndian.Uint32(data[1:5])) + gcmTagSize
+			if n > len(data) {
+				t.Fatalf("Part %d ends inside a frame", i)
+			}
+			data = data[n:]
+		}
+	}
+	if parts < 2 {
+		t.Fatalf("Expected several parts, got %d", parts)
+	}
+
+	args = append([]string{"-in", splitPartName(out, 1), "-out", back}, key...)
+	if err := cmdDecryptStream(args); err != nil {
+		t.Fatalf("decrypt-stream failed: %v", err)
+	}
+	got, err := os.ReadFile(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Reassembled plaintext doesn't match")
+	}
+
+	// Losing the last part is caught as truncation
+	os.Remove(splitPartName(out, parts))
+	if err := cmdDecryptStream(args); err == nil {
+		t.Error("Expected decrypt-stream to fail with a missing part")
+	}
+}
+
+func TestEncryptStreamSplitTooSmall(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.bin")
+	if err := os.WriteFile(in, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := cmdEncryptStream([]string{"-in", in, "-out", filepath.Join(dir, "out"), "-key", "1234567890123456", "-chunk", "1024", "-split", "1000"})
+	if _, ok := err.(*usageError); !ok {
+		t.Errorf("Expected usage error for split smaller than a frame, got %v", err)
+	}
+}
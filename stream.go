@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Chunked GCM stream format
+//
+//	header: "AESS" || version (1) || chunk size (4, big-endian) || nonce prefix (8)
+//	frame:  flags (1) || plaintext length (4, big-endian) || ciphertext || tag (16)
+//
+// Frame i is encrypted with nonce = prefix || uint32(i) and
+// AAD = header || uint64(i) || flags, so frames cannot be reordered, moved
+// between streams or dropped. The last frame carries streamFlagFinal, which
+// makes truncation at a frame boundary detectable.
+//
+// Version 2 streams, written from a KeyRing, add the key epoch to each frame:
+//
+//	frame:  flags (1) || key epoch (4, big-endian) || plaintext length (4) || ciphertext || tag (16)
+//
+// with AAD = header || uint64(i) || flags || epoch.
+const (
+	streamMagic          = "AESS"
+	streamVersion        = 1
+	streamVersionKeyRing = 2
+	streamPrefixSize     = 8
+	streamHeaderSize     = len(streamMagic) + 1 + 4 + streamPrefixSize
+	streamFrameHdrSize   = 1 + 4
+	streamEpochSize      = 4
+	streamMinChunk       = 16 << 10
+	streamMaxChunk       = 4 << 20
+	streamTargetFrames   = 1000
+
+	streamFlagFinal byte = 1
+)
+
+var (
+	ErrStreamHeader    = errors.New("invalid stream header")
+	ErrStreamTruncated = errors.New("stream truncated")
+)
+
+// AdaptiveChunkSize picks a frame size for a stream of totalSize bytes,
+// aiming for about streamTargetFrames frames. The result is a power of two
+// clamped between 16 KB and 4 MB.
+func AdaptiveChunkSize(totalSize int64) int {
+	target := totalSize / streamTargetFrames
+	size := streamMinChunk
+	for int64(size) < target && size < streamMaxChunk {
+		size <<= 1
+	}
+	return size
+}
+
+// streamFrameAAD builds the frame AAD; epoch is empty for version 1 streams
+func streamFrameAAD(header []byte, index uint64, flags byte, epoch []byte) []byte {
+	aad := make([]byte, 0, len(header)+9+len(epoch))
+	aad = append(aad, header...)
+	aad = binary.BigEndian.AppendUint64(aad, index)
+	aad = append(aad, flags)
+	return append(aad, epoch...)
+}
+
+func streamFrameNonce(prefix []byte, index uint64) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], uint32(index))
+	return nonce
+}
+
+// streamFrameKey returns the key frames are encrypted with: key itself, or
+// when the stream has a label the HKDF subkey for it. SecureConn labels each
+// direction so a peer's own frames can't be reflected back to it.
+func streamFrameKey(key, label []byte) []byte {
+	if label == nil {
+		return key
+	}
+	return HKDF(key, nil, label, len(key))
+}
+
+type gcmStreamWriter struct {
+	w         io.Writer
+	key       []byte
+	ring      *KeyRing
+	label     []byte
+	header    []byte
+	chunkSize int
+	buf       []byte
+	index     uint64
+	closed    bool
+}
+
+// NewGCMStreamWriter returns a writer that encrypts everything written to it
+// as a sequence of authenticated GCM frames of at most chunkSize bytes.
+// Close must be called to emit the final frame.
+func NewGCMStreamWriter(w io.Writer, key []byte, chunkSize int) (io.WriteCloser, error) {
+	return newGCMStreamWriter(w, key, chunkSize)
+}
+
+func newGCMStreamWriter(w io.Writer, key []byte, chunkSize int) (*gcmStreamWriter, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	return newStreamWriter(w, append([]byte(nil), key...), nil, chunkSize)
+}
+
+// NewGCMStreamWriterKeyRing is NewGCMStreamWriter for a rotating key: each
+// frame is encrypted with the ring's current epoch at the time it is
+// written, so calling ring.SetCurrent switches keys mid-stream
+func NewGCMStreamWriterKeyRing(w io.Writer, ring *KeyRing, chunkSize int) (io.WriteCloser, error) {
+	return newGCMStreamWriterKeyRing(w, ring, chunkSize)
+}
+
+func newGCMStreamWriterKeyRing(w io.Writer, ring *KeyRing, chunkSize int) (*gcmStreamWriter, error) {
+	if _, _, err := ring.Current(); err != nil {
+		return nil, err
+	}
+	return newStreamWriter(w, nil, ring, chunkSize)
+}
+
+func newStreamWriter(w io.Writer, key []byte, ring *KeyRing, chunkSize int) (*gcmStreamWriter, error) {
+	if chunkSize <= 0 || chunkSize > streamMaxChunk {
+		return nil, fmt.Errorf("chunk size must be between 1 and %d bytes", streamMaxChunk)
+	}
+	header := make([]byte, streamHeaderSize)
+	copy(header, streamMagic)
+	header[4] = streamVersion
+	if ring != nil {
+		header[4] = streamVersionKeyRing
+	}
+	binary.BigEndian.PutUint32(header[5:9], uint32(chunkSize))
+	prefix, err := randomBytes(streamPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+	copy(header[9:], prefix)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &gcmStreamWriter{
+		w:         w,
+		key:       key,
+		ring:      ring,
+		header:    header,
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (s *gcmStreamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, fmt.Errorf("write to closed stream")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// A full buffer is only flushed once more data arrives, so the
+		// last frame can always be marked final on Close
+		if len(s.buf) == s.chunkSize {
+			if err := s.writeFrame(0); err != nil {
+				return n - len(p), err
+			}
+		}
+		k := min(s.chunkSize-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:k]...)
+		p = p[k:]
+	}
+	return n, nil
+}
+
+func (s *gcmStreamWriter) writeFrame(flags byte) error {
+	if s.index > 0xffffffff {
+		return fmt.Errorf("stream exceeds maximum frame count")
+	}
+	key := s.key
+	var epoch []byte
+	if s.ring != nil {
+		id, k, err := s.ring.Current()
+		if err != nil {
+			return err
+		}
+		key = k
+		epoch = binary.BigEndian.AppendUint32(nil, id)
+	}
+	nonce := streamFrameNonce(s.header[9:], s.index)
+	ct, err := GCMEncrypt(s.buf, streamFrameKey(key, s.label), nonce, streamFrameAAD(s.header, s.index, flags, epoch))
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 0, streamFrameHdrSize+len(epoch)+len(ct))
+	frame = append(frame, flags)
+	frame = append(frame, epoch...)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(s.buf)))
+	frame = append(frame, ct...)
+	if _, err := s.w.Write(frame); err != nil {
+		return err
+	}
+	s.index++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// flush writes any buffered data as a non-final frame so it reaches the
+// reader without waiting for more input
+func (s *gcmStreamWriter) flush() error {
+	if s.closed || len(s.buf) == 0 {
+		return nil
+	}
+	return s.writeFrame(0)
+}
+
+// Close writes the final frame. It does not close the underlying writer.
+func (s *gcmStreamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.writeFrame(streamFlagFinal)
+}
+
+type gcmStreamReader struct {
+	r         io.Reader
+	key       []byte
+	ring      *KeyRing
+	label     []byte
+	header    []byte
+	chunkSize int
+	index     uint64
+	pending   []byte
+	done      bool
+	err       error
+}
+
+// NewGCMStreamReader returns a reader that verifies and decrypts a stream
+// produced by NewGCMStreamWriter. Read returns an error as soon as a frame
+// fails authentication or the stream ends before the final frame.
+func NewGCMStreamReader(r io.Reader, key []byte) (io.Reader, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	return newStreamReader(r, append([]byte(nil), key...), nil)
+}
+
+// NewGCMStreamReaderKeyRing is NewGCMStreamReader for streams written with
+// NewGCMStreamWriterKeyRing: each frame is decrypted with the key for the
+// epoch it names. Version 1 streams, which carry no epochs, are read with
+// the ring's current key.
+func NewGCMStreamReaderKeyRing(r io.Reader, ring *KeyRing) (io.Reader, error) {
+	return newStreamReader(r, nil, ring)
+}
+
+func newStreamReader(r io.Reader, key []byte, ring *KeyRing) (*gcmStreamReader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrStreamHeader
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], []byte(streamMagic)) {
+		return nil, ErrStreamHeader
+	}
+	switch header[4] {
+	case streamVersion:
+		if ring != nil {
+			_, k, err := ring.Current()
+			if err != nil {
+				return nil, err
+			}
+			key, ring = k, nil
+		}
+	case streamVersionKeyRing:
+		if ring == nil {
+			return nil, fmt.Errorf("stream uses key epochs and needs a key ring")
+		}
+	default:
+		return nil, fmt.Errorf("%w: stream version %d", ErrUnsupportedVersion, header[4])
+	}
+	chunkSize := int(binary.BigEndian.Uint32(header[5:9]))
+	if chunkSize <= 0 || chunkSize > streamMaxChunk {
+		return nil, ErrStreamHeader
+	}
+	return &gcmStreamReader{
+		r:         r,
+		key:       key,
+		ring:      ring,
+		header:    header,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+func (s *gcmStreamReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.readFrame()
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *gcmStreamReader) readFrame() error {
+	var hdrBuf [streamFrameHdrSize + streamEpochSize]byte
+	hdr := hdrBuf[:streamFrameHdrSize]
+	if s.ring != nil {
+		hdr = hdrBuf[:]
+	}
+	if _, err := io.ReadFull(s.r, hdr); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrStreamTruncated
+		}
+		return err
+	}
+	flags := hdr[0]
+	if flags&^streamFlagFinal != 0 {
+		return fmt.Errorf("frame %d: unknown flags 0x%02x", s.index, flags)
+	}
+	key := s.key
+	var epoch []byte
+	if s.ring != nil {
+		epoch = hdr[1 : 1+streamEpochSize]
+		k, err := s.ring.Key(binary.BigEndian.Uint32(epoch))
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", s.index, err)
+		}
+		key = k
+	}
+	n := int(binary.BigEndian.Uint32(hdr[len(hdr)-4:]))
+	if n > s.chunkSize {
+		return fmt.Errorf("frame %d: length %d exceeds chunk size", s.index, n)
+	}
+	if s.index > 0xffffffff {
+		return fmt.Errorf("stream exceeds maximum frame count")
+	}
+	ct := make([]byte, n+16)
+	if _, err := io.ReadFull(s.r, ct); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrStreamTruncated
+		}
+		return err
+	}
+	nonce := streamFrameNonce(s.header[9:], s.index)
+	pt, err := GCMDecrypt(ct, streamFrameKey(key, s.label), nonce, streamFrameAAD(s.header, s.index, flags, epoch))
+	if err != nil {
+		return fmt.Errorf("frame %d: %w", s.index, err)
+	}
+	s.index++
+	if flags&streamFlagFinal != 0 {
+		// Nothing may follow the final frame
+		var extra [1]byte
+		if k, _ := io.ReadFull(s.r, extra[:]); k != 0 {
+			return fmt.Errorf("trailing data after final frame")
+		}
+		s.done = true
+	}
+	s.pending = pt
+	return nil
+}
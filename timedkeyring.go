@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrKeyExpired is returned by TimedKeyRing.Get for a key past its TTL
+var ErrKeyExpired = errors.New("key expired")
+
+// TimedKeyRing caches keys by ID for a fixed TTL. Expired keys are wiped
+// with Zeroize when they are evicted, either by Get or by the background
+// cleanup goroutine.
+type TimedKeyRing struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]timedKey
+	now     func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type timedKey struct {
+	key     []byte
+	expires time.Time
+}
+
+// NewTimedKeyRing returns an empty ring whose keys live for ttl. If
+// cleanupInterval is positive a goroutine purges expired keys that often
+// until Stop is called.
+func NewTimedKeyRing(ttl, cleanupInterval time.Duration) *TimedKeyRing {
+	r := &TimedKeyRing{
+		ttl:     ttl,
+		entries: make(map[string]timedKey),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go r.cleanup(cleanupInterval)
+	} else {
+		close(r.done)
+	}
+	return r
+}
+
+func (r *TimedKeyRing) cleanup(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.Purge()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the cleanup goroutine and waits for it to exit. Keys already in
+// the ring are kept and still expire on Get.
+func (r *TimedKeyRing) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+// Put stores a copy of key under id, replacing (and wiping) any previous
+// key with that id. The TTL starts now.
+func (r *TimedKeyRing) Put(id string, key []byte) error {
+	if err := checkKeySize(key); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.entries[id]; ok {
+		Zeroize(old.key)
+	}
+	r.entries[id] = timedKey{append([]byte(nil), key...), r.now().Add(r.ttl)}
+	return nil
+}
+
+// Get returns a copy of the key for id, or ErrKeyExpired (after evicting it)
+// if its TTL has passed
+func (r *TimedKeyRing) Get(id string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	if !r.now().Before(e.expires) {
+		r.evict(id, e)
+		return nil, fmt.Errorf("%w: %q", ErrKeyExpired, id)
+	}
+	return append([]byte(nil), e.key...), nil
+}
+
+// Purge evicts every expired key and returns how many were removed
+func (r *TimedKeyRing) Purge() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	n := 0
+	for id, e := range r.entries {
+		if !now.Before(e.expires) {
+			r.evict(id, e)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of keys held, expired or not
+func (r *TimedKeyRing) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// evict wipes and removes an entry; r.mu must be held
+func (r *TimedKeyRing) evict(id string, e timedKey) {
+	Zeroize(e.key)
+	delete(r.entries, id)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimedKeyRingExpiresAndZeroes(t *testing.T) {
+	r := NewTimedKeyRing(20*time.Millisecond, 5*time.Millisecond)
+	defer r.Stop()
+	key := []byte("1234567890123456")
+	if err := r.Put("session", key); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, err := r.Get("session")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Errorf("Get returned the wrong key")
+	}
+
+	r.mu.Lock()
+	stored := r.entries["session"].key
+	r.mu.Unlock()
+
+	// Wait for the cleanup goroutine to evict it
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expired key was never purged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !bytes.Equal(stored, make([]byte, len(key))) {
+		t.Errorf("Evicted key bytes not zeroed: %x", stored)
+	}
+	if !bytes.Equal(got, key) {
+		t.Errorf("Eviction wiped the copy returned by Get")
+	}
+	if _, err := r.Get("session"); err == nil {
+		t.Error("Expected error for purged key")
+	}
+}
+
+func TestTimedKeyRingGetExpired(t *testing.T) {
+	r := NewTimedKeyRing(time.Minute, 0)
+	defer r.Stop()
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+	if err := r.Put("k", []byte("1234567890123456")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	r.mu.Lock()
+	stored := r.entries["k"].key
+	r.mu.Unlock()
+
+	now = now.Add(59 * time.Second)
+	if _, err := r.Get("k"); err != nil {
+		t.Fatalf("Get before TTL failed: %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := r.Get("k"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, got %v", err)
+	}
+	if !bytes.Equal(stored, make([]byte, 16)) {
+		t.Errorf("Expired key bytes not zeroed: %x", stored)
+	}
+	if r.Len() != 0 {
+		t.Errorf("Expired key still held")
+	}
+}
+
+func TestTimedKeyRingStop(t *testing.T) {
+	r := NewTimedKeyRing(time.Minute, time.Millisecond)
+	r.Stop()
+	r.Stop()
+	if err := r.Put("k", []byte("short")); err == nil {
+		t.Error("Expected error for invalid key size")
+	}
+}
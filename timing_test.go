@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Statistical timing checks for the constant-time paths. Timing on a shared
+// machine is noisy, so these only catch gross regressions (such as an early
+// exit on the first differing byte) and use a generous threshold. They are
+// skipped with -short or when AES_SKIP_TIMING_TESTS is set.
+
+const (
+	timingSamples   = 201
+	timingBatch     = 2000
+	timingMaxSpread = 1.5
+)
+
+func skipTimingTest(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("timing test skipped in -short mode")
+	}
+	if os.Getenv("AES_SKIP_TIMING_TESTS") != "" {
+		t.Skip("timing test skipped by AES_SKIP_TIMING_TESTS")
+	}
+}
+
+// medianTimes runs a and b alternately in batches and returns the median
+// batch duration of each, so that drift affects both equally
+func medianTimes(a, b func()) (time.Duration, time.Duration) {
+	ta := make([]time.Duration, timingSamples)
+	tb := make([]time.Duration, timingSamples)
+	for i := 0; i < timingSamples; i++ {
+		start := time.Now()
+		for j := 0; j < timingBatch; j++ {
+			a()
+		}
+		ta[i] = time.Since(start)
+		start = time.Now()
+		for j := 0; j < timingBatch; j++ {
+			b()
+		}
+		tb[i] = time.Since(start)
+	}
+	sort.Slice(ta, func(i, j int) bool { return ta[i] < ta[j] })
+	sort.Slice(tb, func(i, j int) bool { return tb[i] < tb[j] })
+	return ta[timingSamples/2], tb[timingSamples/2]
+}
+
+func checkSpread(t *testing.T, what string, a, b time.Duration) {
+	t.Helper()
+	ratio := float64(a) / float64(b)
+	if ratio < 1 {
+		ratio = 1 / ratio
+	}
+	t.Logf("%s: %v vs %v per %d calls (ratio %.2f)", what, a, b, timingBatch, ratio)
+	if ratio > timingMaxSpread {
+		t.Errorf("%s: timing differs by %.2fx, want at most %.2fx (may be noise; rerun or set AES_SKIP_TIMING_TESTS)", what, ratio, timingMaxSpread)
+	}
+}
+
+var timingSink bool
+
+func TestTagCompareConstantTime(t *testing.T) {
+	skipTimingTest(t)
+	tag := []byte("0123456789abcdef")
+	same := append([]byte(nil), tag...)
+	firstByteDiffers := append([]byte(nil), tag...)
+	firstByteDiffers[0] ^= 0xff
+
+	match, mismatch := medianTimes(
+		func() { timingSink = tagsEqual(tag, same) },
+		func() { timingSink = tagsEqual(tag, firstByteDiffers) },
+	)
+	checkSpread(t, "tag comparison", match, mismatch)
+}
+
+func TestPaddingCheckConstantTime(t *testing.T) {
+	skipTimingTest(t)
+	// Both inputs have bad padding, one wrong next to the length byte and
+	// one wrong at the far end of the block. An early-exit loop rejects the
+	// first almost immediately.
+	valid := make([]byte, 32)
+	for i := 16; i < 32; i++ {
+		valid[i] = 16
+	}
+	badEarly := append([]byte(nil), valid...)
+	badEarly[30] = 0
+	badLate := append([]byte(nil), valid...)
+	badLate[16] = 0
+
+	early, late := medianTimes(
+		func() { _, err := PKCS7Unpad(badEarly, 16); timingSink = err == nil },
+		func() { _, err := PKCS7Unpad(badLate, 16); timingSink = err == nil },
+	)
+	checkSpread(t, "padding check", early, late)
+}
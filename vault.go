@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// FileVault encrypts files at rest without exposing nonces, IVs or file
+// formats to the caller. Each Encrypt draws a fresh nonce (and, for a
+// passphrase vault, a fresh salt), and everything needed to decrypt is
+// stored in the file itself.
+type FileVault struct {
+	key        []byte
+	passphrase []byte
+	opts       *Options
+}
+
+// NewFileVault returns a vault that seals files with AES-GCM under key,
+// using the framed envelope format
+func NewFileVault(key []byte) (*FileVault, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	return &FileVault{key: append([]byte(nil), key...)}, nil
+}
+
+// NewPassphraseVault returns a vault that derives a per-file key from
+// passphrase with EncryptWithPassword. opts may be nil.
+func NewPassphraseVault(passphrase []byte, opts *Options) *FileVault {
+	return &FileVault{passphrase: append([]byte(nil), passphrase...), opts: opts}
+}
+
+// Encrypt seals plaintext and writes it to path, replacing any existing
+// file
+func (v *FileVault) Encrypt(path string, plaintext []byte) error {
+	var blob []byte
+	var err error
+	if v.key != nil {
+		blob, err = SealEnvelope(ModeGCM, plaintext, v.key, nil)
+	} else {
+		blob, err = EncryptWithPassword(plaintext, v.passphrase, v.opts)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, blob, 0600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Decrypt reads path and returns the verified plaintext
+func (v *FileVault) Decrypt(path string) ([]byte, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var pt []byte
+	if v.key != nil {
+		// Only GCM: the header's mode byte isn't authenticated in the
+		// other modes, so trusting it would let a forged file skip the tag
+		pt, err = SecureDecrypt(blob, v.key)
+	} else {
+		pt, err = DecryptWithPassword(blob, v.passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pt, nil
+}
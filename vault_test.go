@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileVaultRoundTrip(t *testing.T) {
+	key := []byte("1234567890123456")
+	keyVault, err := NewFileVault(key)
+	if err != nil {
+		t.Fatalf("NewFileVault failed: %v", err)
+	}
+	vaults := map[string]*FileVault{
+		"key":        keyVault,
+		"passphrase": NewPassphraseVault([]byte("correct-horse"), &Options{Iterations: 1000}),
+	}
+	plaintext := []byte("secrets at rest")
+
+	for name, v := range vaults {
+		path := filepath.Join(t.TempDir(), "secret.bin")
+		// Encrypting the same plaintext to the same path again must not
+		// reuse a nonce, so the two files differ
+		var files [2][]byte
+		for i := range files {
+			if err := v.Encrypt(path, plaintext); err != nil {
+				t.Fatalf("%s vault: Encrypt failed: %v", name, err)
+			}
+			if files[i], err = os.ReadFile(path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := v.Decrypt(path)
+			if err != nil {
+				t.Fatalf("%s vault: Decrypt failed: %v", name, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("%s vault: decrypted text doesn't match", name)
+			}
+		}
+		if bytes.Equal(files[0], files[1]) {
+			t.Errorf("%s vault: two encryptions produced identical files", name)
+		}
+		if bytes.Contains(files[0], plaintext) {
+			t.Errorf("%s vault: plaintext visible in file", name)
+		}
+
+		files[1][len(files[1])-1] ^= 1
+		if err := os.WriteFile(path, files[1], 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Decrypt(path); err == nil {
+			t.Errorf("%s vault: expected error for tampered file", name)
+		}
+	}
+
+	if _, err := NewFileVault([]byte("short")); err == nil {
+		t.Error("Expected error for bad key size")
+	}
+}
+
+// forgeCTREnvelope relabels a framed GCM blob as CTR without the key. GCM
+// encrypts the plaintext with CTR from counter nonce || 00000002, so that
+// counter block as the IV and the tag dropped give a CTR envelope of the
+// same plaintext, whose bits can then be flipped at will.
+func forgeCTREnvelope(t *testing.T, blob []byte) []byte {
+	t.Helper()
+	e, body, err := ParseEnvelope(blob)
+	if err != nil || e.Mode != ModeGCM {
+		t.Fatalf("forgeCTREnvelope needs a GCM envelope, got %v, %v", e, err)
+	}
+	e.Mode = ModeCTR
+	forged := e.marshal()
+	forged = append(forged, body[:gcmNonceSize]...)
+	forged = append(forged, 0, 0, 0, 2)
+	return append(forged, body[gcmNonceSize:len(body)-gcmTagSize]...)
+}
+
+func TestFileVaultRejectsForgedMode(t *testing.T) {
+	key := []byte("1234567890123456")
+	v, err := NewFileVault(key)
+	if err != nil {
+		t.Fatalf("NewFileVault failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "payment.bin")
+	if err := v.Encrypt(path, []byte("pay alice $100")); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := forgeCTREnvelope(t, blob)
+	// '$' -> ',' in "pay alice $100"
+	forged[len(forged)-4] ^= '$' ^ ','
+
+	// The forgery really is a valid CTR envelope of the edited text
+	pt, err := OpenEnvelope(forged, key, nil, ModeCTR)
+	if err != nil || string(pt) != "pay alice ,100" {
+		t.Fatalf("Forgery opened as %q, %v", pt, err)
+	}
+
+	if err := os.WriteFile(path, forged, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := v.Decrypt(path); err == nil {
+		t.Errorf("Decrypt accepted a forged CTR file as %q", pt)
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import "runtime"
+
+// Zeroize overwrites b with zeros, for wiping key material that is no
+// longer needed. KeepAlive stops the compiler treating the writes as dead
+// stores.
+func Zeroize(b []byte) {
+	clear(b)
+	runtime.KeepAlive(b)
+}
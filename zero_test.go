@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestZeroize(t *testing.T) {
+	b := make([]byte, 64)
+	for i := range b {
+		b[i] = byte(i + 1)
+	}
+	Zeroize(b)
+	for i, v := range b {
+		if v != 0 {
+			t.Fatalf("Byte %d = %#x after Zeroize", i, v)
+		}
+	}
+	Zeroize(nil)
+}